@@ -24,6 +24,8 @@ import (
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/resourceprocessor"
 
 	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/dockerstats"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/hoststatsreceiver"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/nginxerrorlogreceiver"
 	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/nginxreceiver"
 	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/vmagereceiver"
 )
@@ -40,7 +42,9 @@ func components() (component.Factories, error) {
 
 	receivers, err := component.MakeReceiverFactoryMap(
 		dockerstats.NewFactory(),
+		hoststatsreceiver.NewFactory(),
 		nginxreceiver.NewFactory(),
+		nginxerrorlogreceiver.NewFactory(),
 		vmagereceiver.NewFactory(),
 	)
 	if err != nil {
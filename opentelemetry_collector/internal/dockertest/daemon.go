@@ -0,0 +1,196 @@
+package dockertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	versionedPathRegexp    = regexp.MustCompile(`^/v([0-9]+\.[0-9]+)(/.*)$`)
+	containerStatsRegexp   = regexp.MustCompile(`^/containers/([^/]+)/stats$`)
+	containerInspectRegexp = regexp.MustCompile(`^/containers/([^/]+)/json$`)
+)
+
+// response replaces the fixture served for a path.
+type response struct {
+	status int
+	body   string
+}
+
+// Daemon is an in-process Docker Engine API server listening on a unix socket, so that
+// code using the docker client can be tested with the real client, including the JSON
+// decoding, API version negotiation and HTTP errors.
+type Daemon struct {
+	t        *testing.T
+	server   *httptest.Server
+	socket   string
+	fixtures string
+
+	mu sync.Mutex
+	// maxAPIVersion is the most recent API version the daemon supports.
+	maxAPIVersion string
+	// latency delays every response, to test timeouts.
+	latency time.Duration
+	// responses replace the fixtures of some paths, without the API version prefix.
+	responses map[string]response
+	// requests are the paths of the requests received, with the API version prefix.
+	requests []string
+}
+
+// NewDaemon starts a daemon supporting API versions up to maxAPIVersion, serving its
+// responses from the fixtures directory: containers.json for /containers/json,
+// stats/<id>.json for /containers/<id>/stats, inspect/<id>.json for /containers/<id>/json
+// and events.jsonl for /events. It is closed at the end of the test.
+func NewDaemon(t *testing.T, fixtures string, maxAPIVersion string) *Daemon {
+	// Unix socket paths are limited to about 100 characters, which t.TempDir can exceed.
+	dir, err := ioutil.TempDir("", "docker")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	d := &Daemon{
+		t:             t,
+		socket:        filepath.Join(dir, "docker.sock"),
+		fixtures:      fixtures,
+		maxAPIVersion: maxAPIVersion,
+		responses:     make(map[string]response),
+	}
+	listener, err := net.Listen("unix", d.socket)
+	require.NoError(t, err)
+	d.server = httptest.NewUnstartedServer(http.HandlerFunc(d.serveHTTP))
+	d.server.Listener = listener
+	d.server.Start()
+	t.Cleanup(d.server.Close)
+	return d
+}
+
+// Host returns the docker host to connect to the daemon, as set in DOCKER_HOST.
+func (d *Daemon) Host() string {
+	return "unix://" + d.socket
+}
+
+// NewClient creates a docker client connected to the daemon, negotiating the API version.
+func (d *Daemon) NewClient() *client.Client {
+	docker, err := client.NewClientWithOpts(client.WithHost(d.Host()), client.WithAPIVersionNegotiation())
+	require.NoError(d.t, err)
+	d.t.Cleanup(func() { docker.Close() })
+	return docker
+}
+
+// SetLatency delays every following response by latency.
+func (d *Daemon) SetLatency(latency time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latency = latency
+}
+
+// Respond makes the daemon answer the requests to path, e.g. /containers/id1/stats,
+// with status and body instead of the fixture.
+func (d *Daemon) Respond(path string, status int, body string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.responses[path] = response{status: status, body: body}
+}
+
+// Fail makes the daemon answer the requests to path with status and a Docker error message.
+func (d *Daemon) Fail(path string, status int) {
+	d.Respond(path, status, dockerError(fmt.Sprintf("injected failure of %s", path)))
+}
+
+// ReceivedRequests returns the paths of the requests received so far.
+func (d *Daemon) ReceivedRequests() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.requests...)
+}
+
+func dockerError(message string) string {
+	b, _ := json.Marshal(map[string]string{"message": message})
+	return string(b)
+}
+
+func (d *Daemon) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	d.requests = append(d.requests, r.URL.Path)
+	latency := d.latency
+	maxAPIVersion := d.maxAPIVersion
+	d.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("API-Version", maxAPIVersion)
+	w.Header().Set("OSType", "linux")
+
+	path := r.URL.Path
+	if match := versionedPathRegexp.FindStringSubmatch(path); match != nil {
+		if versions.GreaterThan(match[1], maxAPIVersion) {
+			writeResponse(w, http.StatusBadRequest, dockerError(fmt.Sprintf(
+				"client version %s is too new. Maximum supported API version is %s", match[1], maxAPIVersion)))
+			return
+		}
+		path = match[2]
+	}
+
+	d.mu.Lock()
+	response, ok := d.responses[path]
+	d.mu.Unlock()
+	if ok {
+		writeResponse(w, response.status, response.body)
+		return
+	}
+
+	var fixture string
+	switch {
+	case path == "/_ping":
+		writeResponse(w, http.StatusOK, "OK")
+		return
+	case path == "/containers/json":
+		fixture = "containers.json"
+	case path == "/events":
+		fixture = "events.jsonl"
+	case containerStatsRegexp.MatchString(path):
+		fixture = filepath.Join("stats", containerStatsRegexp.FindStringSubmatch(path)[1]+".json")
+	case containerInspectRegexp.MatchString(path):
+		fixture = filepath.Join("inspect", containerInspectRegexp.FindStringSubmatch(path)[1]+".json")
+	default:
+		writeResponse(w, http.StatusNotFound, dockerError("page not found"))
+		return
+	}
+
+	body, err := ioutil.ReadFile(filepath.Join(d.fixtures, fixture))
+	if os.IsNotExist(err) {
+		// The daemon answers the requests about unknown containers with a 404.
+		writeResponse(w, http.StatusNotFound, dockerError("No such container: "+path))
+		return
+	}
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, dockerError(err.Error()))
+		return
+	}
+	writeResponse(w, http.StatusOK, string(body))
+}
+
+func writeResponse(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
@@ -0,0 +1,28 @@
+package dockertest
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonEvents(t *testing.T) {
+	daemon := NewDaemon(t, "testdata", "1.41")
+	messages, errs := daemon.NewClient().Events(context.Background(), types.EventsOptions{})
+
+	var actual []string
+	for len(actual) < 2 {
+		select {
+		case m := <-messages:
+			actual = append(actual, m.Action+" "+m.Actor.ID)
+		case err := <-errs:
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, []string{"start id1", "die id3"}, actual)
+	assert.Equal(t, io.EOF, <-errs)
+}
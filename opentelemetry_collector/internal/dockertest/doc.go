@@ -0,0 +1,9 @@
+// Package dockertest provides a fake Docker Engine API server to test the receivers
+// that read from docker. It is only meant to be imported by tests. A Daemon serves
+// the containers, stats, inspect and events endpoints from fixture files, and its
+// failures and latency can be injected, e.g.
+//
+//	daemon := dockertest.NewDaemon(t, "testdata/docker", "1.41")
+//	daemon.Fail("/containers/id1/stats", 500)
+//	docker := daemon.NewClient()
+package dockertest
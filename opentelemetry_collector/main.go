@@ -25,6 +25,10 @@ import (
 	"go.opentelemetry.io/collector/service"
 )
 
+// version is the version of the collector, set at build time with
+// -ldflags "-X main.version=<version>". See the Dockerfile.
+var version = "unknown"
+
 func main() {
 	handleErr := func(err error) {
 		if err != nil {
@@ -39,7 +43,7 @@ func main() {
 		BuildInfo: component.BuildInfo{
 			Command:     "otelcontribcol",
 			Description: "AppEngine Flex OpenTelemetry Contrib Collector",
-			Version:     "latest",
+			Version:     version,
 		},
 	}
 
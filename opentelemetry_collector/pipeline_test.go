@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.opentelemetry.io/collector/service"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/googlecloudexporter"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/internal/dockertest"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+var placeholderRegexp = regexp.MustCompile(`@[A-Z_]+@`)
+
+// testResourceAttributes are the resource attributes the resource processor adds with the
+// values renderConfig substitutes.
+var testResourceAttributes = map[string]string{
+	"opencensus.resourcetype": "gae_instance",
+	"cloud.region":            "us-central1",
+	"appengine.service.id":    "test-service",
+	"appengine.version.id":    "test-version",
+	"appengine.instance.id":   "test-instance",
+}
+
+// renderConfig substitutes the placeholders of opentelemetry_config.yaml like run.sh does,
+// and returns the path of the rendered config.
+func renderConfig(t *testing.T, nginxStatsURL string) string {
+	b, err := ioutil.ReadFile("opentelemetry_config.yaml")
+	require.NoError(t, err)
+
+	rendered := strings.NewReplacer(
+		"@IMAGE_NAME@", "gae-flex-v20200101",
+		"@VERSION@", testResourceAttributes["appengine.version.id"],
+		"@SERVICE@", testResourceAttributes["appengine.service.id"],
+		"@INSTANCE@", testResourceAttributes["appengine.instance.id"],
+		"@NGINX_STATS_URL@", nginxStatsURL,
+		"@REGION@", testResourceAttributes["cloud.region"],
+	).Replace(string(b))
+	// A new placeholder must be substituted by run.sh too.
+	require.Empty(t, placeholderRegexp.FindAllString(rendered, -1), "placeholders not substituted by run.sh")
+
+	path := filepath.Join(t.TempDir(), "opentelemetry_config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(rendered), 0644))
+	return path
+}
+
+// capturedMetrics keeps the metrics sent to each googlecloud exporter, with the prefix
+// the exporter adds to the metric names.
+type capturedMetrics struct {
+	mu       sync.Mutex
+	sinks    map[string]*metricstest.Sink
+	prefixes map[string]string
+}
+
+// exportedNames returns the names the metrics sent to the exporter id are exported with.
+func (c *capturedMetrics) exportedNames(id string) []string {
+	c.mu.Lock()
+	sink, prefix := c.sinks[id], c.prefixes[id]
+	c.mu.Unlock()
+	if sink == nil {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for _, md := range sink.AllMetrics() {
+		rms := md.ResourceMetrics()
+		for i := 0; i < rms.Len(); i++ {
+			ilms := rms.At(i).InstrumentationLibraryMetrics()
+			for j := 0; j < ilms.Len(); j++ {
+				metrics := ilms.At(j).Metrics()
+				for k := 0; k < metrics.Len(); k++ {
+					names[prefix+"/"+metrics.At(k).Name()] = true
+				}
+			}
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// resources returns the attributes of the resources of the metrics sent to the exporter id.
+func (c *capturedMetrics) resources(id string) []map[string]string {
+	c.mu.Lock()
+	sink := c.sinks[id]
+	c.mu.Unlock()
+
+	var resources []map[string]string
+	for _, md := range sink.AllMetrics() {
+		rms := md.ResourceMetrics()
+		for i := 0; i < rms.Len(); i++ {
+			attributes := make(map[string]string)
+			rms.At(i).Resource().Attributes().Range(func(k string, v pdata.AttributeValue) bool {
+				attributes[k] = v.AsString()
+				return true
+			})
+			resources = append(resources, attributes)
+		}
+	}
+	return resources
+}
+
+// capturingExporter stands in for a googlecloud exporter, keeping the metrics in a sink.
+type capturingExporter struct {
+	*metricstest.Sink
+}
+
+var _ component.MetricsExporter = capturingExporter{}
+
+func (e capturingExporter) Start(context.Context, component.Host) error {
+	return nil
+}
+
+func (e capturingExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// newCapturingExporterFactory returns a factory that accepts the googlecloud exporter config
+// and creates exporters capturing their metrics in captured.
+func newCapturingExporterFactory(captured *capturedMetrics) component.ExporterFactory {
+	googlecloud := googlecloudexporter.NewFactory()
+	return component.NewExporterFactory(
+		googlecloud.Type(),
+		googlecloud.CreateDefaultConfig,
+		component.WithMetricsExporter(func(_ context.Context, _ component.ExporterCreateSettings, cfg config.Exporter) (component.MetricsExporter, error) {
+			captured.mu.Lock()
+			defer captured.mu.Unlock()
+			id := cfg.ID().String()
+			sink := &metricstest.Sink{}
+			captured.sinks[id] = sink
+			captured.prefixes[id] = cfg.(*googlecloudexporter.Config).MetricConfig.Prefix
+			return capturingExporter{Sink: sink}, nil
+		}),
+	)
+}
+
+func TestShippedConfigLoads(t *testing.T) {
+	factories, err := components()
+	require.NoError(t, err)
+
+	provider := service.MustNewDefaultConfigProvider([]string{renderConfig(t, "http://localhost/nginx_status")}, nil)
+	cfg, err := provider.Get(context.Background(), factories)
+	require.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+	assert.NoError(t, provider.Shutdown(context.Background()))
+}
+
+func TestShippedConfigPipelines(t *testing.T) {
+	nginx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/nginx_status.json")
+	}))
+	defer nginx.Close()
+
+	docker := dockertest.NewDaemon(t, "receiver/dockerstats/testdata/docker", "1.41")
+	t.Setenv("DOCKER_HOST", docker.Host())
+	t.Setenv("DOCKER_API_VERSION", "")
+	t.Setenv("DOCKER_CERT_PATH", "")
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+	t.Setenv("BUILD_DATE", "2020-01-01T00:00:00Z")
+	t.Setenv("VM_START_TIME", "2020-01-02T00:00:00Z")
+	readyTimeFile := filepath.Join(t.TempDir(), "vm_ready_time")
+	require.NoError(t, ioutil.WriteFile(readyTimeFile, []byte("2020-01-02T00:01:00Z\n"), 0644))
+	startupTimestampsFile := filepath.Join(t.TempDir(), "startup_timestamps")
+	require.NoError(t, ioutil.WriteFile(startupTimestampsFile, []byte("vm_start 2020-01-02T00:00:00Z\ncollector_started 2020-01-02T00:00:30Z\nvm_ready 2020-01-02T00:01:00Z\n"), 0644))
+	errorLogDir := t.TempDir()
+	errorLogFile := filepath.Join(errorLogDir, "error.log")
+	require.NoError(t, ioutil.WriteFile(errorLogFile, []byte("2020/01/01 00:00:00 [error] 7#7: *1 connect() failed (111: Connection refused) while connecting to upstream\n"), 0644))
+
+	factories, err := components()
+	require.NoError(t, err)
+	captured := &capturedMetrics{sinks: make(map[string]*metricstest.Sink), prefixes: make(map[string]string)}
+	capturing := newCapturingExporterFactory(captured)
+	factories.Exporters[capturing.Type()] = capturing
+
+	properties := []string{
+		// The host stats are read from fixtures rather than from the host running the test.
+		"receivers.hoststats.proc_root=receiver/hoststatsreceiver/testdata/proc",
+		"receivers.hoststats.sys_root=receiver/hoststatsreceiver/testdata/sys",
+		"receivers.hoststats.root_path=",
+		"receivers.vmage.proc_root=receiver/vmagereceiver/testdata/proc",
+		"receivers.vmage.os_release_file=receiver/vmagereceiver/testdata/os-release",
+		"receivers.vmage.vm_ready_time_source.file=" + readyTimeFile,
+		"receivers.vmage.startup_timestamps_file=" + startupTimestampsFile,
+		"receivers.nginxerrorlog.error_log_path=" + errorLogFile,
+		"receivers.nginxerrorlog.position_file=" + filepath.Join(errorLogDir, "position"),
+		"receivers.nginxerrorlog.read_from_beginning=true",
+		"service.telemetry.metrics.level=none",
+		"service.telemetry.logs.level=error",
+	}
+	col, err := service.New(service.CollectorSettings{
+		Factories:               factories,
+		BuildInfo:               component.BuildInfo{Command: "otelcontribcol", Version: "test"},
+		DisableGracefulShutdown: true,
+		ConfigProvider:          service.MustNewDefaultConfigProvider([]string{renderConfig(t, nginx.URL)}, properties),
+	})
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- col.Run(context.Background()) }()
+
+	// The receivers scrape right away, so every pipeline exports before the first interval.
+	expected := map[string][]string{
+		"googlecloud": {
+			"appengine.googleapis.com/flex/internal/on_vm_request_latencies",
+			"appengine.googleapis.com/flex/internal/nginx/error_log_entries",
+			"appengine.googleapis.com/flex/internal/on_vm_upstream_latencies",
+			"appengine.googleapis.com/flex/internal/vm_image_age",
+			"appengine.googleapis.com/flex/internal/vm_ready_time",
+			"appengine.googleapis.com/flex/internal/vm_startup_duration",
+			"appengine.googleapis.com/flex/internal/vm_startup_phase_duration",
+			"appengine.googleapis.com/flex/internal/web_socket/durations",
+		},
+		"googlecloud/instance": {
+			"appengine.googleapis.com/flex/instance/agent/container/cpu/usage_time",
+			"appengine.googleapis.com/flex/instance/agent/container/memory/usage",
+			"appengine.googleapis.com/flex/instance/agent/container/uptime",
+			"appengine.googleapis.com/flex/instance/agent/host/cpu/usage_time",
+			"appengine.googleapis.com/flex/instance/agent/host/memory/usage",
+		},
+	}
+	assert.Eventually(t, func() bool {
+		for id, names := range expected {
+			exported := captured.exportedNames(id)
+			for _, name := range names {
+				if !contains(exported, name) {
+					return false
+				}
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+
+	col.Shutdown()
+	require.NoError(t, <-done)
+
+	// The vmage, nginxstats and nginxerrorlog metrics are exported by googlecloud, the
+	// dockerstats and hoststats metrics by googlecloud/instance.
+	for _, name := range captured.exportedNames("googlecloud") {
+		assert.NotRegexp(t, `/(container|host)/`, name)
+	}
+	for _, name := range captured.exportedNames("googlecloud/instance") {
+		assert.Regexp(t, `^appengine.googleapis.com/flex/instance/agent/(container|host)/`, name)
+	}
+
+	for id := range expected {
+		resources := captured.resources(id)
+		assert.NotEmpty(t, resources, id)
+		for _, attributes := range resources {
+			assert.Equal(t, testResourceAttributes, attributes, id)
+		}
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
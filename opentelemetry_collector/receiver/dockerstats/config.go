@@ -4,11 +4,14 @@ import (
 	"time"
 
 	"go.opentelemetry.io/collector/config"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 // Config defines the configuration for dockerstats receiver.
 type Config struct {
 	config.ReceiverSettings `mapstructure:",squash"`
+	scrapeloop.Settings     `mapstructure:",squash"`
 	// ScrapeInterval controls how often docker stats are scraped from docker API.
 	ScrapeInterval time.Duration `mapstructure:"scrape_interval"`
 }
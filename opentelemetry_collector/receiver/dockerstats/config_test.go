@@ -9,6 +9,8 @@ import (
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/service/servicetest"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -28,5 +30,6 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, customReceiver, &Config{
 		ReceiverSettings: config.NewReceiverSettings(config.NewComponentIDWithName("dockerstats", "customname")),
 		ScrapeInterval:   10 * time.Minute,
+		Settings:         scrapeloop.Settings{InitialDelay: 5 * time.Second, Jitter: 10 * time.Second, Timeout: 30 * time.Second},
 	})
 }
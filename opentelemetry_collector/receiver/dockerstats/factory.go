@@ -8,6 +8,8 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/consumer"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 const typeStr = "dockerstats"
@@ -27,7 +29,7 @@ func createMetricsReceiver(ctx context.Context, settings component.ReceiverCreat
 		return nil, fmt.Errorf("invalid scrape duration: %v, must be positive", c.ScrapeInterval)
 	}
 
-	s, err := newScraper(c.ScrapeInterval, nextConsumer, settings.Logger)
+	s, err := newScraper(scrapeloop.Config{Interval: c.ScrapeInterval, Settings: c.Settings}, nextConsumer, settings.Logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dockerstats scraper: %v", err)
 	}
@@ -26,8 +26,9 @@ func (r *Receiver) Start(ctx context.Context, host component.Host) error {
 
 // Shutdown tells this receiver to stop.
 func (r *Receiver) Shutdown(ctx context.Context) error {
+	var err error
 	r.stopOnce.Do(func() {
-		r.scraper.stop()
+		err = r.scraper.stop(ctx)
 	})
-	return nil
+	return err
 }
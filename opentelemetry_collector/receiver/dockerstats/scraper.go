@@ -15,74 +15,75 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
-
-	mpb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/opencensus"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 var (
-	containerNameLabel = &mpb.LabelKey{
+	// metricRegistry holds the descriptors of the metrics generated by the docker stats receiver.
+	metricRegistry = metricgenerator.NewRegistry()
+
+	containerNameLabel = metricgenerator.LabelKey{
 		Key:         "container_name",
 		Description: "Name of the container (or ID if name is not available)",
 	}
 
-	cpuUsageDesc = &mpb.MetricDescriptor{
+	cpuUsageDesc = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 		Name:        "container/cpu/usage_time",
 		Description: "Total CPU time consumed",
 		Unit:        "seconds",
-		Type:        mpb.MetricDescriptor_CUMULATIVE_DOUBLE,
-		LabelKeys:   []*mpb.LabelKey{containerNameLabel},
-	}
-	cpuLimitDesc = &mpb.MetricDescriptor{
+		Type:        metricgenerator.CumulativeDouble,
+		LabelKeys:   []metricgenerator.LabelKey{containerNameLabel},
+	})
+	cpuLimitDesc = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 		Name:        "container/cpu/limit",
 		Description: "CPU time limit (where applicable)",
 		Unit:        "seconds",
-		Type:        mpb.MetricDescriptor_GAUGE_DOUBLE,
-		LabelKeys:   []*mpb.LabelKey{containerNameLabel},
-	}
-	memUsageDesc = &mpb.MetricDescriptor{
+		Type:        metricgenerator.GaugeDouble,
+		LabelKeys:   []metricgenerator.LabelKey{containerNameLabel},
+	})
+	memUsageDesc = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 		Name:        "container/memory/usage",
 		Description: "Total memory the container is using",
 		Unit:        "bytes",
-		Type:        mpb.MetricDescriptor_GAUGE_INT64,
-		LabelKeys:   []*mpb.LabelKey{containerNameLabel},
-	}
-	memLimitDesc = &mpb.MetricDescriptor{
+		Type:        metricgenerator.GaugeInt64,
+		LabelKeys:   []metricgenerator.LabelKey{containerNameLabel},
+	})
+	memLimitDesc = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 		Name:        "container/memory/limit",
 		Description: "Total memory the container is allowed to use",
 		Unit:        "bytes",
-		Type:        mpb.MetricDescriptor_GAUGE_INT64,
-		LabelKeys:   []*mpb.LabelKey{containerNameLabel},
-	}
-	nwRecvBytesDesc = &mpb.MetricDescriptor{
+		Type:        metricgenerator.GaugeInt64,
+		LabelKeys:   []metricgenerator.LabelKey{containerNameLabel},
+	})
+	nwRecvBytesDesc = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 		Name:        "container/network/received_bytes_count",
 		Description: "Bytes received by container over all network interfaces",
 		Unit:        "bytes",
-		Type:        mpb.MetricDescriptor_CUMULATIVE_INT64,
-		LabelKeys:   []*mpb.LabelKey{containerNameLabel},
-	}
-	nwSentBytesDesc = &mpb.MetricDescriptor{
+		Type:        metricgenerator.CumulativeInt64,
+		LabelKeys:   []metricgenerator.LabelKey{containerNameLabel},
+	})
+	nwSentBytesDesc = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 		Name:        "container/network/sent_bytes_count",
 		Description: "Bytes sent by container over all network interfaces",
 		Unit:        "byte",
-		Type:        mpb.MetricDescriptor_CUMULATIVE_INT64,
-		LabelKeys:   []*mpb.LabelKey{containerNameLabel},
-	}
+		Type:        metricgenerator.CumulativeInt64,
+		LabelKeys:   []metricgenerator.LabelKey{containerNameLabel},
+	})
 	// Container health metrics.
-	uptimeDesc = &mpb.MetricDescriptor{
+	uptimeDesc = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 		Name:        "container/uptime",
 		Description: "Container uptime",
 		Unit:        "seconds",
-		Type:        mpb.MetricDescriptor_GAUGE_INT64,
-		LabelKeys:   []*mpb.LabelKey{containerNameLabel},
-	}
-	restartCountDesc = &mpb.MetricDescriptor{
+		Type:        metricgenerator.GaugeInt64,
+		LabelKeys:   []metricgenerator.LabelKey{containerNameLabel},
+	})
+	restartCountDesc = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 		Name:        "container/restart_count",
 		Description: "Number of times the container has been restarted.",
 		Unit:        "Count",
-		Type:        mpb.MetricDescriptor_CUMULATIVE_INT64,
-		LabelKeys:   []*mpb.LabelKey{containerNameLabel},
-	}
+		Type:        metricgenerator.CumulativeInt64,
+		LabelKeys:   []metricgenerator.LabelKey{containerNameLabel},
+	})
 )
 
 type containerInfo struct {
@@ -92,10 +93,9 @@ type containerInfo struct {
 }
 
 type scraper struct {
-	startTime      time.Time
-	scrapeInterval time.Duration
-	done           chan bool
-	scrapeCount    uint64
+	startTime   time.Time
+	controller  *scrapeloop.Controller
+	scrapeCount uint64
 
 	metricConsumer consumer.Metrics
 	docker         client.ContainerAPIClient
@@ -104,54 +104,47 @@ type scraper struct {
 	now func() time.Time
 }
 
-func newScraper(scrapeInterval time.Duration, metricConsumer consumer.Metrics, logger *zap.Logger) (*scraper, error) {
+func newScraper(scrapeCfg scrapeloop.Config, metricConsumer consumer.Metrics, logger *zap.Logger) (*scraper, error) {
 	docker, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize docker client: %v", err)
 	}
 
-	return &scraper{
-		scrapeInterval: scrapeInterval,
-		done:           make(chan bool),
+	s := &scraper{
 		metricConsumer: metricConsumer,
 		docker:         docker,
 		logger:         logger,
 		now:            time.Now,
-	}, nil
+	}
+	s.controller, err = scrapeloop.NewController(scrapeCfg, s.scrape)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 func (s *scraper) start() {
 	s.startTime = s.now()
-	go func() {
-		ticker := time.NewTicker(s.scrapeInterval)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				s.export()
-				s.scrapeCount++
-			case <-s.done:
-				return
-			}
-		}
-	}()
+	s.controller.Start()
 }
 
-func (s *scraper) stop() {
-	s.done <- true
+func (s *scraper) stop(ctx context.Context) error {
+	return s.controller.Shutdown(ctx)
 }
 
-func (s *scraper) export() {
-	ctx, cancel := context.WithTimeout(context.Background(), s.scrapeInterval)
-	defer cancel()
+func (s *scraper) scrape(ctx context.Context) {
+	s.export(ctx)
+	s.scrapeCount++
+}
 
+func (s *scraper) export(ctx context.Context) {
 	containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{})
 	if err != nil {
 		s.logger.Warn("Failed to get docker container list.", zap.Error(err))
 		return
 	}
 
-	var metrics []*mpb.Metric
+	builder := metricgenerator.NewMetricsBuilder()
 	for _, container := range containers {
 		var name string
 		if len(container.Names) > 0 {
@@ -161,24 +154,27 @@ func (s *scraper) export() {
 		} else {
 			name = container.ID
 		}
-		labelValues := []*mpb.LabelValue{metricgenerator.MakeLabelValue(name)}
+		attributes := map[string]string{containerNameLabel.Key: name}
 		cLogger := s.logger.With(zap.String("name", name), zap.String("id", container.ID))
 
 		stats, err := s.readResourceUsageStats(ctx, container.ID)
 		if err != nil {
 			cLogger.Warn("readResourceUsageStats failed.", zap.Error(err))
 		} else {
-			metrics = append(metrics, s.usageStatsToMetrics(stats, labelValues)...)
+			s.usageStatsToMetrics(builder, stats, attributes)
 		}
 
 		info, err := s.readContainerInfo(ctx, container.ID)
 		if err != nil {
 			cLogger.Warn("readContainerInfo failed.", zap.Error(err))
 		} else {
-			metrics = append(metrics, s.containerInfoToMetrics(info, labelValues)...)
+			s.containerInfoToMetrics(builder, info, attributes)
 		}
 	}
-	err = s.metricConsumer.ConsumeMetrics(ctx, opencensus.OCToMetrics(nil, nil, metrics))
+	if err := metricRegistry.Validate(builder.Metrics()); err != nil {
+		s.logger.Error("Generated metrics do not match their descriptors", zap.Error(err))
+	}
+	err = s.metricConsumer.ConsumeMetrics(ctx, builder.Metrics())
 	if err != nil {
 		s.logger.Error("Error sending docker stats metrics", zap.Error(err))
 	}
@@ -203,47 +199,20 @@ func (s *scraper) readResourceUsageStats(ctx context.Context, id string) (*types
 	return &stats, nil
 }
 
-func (s *scraper) usageStatsToMetrics(stats *types.StatsJSON, labelValues []*mpb.LabelValue) []*mpb.Metric {
+func (s *scraper) usageStatsToMetrics(builder *metricgenerator.MetricsBuilder, stats *types.StatsJSON, attributes map[string]string) {
 	var rx, tx uint64
 	for _, nw := range stats.Networks {
 		rx += nw.RxBytes
 		tx += nw.TxBytes
 	}
 
-	return []*mpb.Metric{
-		{
-			MetricDescriptor: cpuUsageDesc,
-			Timeseries: []*mpb.TimeSeries{
-				metricgenerator.MakeDoubleTimeSeries(time.Duration(stats.CPUStats.CPUUsage.TotalUsage).Seconds(), s.startTime, s.now(), labelValues),
-			},
-		},
-		// Unfortunately, Docker API doesn't expose CPU Limits via CPUStats API. That information
-		// is extracted via container inspection (see readInfo() below).
-		{
-			MetricDescriptor: memUsageDesc,
-			Timeseries: []*mpb.TimeSeries{
-				metricgenerator.MakeInt64TimeSeries(int64(stats.MemoryStats.Usage), s.startTime, s.now(), labelValues),
-			},
-		},
-		{
-			MetricDescriptor: memLimitDesc,
-			Timeseries: []*mpb.TimeSeries{
-				metricgenerator.MakeInt64TimeSeries(int64(stats.MemoryStats.Limit), s.startTime, s.now(), labelValues),
-			},
-		},
-		{
-			MetricDescriptor: nwRecvBytesDesc,
-			Timeseries: []*mpb.TimeSeries{
-				metricgenerator.MakeInt64TimeSeries(int64(rx), s.startTime, s.now(), labelValues),
-			},
-		},
-		{
-			MetricDescriptor: nwSentBytesDesc,
-			Timeseries: []*mpb.TimeSeries{
-				metricgenerator.MakeInt64TimeSeries(int64(tx), s.startTime, s.now(), labelValues),
-			},
-		},
-	}
+	builder.AddMetric(cpuUsageDesc).AddDoublePoint(time.Duration(stats.CPUStats.CPUUsage.TotalUsage).Seconds(), s.startTime, s.now(), attributes)
+	// Unfortunately, Docker API doesn't expose CPU Limits via CPUStats API. That information
+	// is extracted via container inspection (see readInfo() below).
+	builder.AddMetric(memUsageDesc).AddInt64Point(int64(stats.MemoryStats.Usage), s.startTime, s.now(), attributes)
+	builder.AddMetric(memLimitDesc).AddInt64Point(int64(stats.MemoryStats.Limit), s.startTime, s.now(), attributes)
+	builder.AddMetric(nwRecvBytesDesc).AddInt64Point(int64(rx), s.startTime, s.now(), attributes)
+	builder.AddMetric(nwSentBytesDesc).AddInt64Point(int64(tx), s.startTime, s.now(), attributes)
 }
 
 func (s *scraper) readContainerInfo(ctx context.Context, id string) (containerInfo, error) {
@@ -269,28 +238,10 @@ func (s *scraper) readContainerInfo(ctx context.Context, id string) (containerIn
 	return info, nil
 }
 
-func (s *scraper) containerInfoToMetrics(info containerInfo, labelValues []*mpb.LabelValue) []*mpb.Metric {
-	metrics := []*mpb.Metric{
-		{
-			MetricDescriptor: uptimeDesc,
-			Timeseries: []*mpb.TimeSeries{
-				metricgenerator.MakeInt64TimeSeries(int64(info.uptime.Seconds()), s.startTime, s.now(), labelValues),
-			},
-		},
-		{
-			MetricDescriptor: restartCountDesc,
-			Timeseries: []*mpb.TimeSeries{
-				metricgenerator.MakeInt64TimeSeries(info.restartCount, s.startTime, s.now(), labelValues),
-			},
-		},
-	}
+func (s *scraper) containerInfoToMetrics(builder *metricgenerator.MetricsBuilder, info containerInfo, attributes map[string]string) {
+	builder.AddMetric(uptimeDesc).AddInt64Point(int64(info.uptime.Seconds()), s.startTime, s.now(), attributes)
+	builder.AddMetric(restartCountDesc).AddInt64Point(info.restartCount, s.startTime, s.now(), attributes)
 	if info.cpuLimit > 0 { // only generate if the container has a CPU limit.
-		metrics = append(metrics, &mpb.Metric{
-			MetricDescriptor: cpuLimitDesc,
-			Timeseries: []*mpb.TimeSeries{
-				metricgenerator.MakeDoubleTimeSeries(time.Duration(info.cpuLimit).Seconds(), s.startTime, s.now(), labelValues),
-			},
-		})
+		builder.AddMetric(cpuLimitDesc).AddDoublePoint(time.Duration(info.cpuLimit).Seconds(), s.startTime, s.now(), attributes)
 	}
-	return metrics
 }
@@ -1,268 +1,111 @@
 package dockerstats
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"testing"
 	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 
 	"github.com/stretchr/testify/assert"
-	"go.opentelemetry.io/collector/consumer"
-	"go.opentelemetry.io/collector/model/pdata"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
-	mpb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/opencensus"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/internal/dockertest"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
-type fakeDocker struct {
-	client.Client
-}
+// fakeDockerFixtures is the directory the fake docker daemon serves its responses from.
+const fakeDockerFixtures = "testdata/docker"
 
-func (d *fakeDocker) ContainerList(ctx context.Context, opts types.ContainerListOptions) ([]types.Container, error) {
-	return []types.Container{
-		{
-			ID:    "id1",
-			Names: []string{"name1a", "name1b"},
-		},
-		{
-			ID:    "id2",
-			Names: []string{},
-		},
-		{
-			ID:    "id3",
-			Names: []string{"name3"},
-		},
-	}, nil
+func fakeNow() time.Time {
+	t, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+	return t
 }
 
-func (d *fakeDocker) ContainerStats(ctx context.Context, id string, stream bool) (types.ContainerStats, error) {
-	s1 := types.StatsJSON{
-		Stats: types.Stats{
-			CPUStats: types.CPUStats{
-				CPUUsage: types.CPUUsage{
-					TotalUsage: 100000000,
-				},
-			},
-			MemoryStats: types.MemoryStats{
-				Usage: 33,
-				Limit: 66,
-			},
-		},
-		Networks: map[string]types.NetworkStats{
-			"eth0": {
-				RxBytes: 111,
-				TxBytes: 222,
-			},
-		},
-	}
-	s2 := types.StatsJSON{
-		Stats: types.Stats{
-			CPUStats: types.CPUStats{
-				CPUUsage: types.CPUUsage{
-					TotalUsage: 200000000,
-				},
-			},
-			MemoryStats: types.MemoryStats{
-				Usage: 44,
-				Limit: 88,
-			},
-		},
-		Networks: map[string]types.NetworkStats{
-			"eth0": {
-				RxBytes: 333,
-				TxBytes: 444,
-			},
-			"eth1": {
-				RxBytes: 222,
-				TxBytes: 333,
-			},
-		},
-	}
-	s3 := types.StatsJSON{}
-
-	var stats types.StatsJSON
-	var err error
-	switch id {
-	case "id1":
-		stats = s1
-	case "id2":
-		stats = s2
-	case "id3":
-		stats = s3
-		err = fmt.Errorf("manual failure")
-	}
-
-	b, err2 := json.Marshal(stats)
-	if err2 != nil {
-		return types.ContainerStats{}, fmt.Errorf("failed to marshal JSON: %v", err2)
+func newTestScraper(docker client.ContainerAPIClient, sink *metricstest.Sink) *scraper {
+	return &scraper{
+		startTime:      fakeNow(),
+		metricConsumer: sink,
+		docker:         docker,
+		now:            fakeNow,
+		logger:         zap.NewNop(),
 	}
-
-	return types.ContainerStats{
-		Body: ioutil.NopCloser(bytes.NewReader(b)),
-	}, err
 }
 
-func (d *fakeDocker) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
-	var c types.ContainerJSON
-	var err error
-
-	switch id {
-	case "id1":
-		c = types.ContainerJSON{
-			ContainerJSONBase: &types.ContainerJSONBase{
-				RestartCount: 3,
-				State: &types.ContainerState{
-					StartedAt: "2019-12-31T12:00:00.000000000Z",
-				},
-				HostConfig: &container.HostConfig{},
-			},
-		}
-	case "id2":
-		c = types.ContainerJSON{
-			ContainerJSONBase: &types.ContainerJSONBase{
-				RestartCount: 5,
-				State: &types.ContainerState{
-					StartedAt: "2019-12-31T00:00:00.000000000Z",
-				},
-				HostConfig: &container.HostConfig{
-					Resources: container.Resources{
-						NanoCPUs: 500000000,
-					},
-				},
-			},
-		}
-	case "id3":
-		c = types.ContainerJSON{}
-		err = fmt.Errorf("manual error")
-	}
+func TestScraperExport(t *testing.T) {
+	daemon := dockertest.NewDaemon(t, fakeDockerFixtures, "1.41")
+	sink := &metricstest.Sink{}
+	s := newTestScraper(daemon.NewClient(), sink)
 
-	return c, err
-}
+	s.export(context.Background())
 
-// fakeMetricConsumer extends consumer.MetricsConsumer.
-type fakeMetricsConsumer struct {
-	metrics pdata.Metrics
+	assert.NoError(t, metricRegistry.Validate(sink.Last()))
+	// id3 has no stats nor inspect fixture, so the daemon answers with a 404 and it is not exported.
+	metricstest.AssertMetricsGolden(t, "testdata/scraper_export.golden", sink.Last())
 }
 
-func (c *fakeMetricsConsumer) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{
-		MutatesData: false,
-	}
-}
+func TestScraperExportSkipsFailedStats(t *testing.T) {
+	daemon := dockertest.NewDaemon(t, fakeDockerFixtures, "1.41")
+	daemon.Fail("/containers/id1/stats", 500)
+	daemon.Respond("/containers/id2/stats", 200, "{")
+	sink := &metricstest.Sink{}
+	s := newTestScraper(daemon.NewClient(), sink)
 
-func (c *fakeMetricsConsumer) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
-	c.metrics = md
-	return nil
-}
+	s.export(context.Background())
 
-func fakeNow() time.Time {
-	t, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
-	return t
+	// The container info is still exported when the usage stats fail or are malformed.
+	metricstest.AssertMetricsGolden(t, "testdata/scraper_export_failed_stats.golden", sink.Last())
 }
 
-func TestScraperExport(t *testing.T) {
-	c := &fakeMetricsConsumer{}
-	s := &scraper{
-		startTime:      fakeNow(),
-		metricConsumer: c,
-		docker:         &fakeDocker{},
-		scrapeInterval: 10 * time.Second,
-		now:            fakeNow,
-		logger:         zap.NewNop(),
-	}
+func TestScraperExportTimeout(t *testing.T) {
+	daemon := dockertest.NewDaemon(t, fakeDockerFixtures, "1.41")
+	daemon.SetLatency(time.Second)
+	sink := &metricstest.Sink{}
+	s := newTestScraper(daemon.NewClient(), sink)
 
-	s.export()
-
-	_, _, data := opencensus.ResourceMetricsToOC(c.metrics.ResourceMetrics().At(0))
-	verifyContainerMetricDoubleValue(t, data, "container/cpu/usage_time", "name1a", 0.1)
-	verifyContainerMetricAbsent(t, data, "container/cpu/limit", "name1a")
-	verifyContainerMetricInt64Value(t, data, "container/memory/usage", "name1a", 33)
-	verifyContainerMetricInt64Value(t, data, "container/memory/limit", "name1a", 66)
-	verifyContainerMetricInt64Value(t, data, "container/network/received_bytes_count", "name1a", 111)
-	verifyContainerMetricInt64Value(t, data, "container/network/sent_bytes_count", "name1a", 222)
-	verifyContainerMetricInt64Value(t, data, "container/uptime", "name1a", 43200)
-	verifyContainerMetricInt64Value(t, data, "container/restart_count", "name1a", 3)
-	verifyContainerMetricDoubleValue(t, data, "container/cpu/usage_time", "id2", 0.2)
-	verifyContainerMetricDoubleValue(t, data, "container/cpu/limit", "id2", 0.5)
-	verifyContainerMetricInt64Value(t, data, "container/memory/usage", "id2", 44)
-	verifyContainerMetricInt64Value(t, data, "container/memory/limit", "id2", 88)
-	verifyContainerMetricInt64Value(t, data, "container/network/received_bytes_count", "id2", 555)
-	verifyContainerMetricInt64Value(t, data, "container/network/sent_bytes_count", "id2", 777)
-	verifyContainerMetricInt64Value(t, data, "container/uptime", "id2", 86400)
-	verifyContainerMetricInt64Value(t, data, "container/restart_count", "id2", 5)
-	verifyContainerMetricAbsent(t, data, "container/cpu/usage_time", "name3")
-	verifyContainerMetricAbsent(t, data, "container/cpu/limit", "name3")
-	verifyContainerMetricAbsent(t, data, "container/memory/usage", "name3")
-	verifyContainerMetricAbsent(t, data, "container/memory/limit", "name3")
-	verifyContainerMetricAbsent(t, data, "container/network/received_bytes_count", "name3")
-	verifyContainerMetricAbsent(t, data, "container/network/sent_bytes_count", "name3")
-	verifyContainerMetricAbsent(t, data, "container/uptime", "name3")
-	verifyContainerMetricAbsent(t, data, "container/restart_count", "name3")
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	s.export(ctx)
 
-func verifyContainerMetricInt64Value(t *testing.T, data []*mpb.Metric, name, label string, value int64) {
-	var metric *mpb.Metric
-	for _, m := range data {
-		if m.MetricDescriptor.Name == name && m.Timeseries[0].LabelValues[0].Value == label {
-			metric = m
-		}
-	}
-	if metric == nil {
-		t.Errorf("Unable to find metric %q", name)
-		return
-	}
-	assert.Equal(t, value, metric.Timeseries[0].Points[0].GetInt64Value())
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Empty(t, sink.AllMetrics())
 }
 
-func verifyContainerMetricDoubleValue(t *testing.T, data []*mpb.Metric, name, label string, value float64) {
-	var metric *mpb.Metric
-	for _, m := range data {
-		if m.MetricDescriptor.Name == name && m.Timeseries[0].LabelValues[0].Value == label {
-			metric = m
-		}
-	}
-	if metric == nil {
-		t.Errorf("Unable to find metric %q", name)
-		return
-	}
-	assert.Equal(t, value, metric.Timeseries[0].Points[0].GetDoubleValue())
-}
+func TestNewScraperFromEnv(t *testing.T) {
+	daemon := dockertest.NewDaemon(t, fakeDockerFixtures, "1.41")
+	t.Setenv("DOCKER_HOST", daemon.Host())
+	t.Setenv("DOCKER_API_VERSION", "")
+	t.Setenv("DOCKER_CERT_PATH", "")
+	t.Setenv("DOCKER_TLS_VERIFY", "")
 
-func verifyContainerMetricAbsent(t *testing.T, data []*mpb.Metric, name, label string) {
-	for _, m := range data {
-		if m.MetricDescriptor.Name == name && m.Timeseries[0].LabelValues[0].Value == label {
-			t.Errorf("Expected metric %s{container_name=%s} to be absent, found metric: %v", name, label, m)
-			break
-		}
-	}
-}
+	sink := &metricstest.Sink{}
+	s, err := newScraper(scrapeloop.Config{Interval: time.Minute}, sink, zap.NewNop())
+	require.NoError(t, err)
+	s.startTime = fakeNow()
+	s.now = fakeNow
 
-type alwaysFailDocker struct {
-	client.Client
-}
+	s.export(context.Background())
 
-func (d *alwaysFailDocker) ContainerList(_ context.Context, _ types.ContainerListOptions) ([]types.Container, error) {
-	return []types.Container{}, fmt.Errorf("always fail")
+	assert.Contains(t, daemon.ReceivedRequests(), "/v1.41/containers/json")
+	metricstest.AssertMetricsGolden(t, "testdata/scraper_export.golden", sink.Last())
 }
 
 func TestScraperContinuesOnError(t *testing.T) {
+	daemon := dockertest.NewDaemon(t, fakeDockerFixtures, "1.41")
+	daemon.Fail("/containers/json", 500)
 	s := &scraper{
-		now:            fakeNow,
-		docker:         &alwaysFailDocker{},
-		scrapeInterval: 1 * time.Second,
-		done:           make(chan bool),
-		logger:         zap.NewNop(),
+		now:    fakeNow,
+		docker: daemon.NewClient(),
+		logger: zap.NewNop(),
 	}
+	var err error
+	s.controller, err = scrapeloop.NewController(scrapeloop.Config{Interval: 10 * time.Millisecond}, s.scrape)
+	require.NoError(t, err)
 	s.start()
-	time.Sleep(6 * time.Second)
-	s.stop()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, s.stop(context.Background()))
 	assert.GreaterOrEqual(t, s.scrapeCount, uint64(5))
 }
@@ -0,0 +1,26 @@
+package hoststatsreceiver
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/config"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
+)
+
+// Config defines the configuration for the host stats receiver.
+type Config struct {
+	config.ReceiverSettings `mapstructure:",squash"`
+	scrapeloop.Settings     `mapstructure:",squash"`
+	ExportInterval          time.Duration `mapstructure:"export_interval"`
+	// ProcRoot is where the proc filesystem of the host is mounted.
+	ProcRoot string `mapstructure:"proc_root"`
+	// SysRoot is where the sys filesystem of the host is mounted. It tells the disks apart
+	// from their partitions, and the virtual network interfaces from the physical ones.
+	SysRoot string `mapstructure:"sys_root"`
+	// RootPath is where the root filesystem of the host is mounted. The MountPoints are
+	// read under it, and are labelled as seen from the host.
+	RootPath string `mapstructure:"root_path"`
+	// MountPoints are the filesystems the usage metrics are generated for.
+	MountPoints []string `mapstructure:"mount_points"`
+}
@@ -0,0 +1,4 @@
+// Package hoststatsreceiver reads the host CPU, memory, disk, network and
+// filesystem stats from /proc and statfs and generates metrics based on them.
+// It is a metric receiver designed to work with OpenTelemetry Collector.
+package hoststatsreceiver
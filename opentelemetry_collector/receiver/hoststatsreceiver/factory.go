@@ -0,0 +1,55 @@
+package hoststatsreceiver
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+const (
+	typeStr = "hoststats"
+)
+
+// CreateDefaultConfig creates the default configuration for the receiver.
+func createDefaultConfig() config.Receiver {
+	return &Config{
+		ReceiverSettings: config.NewReceiverSettings(config.NewComponentID(typeStr)),
+		ExportInterval:   time.Minute,
+		ProcRoot:         defaultProcRoot,
+		SysRoot:          defaultSysRoot,
+		MountPoints:      []string{"/"},
+	}
+}
+
+// CreateMetricsReceiver creates a metrics receiver based on the provided config.
+func createMetricsReceiver(
+	ctx context.Context,
+	params component.ReceiverCreateSettings,
+	config config.Receiver,
+	consumer consumer.Metrics,
+) (component.MetricsReceiver, error) {
+
+	cfg := config.(*Config)
+	collector, err := NewHostStatsCollector(cfg, params.Logger, consumer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	receiver := &Receiver{
+		hostStatsCollector: collector,
+	}
+
+	return receiver, nil
+}
+
+// NewFactory creates and returns a factory for the host stats receiver.
+func NewFactory() component.ReceiverFactory {
+	return component.NewReceiverFactory(
+		typeStr,
+		createDefaultConfig,
+		component.WithMetricsReceiver(createMetricsReceiver))
+}
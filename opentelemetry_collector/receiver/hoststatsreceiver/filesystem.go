@@ -0,0 +1,9 @@
+package hoststatsreceiver
+
+// filesystemUsage is the space of a filesystem in bytes.
+type filesystemUsage struct {
+	used int64
+	free int64
+	// reserved is the free space only available to root.
+	reserved int64
+}
@@ -0,0 +1,20 @@
+//go:build linux
+// +build linux
+
+package hoststatsreceiver
+
+import "syscall"
+
+// statFilesystem reads the usage of the filesystem mounted at path with statfs.
+func statFilesystem(path string) (filesystemUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return filesystemUsage{}, err
+	}
+	blockSize := int64(stat.Bsize)
+	return filesystemUsage{
+		used:     int64(stat.Blocks-stat.Bfree) * blockSize,
+		free:     int64(stat.Bavail) * blockSize,
+		reserved: int64(stat.Bfree-stat.Bavail) * blockSize,
+	}, nil
+}
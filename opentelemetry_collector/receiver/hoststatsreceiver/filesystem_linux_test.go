@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package hoststatsreceiver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatFilesystem(t *testing.T) {
+	usage, err := statFilesystem(t.TempDir())
+	require.NoError(t, err)
+	assert.Greater(t, usage.used+usage.free, int64(0))
+	assert.GreaterOrEqual(t, usage.reserved, int64(0))
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package hoststatsreceiver
+
+import "errors"
+
+// statFilesystem is only implemented on Linux, the only platform the collector runs on.
+func statFilesystem(path string) (filesystemUsage, error) {
+	return filesystemUsage{}, errors.New("filesystem stats are only supported on Linux")
+}
@@ -0,0 +1,229 @@
+package hoststatsreceiver
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
+)
+
+// HostStatsCollector is a struct that generates metrics from the host stats in /proc
+// and the usage of the filesystems at the configured mount points.
+type HostStatsCollector struct {
+	consumer consumer.Metrics
+
+	now        func() time.Time
+	startTime  time.Time
+	controller *scrapeloop.Controller
+	logger     *zap.Logger
+
+	procRoot    string
+	sysRoot     string
+	rootPath    string
+	mountPoints []string
+	statfs      func(path string) (filesystemUsage, error)
+}
+
+// memoryStates are the memory states in the order they are exported in.
+var memoryStates = []string{"used", "free", "buffered", "cached", "slab"}
+
+// NewHostStatsCollector creates a new HostStatsCollector that generates metrics
+// based on the host stats found under the proc root in the config.
+func NewHostStatsCollector(cfg *Config, logger *zap.Logger, consumer consumer.Metrics) (*HostStatsCollector, error) {
+	if cfg.ExportInterval <= 0 {
+		return nil, errors.New("ExportInterval must be greater than 0")
+	}
+
+	procRoot := cfg.ProcRoot
+	if procRoot == "" {
+		procRoot = defaultProcRoot
+	}
+	sysRoot := cfg.SysRoot
+	if sysRoot == "" {
+		sysRoot = defaultSysRoot
+	}
+
+	collector := &HostStatsCollector{
+		consumer:    consumer,
+		now:         time.Now,
+		logger:      logger,
+		procRoot:    procRoot,
+		sysRoot:     sysRoot,
+		rootPath:    cfg.RootPath,
+		mountPoints: cfg.MountPoints,
+		statfs:      statFilesystem,
+	}
+
+	controller, err := scrapeloop.NewController(scrapeloop.Config{Interval: cfg.ExportInterval, Settings: cfg.Settings}, collector.scrapeAndExport)
+	if err != nil {
+		return nil, err
+	}
+	collector.controller = controller
+	return collector, nil
+}
+
+// StartCollection starts a go routine that reads the host stats right away and then
+// periodically, and exports metrics based on them.
+func (collector *HostStatsCollector) StartCollection() {
+	collector.startTime = collector.now()
+	collector.controller.Start()
+}
+
+// StopCollection stops the reading of the host stats and the export of the metrics,
+// and waits for an export in progress to finish or for ctx to be done.
+func (collector *HostStatsCollector) StopCollection(ctx context.Context) error {
+	return collector.controller.Shutdown(ctx)
+}
+
+func (collector *HostStatsCollector) addCPUMetrics(builder *metricgenerator.MetricsBuilder, now time.Time) error {
+	times, err := readCPUTimes(collector.procRoot)
+	if err != nil {
+		return err
+	}
+	metric := builder.AddMetric(cpuUsageTimeMetric)
+	for _, state := range cpuStates {
+		seconds, ok := times[state]
+		if !ok {
+			continue
+		}
+		metric.AddDoublePoint(seconds, collector.startTime, now, map[string]string{cpuStateLabel.Key: state})
+	}
+	return nil
+}
+
+func (collector *HostStatsCollector) addMemoryMetrics(builder *metricgenerator.MetricsBuilder, now time.Time) error {
+	usage, err := readMemoryUsage(collector.procRoot)
+	if err != nil {
+		return err
+	}
+	metric := builder.AddMetric(memoryUsageMetric)
+	for _, state := range memoryStates {
+		metric.AddInt64Point(usage[state], collector.startTime, now, map[string]string{memoryStateLabel.Key: state})
+	}
+	return nil
+}
+
+func (collector *HostStatsCollector) addDiskMetrics(builder *metricgenerator.MetricsBuilder, now time.Time) error {
+	stats, err := readDiskStats(collector.procRoot, collector.sysRoot)
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	readBytes := builder.AddMetric(diskReadBytesMetric)
+	writeBytes := builder.AddMetric(diskWriteBytesMetric)
+	readOps := builder.AddMetric(diskReadOpsMetric)
+	writeOps := builder.AddMetric(diskWriteOpsMetric)
+	ioTime := builder.AddMetric(diskIOTimeMetric)
+	for _, disk := range stats {
+		attributes := map[string]string{deviceLabel.Key: disk.device}
+		readBytes.AddInt64Point(disk.readBytes, collector.startTime, now, attributes)
+		writeBytes.AddInt64Point(disk.writeBytes, collector.startTime, now, attributes)
+		readOps.AddInt64Point(disk.readOps, collector.startTime, now, attributes)
+		writeOps.AddInt64Point(disk.writeOps, collector.startTime, now, attributes)
+		ioTime.AddDoublePoint(disk.ioTime.Seconds(), collector.startTime, now, attributes)
+	}
+	return nil
+}
+
+func (collector *HostStatsCollector) addNetworkMetrics(builder *metricgenerator.MetricsBuilder, now time.Time) error {
+	stats, err := readNetDevStats(collector.procRoot, collector.sysRoot)
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	receivedBytes := builder.AddMetric(networkReceivedBytesMetric)
+	sentBytes := builder.AddMetric(networkSentBytesMetric)
+	receivedPkts := builder.AddMetric(networkReceivedPacketsMetric)
+	sentPkts := builder.AddMetric(networkSentPacketsMetric)
+	receiveErrors := builder.AddMetric(networkReceiveErrorsMetric)
+	sendErrors := builder.AddMetric(networkSendErrorsMetric)
+	for _, iface := range stats {
+		attributes := map[string]string{interfaceLabel.Key: iface.iface}
+		receivedBytes.AddInt64Point(iface.receivedBytes, collector.startTime, now, attributes)
+		sentBytes.AddInt64Point(iface.sentBytes, collector.startTime, now, attributes)
+		receivedPkts.AddInt64Point(iface.receivedPkts, collector.startTime, now, attributes)
+		sentPkts.AddInt64Point(iface.sentPkts, collector.startTime, now, attributes)
+		receiveErrors.AddInt64Point(iface.receiveErrors, collector.startTime, now, attributes)
+		sendErrors.AddInt64Point(iface.sendErrors, collector.startTime, now, attributes)
+	}
+	return nil
+}
+
+// addFilesystemMetrics generates the usage metric for each mount point that can be read,
+// logging the ones that can't.
+func (collector *HostStatsCollector) addFilesystemMetrics(builder *metricgenerator.MetricsBuilder, now time.Time) {
+	type mountPointUsage struct {
+		mountPoint string
+		usage      filesystemUsage
+	}
+	var usages []mountPointUsage
+	for _, mountPoint := range collector.mountPoints {
+		usage, err := collector.statfs(filepath.Join(collector.rootPath, mountPoint))
+		if err != nil {
+			collector.logger.Warn("Error reading the filesystem usage", zap.String("mount_point", mountPoint), zap.Error(err))
+			continue
+		}
+		usages = append(usages, mountPointUsage{mountPoint, usage})
+	}
+	if len(usages) == 0 {
+		return
+	}
+
+	metric := builder.AddMetric(filesystemUsageMetric)
+	for _, mountPointUsage := range usages {
+		usage := mountPointUsage.usage
+		for _, state := range []struct {
+			name  string
+			bytes int64
+		}{{"used", usage.used}, {"free", usage.free}, {"reserved", usage.reserved}} {
+			attributes := map[string]string{mountPointLabel.Key: mountPointUsage.mountPoint, filesystemStateLabel.Key: state.name}
+			metric.AddInt64Point(state.bytes, collector.startTime, now, attributes)
+		}
+	}
+}
+
+func (collector *HostStatsCollector) makeMetrics() *metricgenerator.MetricsBuilder {
+	now := collector.now()
+	builder := metricgenerator.NewMetricsBuilder()
+
+	readers := []struct {
+		name string
+		add  func(builder *metricgenerator.MetricsBuilder, now time.Time) error
+	}{
+		{"cpu", collector.addCPUMetrics},
+		{"memory", collector.addMemoryMetrics},
+		{"disk", collector.addDiskMetrics},
+		{"network", collector.addNetworkMetrics},
+	}
+	for _, reader := range readers {
+		if err := reader.add(builder, now); err != nil {
+			collector.logger.Warn("Error reading the host stats", zap.String("stats", reader.name), zap.Error(err))
+		}
+	}
+	collector.addFilesystemMetrics(builder, now)
+	return builder
+}
+
+func (collector *HostStatsCollector) scrapeAndExport(ctx context.Context) {
+	builder := collector.makeMetrics()
+	if builder.Len() == 0 {
+		return
+	}
+	if err := metricRegistry.Validate(builder.Metrics()); err != nil {
+		collector.logger.Error("Generated metrics do not match their descriptors", zap.Error(err))
+	}
+	err := collector.consumer.ConsumeMetrics(ctx, builder.Metrics())
+	if err != nil {
+		collector.logger.Error("Error sending host stats metrics", zap.Error(err))
+	}
+}
@@ -0,0 +1,73 @@
+package hoststatsreceiver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+func fakeNow() time.Time {
+	return time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+}
+
+func fakeStatfs(path string) (filesystemUsage, error) {
+	if path != "/" {
+		return filesystemUsage{}, errors.New("no such file or directory")
+	}
+	return filesystemUsage{used: 6000, free: 3000, reserved: 1000}, nil
+}
+
+func newTestCollector(t *testing.T, procRoot string, consumer consumer.Metrics) *HostStatsCollector {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	cfg.ProcRoot = procRoot
+	cfg.SysRoot = testSysRoot
+	cfg.MountPoints = []string{"/", "/missing"}
+	collector, err := NewHostStatsCollector(cfg, zap.NewNop(), consumer)
+	require.NoError(t, err)
+	collector.now = fakeNow
+	collector.startTime = fakeNow()
+	collector.statfs = fakeStatfs
+	return collector
+}
+
+func TestScrapeAndExport(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(t, testProcRoot, sink)
+	collector.scrapeAndExport(context.Background())
+
+	assert.NoError(t, metricRegistry.Validate(sink.Last()))
+	metricstest.AssertMetricsGolden(t, "testdata/scrape_and_export.golden", sink.Last())
+}
+
+func TestScrapeAndExportMissingProcRoot(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(t, "testdata/missing", sink)
+	collector.scrapeAndExport(context.Background())
+
+	// Only the filesystem usage, which doesn't come from /proc, is exported.
+	metricstest.AssertMetricsGolden(t, "testdata/scrape_and_export_missing_proc_root.golden", sink.Last())
+}
+
+func TestScrapeAndExportRootPath(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(t, "testdata/missing", sink)
+	collector.rootPath = "/host/root"
+	var paths []string
+	collector.statfs = func(path string) (filesystemUsage, error) {
+		paths = append(paths, path)
+		return fakeStatfs("/")
+	}
+	collector.scrapeAndExport(context.Background())
+
+	// The mount points are read under the host root, but labelled as seen from the host.
+	assert.Equal(t, []string{"/host/root", "/host/root/missing"}, paths)
+	metricstest.AssertMetricsGolden(t, "testdata/scrape_and_export_root_path.golden", sink.Last())
+}
@@ -0,0 +1,150 @@
+package hoststatsreceiver
+
+import (
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+)
+
+// metricRegistry holds the descriptors of the metrics generated by the host stats receiver.
+var metricRegistry = metricgenerator.NewRegistry()
+
+var cpuStateLabel = metricgenerator.LabelKey{
+	Key:         "state",
+	Description: "The CPU state: user, nice, system, idle, iowait, irq, softirq or steal",
+}
+
+var cpuUsageTimeMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/cpu/usage_time",
+	Description: "Total CPU time spent in each state by all the CPUs of the host",
+	Unit:        "s",
+	Type:        metricgenerator.CumulativeDouble,
+	LabelKeys:   []metricgenerator.LabelKey{cpuStateLabel},
+})
+
+var memoryStateLabel = metricgenerator.LabelKey{
+	Key:         "state",
+	Description: "The memory state: used, free, buffered, cached or slab",
+}
+
+var memoryUsageMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/memory/usage",
+	Description: "Memory of the host in each state",
+	Unit:        "By",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{memoryStateLabel},
+})
+
+var deviceLabel = metricgenerator.LabelKey{
+	Key:         "device",
+	Description: "The name of the block device",
+}
+
+var diskReadBytesMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/disk/read_bytes_count",
+	Description: "Bytes read from the block device",
+	Unit:        "By",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{deviceLabel},
+})
+
+var diskWriteBytesMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/disk/write_bytes_count",
+	Description: "Bytes written to the block device",
+	Unit:        "By",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{deviceLabel},
+})
+
+var diskReadOpsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/disk/read_ops_count",
+	Description: "Reads completed on the block device",
+	Unit:        "1",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{deviceLabel},
+})
+
+var diskWriteOpsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/disk/write_ops_count",
+	Description: "Writes completed on the block device",
+	Unit:        "1",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{deviceLabel},
+})
+
+var diskIOTimeMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/disk/io_time",
+	Description: "Time the block device spent doing I/O",
+	Unit:        "s",
+	Type:        metricgenerator.CumulativeDouble,
+	LabelKeys:   []metricgenerator.LabelKey{deviceLabel},
+})
+
+var interfaceLabel = metricgenerator.LabelKey{
+	Key:         "interface",
+	Description: "The name of the network interface",
+}
+
+var networkReceivedBytesMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/network/received_bytes_count",
+	Description: "Bytes received on the network interface",
+	Unit:        "By",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{interfaceLabel},
+})
+
+var networkSentBytesMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/network/sent_bytes_count",
+	Description: "Bytes sent on the network interface",
+	Unit:        "By",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{interfaceLabel},
+})
+
+var networkReceivedPacketsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/network/received_packets_count",
+	Description: "Packets received on the network interface",
+	Unit:        "1",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{interfaceLabel},
+})
+
+var networkSentPacketsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/network/sent_packets_count",
+	Description: "Packets sent on the network interface",
+	Unit:        "1",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{interfaceLabel},
+})
+
+var networkReceiveErrorsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/network/receive_errors_count",
+	Description: "Errors receiving on the network interface",
+	Unit:        "1",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{interfaceLabel},
+})
+
+var networkSendErrorsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/network/send_errors_count",
+	Description: "Errors sending on the network interface",
+	Unit:        "1",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{interfaceLabel},
+})
+
+var mountPointLabel = metricgenerator.LabelKey{
+	Key:         "mount_point",
+	Description: "The mount point of the filesystem",
+}
+
+var filesystemStateLabel = metricgenerator.LabelKey{
+	Key:         "state",
+	Description: "The filesystem space state: used, free or reserved",
+}
+
+var filesystemUsageMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "host/filesystem/usage",
+	Description: "Space of the filesystem in each state",
+	Unit:        "By",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{mountPointLabel, filesystemStateLabel},
+})
@@ -0,0 +1,33 @@
+package hoststatsreceiver
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// Receiver is the type that provides Receiver functionality for the host stats metrics.
+type Receiver struct {
+	hostStatsCollector *HostStatsCollector
+
+	stopOnce  sync.Once
+	startOnce sync.Once
+}
+
+// Start starts the underlying host metrics generator.
+func (receiver *Receiver) Start(ctx context.Context, host component.Host) error {
+	receiver.startOnce.Do(func() {
+		receiver.hostStatsCollector.StartCollection()
+	})
+	return nil
+}
+
+// Shutdown stops and cancels the underlying host metrics generator.
+func (receiver *Receiver) Shutdown(ctx context.Context) error {
+	var err error
+	receiver.stopOnce.Do(func() {
+		err = receiver.hostStatsCollector.StopCollection(ctx)
+	})
+	return err
+}
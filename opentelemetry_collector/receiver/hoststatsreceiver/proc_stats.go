@@ -0,0 +1,244 @@
+package hoststatsreceiver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultProcRoot = "/proc"
+	defaultSysRoot  = "/sys"
+)
+
+// clockTicksPerSecond is the unit of the CPU times in /proc/stat, USER_HZ,
+// which is 100 on every architecture Linux runs on.
+const clockTicksPerSecond = 100
+
+// sectorSize is the unit of the sector counts in /proc/diskstats, which is
+// always 512 bytes regardless of the sector size of the device.
+const sectorSize = 512
+
+// cpuStates are the CPU states in the order of the cpu line of /proc/stat.
+// The guest times that follow them are already included in user and nice.
+var cpuStates = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+
+// diskStats are the stats of a block device read from /proc/diskstats.
+type diskStats struct {
+	device     string
+	readOps    int64
+	readBytes  int64
+	writeOps   int64
+	writeBytes int64
+	ioTime     time.Duration
+}
+
+// netDevStats are the stats of a network interface read from /proc/net/dev.
+type netDevStats struct {
+	iface         string
+	receivedBytes int64
+	receivedPkts  int64
+	receiveErrors int64
+	sentBytes     int64
+	sentPkts      int64
+	sendErrors    int64
+}
+
+// readLines calls parse for each line of the file at path, stopping at the first error.
+func readLines(path string, parse func(line string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := parse(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func parseCounters(name string, values []string) ([]int64, error) {
+	counters := make([]int64, len(values))
+	for i, value := range values {
+		counter, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s counter %q: %v", name, value, err)
+		}
+		counters[i] = counter
+	}
+	return counters, nil
+}
+
+// readCPUTimes reads the time all the CPUs spent in each of cpuStates from the cpu line of /proc/stat.
+func readCPUTimes(procRoot string) (map[string]float64, error) {
+	var times map[string]float64
+	err := readLines(filepath.Join(procRoot, "stat"), func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "cpu" || times != nil {
+			return nil
+		}
+		// Kernels older than 2.6.11 don't report steal time.
+		values := fields[1:]
+		if len(values) > len(cpuStates) {
+			values = values[:len(cpuStates)]
+		}
+		counters, err := parseCounters("cpu", values)
+		if err != nil {
+			return err
+		}
+		times = make(map[string]float64, len(counters))
+		for i, ticks := range counters {
+			times[cpuStates[i]] = float64(ticks) / clockTicksPerSecond
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if times == nil {
+		return nil, errors.New("cpu not found in stat")
+	}
+	return times, nil
+}
+
+// readMemoryUsage reads the amount of memory in each memory state from /proc/meminfo.
+// Used memory is what is left of the total after the other states.
+func readMemoryUsage(procRoot string) (map[string]int64, error) {
+	info := make(map[string]int64)
+	err := readLines(filepath.Join(procRoot, "meminfo"), func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil
+		}
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid meminfo value %q: %v", line, err)
+		}
+		if len(fields) == 3 && fields[2] == "kB" {
+			value *= 1024
+		}
+		info[strings.TrimSuffix(fields[0], ":")] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"MemTotal", "MemFree", "Buffers", "Cached", "Slab"} {
+		if _, ok := info[key]; !ok {
+			return nil, fmt.Errorf("%s not found in meminfo", key)
+		}
+	}
+	usage := map[string]int64{
+		"free":     info["MemFree"],
+		"buffered": info["Buffers"],
+		"cached":   info["Cached"],
+		"slab":     info["Slab"],
+	}
+	usage["used"] = info["MemTotal"] - usage["free"] - usage["buffered"] - usage["cached"] - usage["slab"]
+	return usage, nil
+}
+
+// isVirtualDevice returns true for the loop and ram block devices, which don't
+// do any disk I/O.
+func isVirtualDevice(device string) bool {
+	return strings.HasPrefix(device, "loop") || strings.HasPrefix(device, "ram")
+}
+
+// readDisks returns the names of the disks listed in /sys/block, which unlike /proc/diskstats
+// does not list their partitions. It returns nil if /sys/block can not be read.
+func readDisks(sysRoot string) map[string]bool {
+	entries, err := ioutil.ReadDir(filepath.Join(sysRoot, "block"))
+	if err != nil {
+		return nil
+	}
+	disks := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		disks[entry.Name()] = true
+	}
+	return disks
+}
+
+// readDiskStats reads the stats of the disks from /proc/diskstats. The partitions are skipped
+// when the disks can be read from /sys/block, so that their I/O is not counted twice.
+func readDiskStats(procRoot, sysRoot string) ([]diskStats, error) {
+	disks := readDisks(sysRoot)
+	var stats []diskStats
+	err := readLines(filepath.Join(procRoot, "diskstats"), func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 14 || isVirtualDevice(fields[2]) || (disks != nil && !disks[fields[2]]) {
+			return nil
+		}
+		counters, err := parseCounters(fields[2], fields[3:14])
+		if err != nil {
+			return err
+		}
+		stats = append(stats, diskStats{
+			device:     fields[2],
+			readOps:    counters[0],
+			readBytes:  counters[2] * sectorSize,
+			writeOps:   counters[4],
+			writeBytes: counters[6] * sectorSize,
+			ioTime:     time.Duration(counters[9]) * time.Millisecond,
+		})
+		return nil
+	})
+	return stats, err
+}
+
+// isVirtualInterface returns true for the network interfaces that are not backed by a device,
+// like the docker bridge and the veth pairs of the containers, whose traffic also goes through
+// a physical interface. /sys/class/net links each interface to its device.
+func isVirtualInterface(sysRoot, iface string) bool {
+	target, err := os.Readlink(filepath.Join(sysRoot, "class", "net", iface))
+	return err == nil && strings.Contains(target, "/devices/virtual/")
+}
+
+// readNetDevStats reads the stats of the network interfaces, except loopback and the virtual
+// interfaces, from /proc/net/dev.
+func readNetDevStats(procRoot, sysRoot string) ([]netDevStats, error) {
+	var stats []netDevStats
+	// /proc/net is the network namespace of the process reading it, which is the one of the
+	// container the collector runs in. The one of the first process is the host's when the
+	// host proc filesystem is mounted.
+	err := readLines(filepath.Join(procRoot, "1", "net", "dev"), func(line string) error {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			// One of the two header lines.
+			return nil
+		}
+		iface := strings.TrimSpace(line[:colon])
+		if iface == "lo" || isVirtualInterface(sysRoot, iface) {
+			return nil
+		}
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) < 16 {
+			return fmt.Errorf("expected 16 counters for %s, got %d", iface, len(fields))
+		}
+		counters, err := parseCounters(iface, fields[:16])
+		if err != nil {
+			return err
+		}
+		stats = append(stats, netDevStats{
+			iface:         iface,
+			receivedBytes: counters[0],
+			receivedPkts:  counters[1],
+			receiveErrors: counters[2],
+			sentBytes:     counters[8],
+			sentPkts:      counters[9],
+			sendErrors:    counters[10],
+		})
+		return nil
+	})
+	return stats, err
+}
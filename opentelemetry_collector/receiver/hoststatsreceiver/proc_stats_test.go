@@ -0,0 +1,121 @@
+package hoststatsreceiver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testProcRoot = "testdata/proc"
+	testSysRoot  = "testdata/sys"
+)
+
+func TestReadCPUTimes(t *testing.T) {
+	times, err := readCPUTimes(testProcRoot)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"user":    100,
+		"nice":    2,
+		"system":  30,
+		"idle":    5000,
+		"iowait":  4,
+		"irq":     0,
+		"softirq": 1,
+		"steal":   0.5,
+	}, times)
+}
+
+func TestReadCPUTimesMissing(t *testing.T) {
+	procRoot := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(procRoot, "stat"), []byte("btime 1167645600\n"), 0644))
+	_, err := readCPUTimes(procRoot)
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(procRoot, "stat"), []byte("cpu 1 2 x\n"), 0644))
+	_, err = readCPUTimes(procRoot)
+	assert.Error(t, err)
+}
+
+func TestReadMemoryUsage(t *testing.T) {
+	usage, err := readMemoryUsage(testProcRoot)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{
+		"used":     1500000 * 1024,
+		"free":     1000000 * 1024,
+		"buffered": 100000 * 1024,
+		"cached":   1200000 * 1024,
+		"slab":     200000 * 1024,
+	}, usage)
+}
+
+func TestReadMemoryUsageMissingField(t *testing.T) {
+	procRoot := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(procRoot, "meminfo"), []byte("MemTotal: 4000000 kB\n"), 0644))
+	_, err := readMemoryUsage(procRoot)
+	assert.Error(t, err)
+}
+
+var testDiskStatsSDA = diskStats{
+	device:     "sda",
+	readOps:    12000,
+	readBytes:  480000 * 512,
+	writeOps:   8000,
+	writeBytes: 320000 * 512,
+	ioTime:     21 * time.Second,
+}
+
+func TestReadDiskStats(t *testing.T) {
+	// The partition sda1 is not listed in /sys/block.
+	stats, err := readDiskStats(testProcRoot, testSysRoot)
+	require.NoError(t, err)
+	assert.Equal(t, []diskStats{testDiskStatsSDA}, stats)
+}
+
+func TestReadDiskStatsMissingSysRoot(t *testing.T) {
+	stats, err := readDiskStats(testProcRoot, filepath.Join(t.TempDir(), "sys"))
+	require.NoError(t, err)
+	assert.Equal(t, []diskStats{
+		testDiskStatsSDA,
+		{
+			device:     "sda1",
+			readOps:    11000,
+			readBytes:  470000 * 512,
+			writeOps:   8000,
+			writeBytes: 320000 * 512,
+			ioTime:     20 * time.Second,
+		},
+	}, stats)
+}
+
+func TestReadNetDevStats(t *testing.T) {
+	// docker0 is a virtual interface, and is skipped.
+	stats, err := readNetDevStats(testProcRoot, testSysRoot)
+	require.NoError(t, err)
+	assert.Equal(t, []netDevStats{{
+		iface:         "eth0",
+		receivedBytes: 98765432,
+		receivedPkts:  65432,
+		receiveErrors: 3,
+		sentBytes:     12345678,
+		sentPkts:      43210,
+		sendErrors:    1,
+	}}, stats)
+}
+
+func TestReadMissingProcRoot(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "proc")
+	_, err := readCPUTimes(missing)
+	assert.True(t, os.IsNotExist(err))
+	_, err = readMemoryUsage(missing)
+	assert.True(t, os.IsNotExist(err))
+	_, err = readDiskStats(missing, testSysRoot)
+	assert.True(t, os.IsNotExist(err))
+	_, err = readNetDevStats(missing, testSysRoot)
+	assert.True(t, os.IsNotExist(err))
+}
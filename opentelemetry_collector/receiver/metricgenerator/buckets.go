@@ -0,0 +1,267 @@
+package metricgenerator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// BucketLayout defines the buckets of a distribution. Bucket 0 holds the values below the
+// first bound, bucket i the values between bounds[i-1] and bounds[i], and the last bucket the
+// values above the last bound. ExplicitBuckets and LogLinearBuckets put a value equal to a bound
+// in the bucket above it, like MakeBuckets, and ExponentialBuckets in the bucket below it, like
+// OpenTelemetry exponential histograms.
+type BucketLayout interface {
+	// Bounds returns the upper boundaries of the buckets, except for the last bucket which
+	// has +infinity as an implied upper bound.
+	Bounds() []float64
+	// Index returns the index of the bucket holding val.
+	Index(val float64) int
+}
+
+// ExplicitBuckets is a BucketLayout with arbitrary increasing bounds. Index finds the
+// bucket with a binary search.
+type ExplicitBuckets []float64
+
+// Bounds returns the bounds of the buckets.
+func (b ExplicitBuckets) Bounds() []float64 {
+	return b
+}
+
+// Index returns the index of the bucket holding val.
+func (b ExplicitBuckets) Index(val float64) int {
+	return getBucketIndex(val, b)
+}
+
+// adjustIndex corrects index, an estimate of the bucket holding val off by at most one
+// because of rounding errors, using the bounds of the buckets.
+func adjustIndex(val float64, bounds []float64, index int) int {
+	if !(val >= bounds[0]) {
+		// Also catches NaN.
+		return 0
+	}
+	if index < 1 {
+		index = 1
+	}
+	if index > len(bounds) {
+		index = len(bounds)
+	}
+	if index > 1 && val < bounds[index-1] {
+		index--
+	}
+	if index < len(bounds) && val >= bounds[index] {
+		index++
+	}
+	return index
+}
+
+const (
+	// minExponentialScale and maxExponentialScale are the scales of OpenTelemetry exponential histograms.
+	minExponentialScale = -10
+	maxExponentialScale = 20
+	// maxBucketCount limits the size of the generated layouts.
+	maxBucketCount = 16384
+)
+
+// ExponentialBuckets is the BucketLayout of an OpenTelemetry exponential histogram with
+// the base 2^(2^-scale). It has count buckets from base^offset up to base^(offset+count), plus
+// the buckets below and above them. Like in OpenTelemetry, bucket i holds the values in
+// (base^(offset+i-1), base^(offset+i)], so a value equal to a bound is in the bucket below it.
+// Index finds the bucket in constant time.
+type ExponentialBuckets struct {
+	scale  int32
+	offset int32
+	bounds []float64
+}
+
+// exponentialBound returns base^index for the base of scale. The exponent is a dyadic
+// rational computed exactly, so the bounds of different scales match exactly where they coincide.
+func exponentialBound(scale, index int32) float64 {
+	return math.Exp2(math.Ldexp(float64(index), -int(scale)))
+}
+
+// NewExponentialBuckets creates the ExponentialBuckets with the given scale and offset and count buckets.
+func NewExponentialBuckets(scale, offset int32, count int) (*ExponentialBuckets, error) {
+	if scale < minExponentialScale || scale > maxExponentialScale {
+		return nil, fmt.Errorf("scale %d must be between %d and %d", scale, minExponentialScale, maxExponentialScale)
+	}
+	if count < 1 || count > maxBucketCount {
+		return nil, fmt.Errorf("bucket count %d must be between 1 and %d", count, maxBucketCount)
+	}
+
+	bounds := make([]float64, count+1)
+	for i := range bounds {
+		bounds[i] = exponentialBound(scale, offset+int32(i))
+	}
+	if bounds[0] == 0 || math.IsInf(bounds[count], 1) {
+		return nil, fmt.Errorf("bounds with scale %d, offset %d and count %d are out of the float64 range", scale, offset, count)
+	}
+	return &ExponentialBuckets{scale: scale, offset: offset, bounds: bounds}, nil
+}
+
+// Scale returns the scale of the buckets.
+func (b *ExponentialBuckets) Scale() int32 {
+	return b.scale
+}
+
+// Offset returns the exponent of the first bound.
+func (b *ExponentialBuckets) Offset() int32 {
+	return b.offset
+}
+
+// Bounds returns the bounds of the buckets.
+func (b *ExponentialBuckets) Bounds() []float64 {
+	return b.bounds
+}
+
+// Index returns the index of the bucket holding val, which is the number of bounds lower than val.
+func (b *ExponentialBuckets) Index(val float64) int {
+	last := len(b.bounds) - 1
+	if !(val > b.bounds[0]) {
+		// Also catches NaN.
+		return 0
+	}
+	if val > b.bounds[last] {
+		return len(b.bounds)
+	}
+	// base^(index+offset-1) < val <= base^(index+offset), up to the rounding errors of Log2,
+	// which are corrected with the bounds.
+	index := int(math.Ceil(math.Ldexp(math.Log2(val), int(b.scale)))) - int(b.offset)
+	if index < 1 {
+		index = 1
+	}
+	if index > last {
+		index = last
+	}
+	if index > 1 && val <= b.bounds[index-1] {
+		index--
+	}
+	if val > b.bounds[index] {
+		index++
+	}
+	return index
+}
+
+// LogLinearBuckets is a BucketLayout that splits each power of base from base^minExponent
+// up to base^maxExponent in subBuckets buckets of equal width, which keeps the relative
+// error of the buckets bounded like exponential buckets with round bounds.
+// Index finds the bucket in constant time.
+type LogLinearBuckets struct {
+	base        float64
+	minExponent int
+	maxExponent int
+	subBuckets  int
+	bounds      []float64
+}
+
+// NewLogLinearBuckets creates the LogLinearBuckets from base^minExponent to base^maxExponent
+// with subBuckets buckets for each power of base.
+func NewLogLinearBuckets(base float64, minExponent, maxExponent, subBuckets int) (*LogLinearBuckets, error) {
+	if !(base > 1) || math.IsInf(base, 1) {
+		return nil, errors.New("base must be greater than 1")
+	}
+	if maxExponent <= minExponent {
+		return nil, errors.New("maxExponent must be greater than minExponent")
+	}
+	if subBuckets < 1 {
+		return nil, errors.New("subBuckets must be at least 1")
+	}
+	if (maxExponent-minExponent)*subBuckets > maxBucketCount {
+		return nil, fmt.Errorf("the layout must not have more than %d buckets", maxBucketCount)
+	}
+
+	bounds := make([]float64, 0, (maxExponent-minExponent)*subBuckets+1)
+	for exponent := minExponent; exponent < maxExponent; exponent++ {
+		power := math.Pow(base, float64(exponent))
+		width := power * (base - 1) / float64(subBuckets)
+		for i := 0; i < subBuckets; i++ {
+			bounds = append(bounds, power+float64(i)*width)
+		}
+	}
+	bounds = append(bounds, math.Pow(base, float64(maxExponent)))
+	if bounds[0] == 0 || math.IsInf(bounds[len(bounds)-1], 1) {
+		return nil, errors.New("bounds are out of the float64 range")
+	}
+
+	return &LogLinearBuckets{
+		base:        base,
+		minExponent: minExponent,
+		maxExponent: maxExponent,
+		subBuckets:  subBuckets,
+		bounds:      bounds,
+	}, nil
+}
+
+// Bounds returns the bounds of the buckets.
+func (b *LogLinearBuckets) Bounds() []float64 {
+	return b.bounds
+}
+
+// Index returns the index of the bucket holding val.
+func (b *LogLinearBuckets) Index(val float64) int {
+	if !(val >= b.bounds[0]) {
+		return 0
+	}
+	if val >= b.bounds[len(b.bounds)-1] {
+		return len(b.bounds)
+	}
+	exponent := int(math.Floor(math.Log(val) / math.Log(b.base)))
+	if exponent < b.minExponent {
+		exponent = b.minExponent
+	}
+	if exponent >= b.maxExponent {
+		exponent = b.maxExponent - 1
+	}
+	power := math.Pow(b.base, float64(exponent))
+	sub := int((val/power - 1) * float64(b.subBuckets) / (b.base - 1))
+	if sub < 0 {
+		sub = 0
+	}
+	if sub >= b.subBuckets {
+		sub = b.subBuckets - 1
+	}
+	return adjustIndex(val, b.bounds, (exponent-b.minExponent)*b.subBuckets+sub+1)
+}
+
+// Rebucket returns the distribution with its bucket counts moved onto bounds with
+// RebucketDistribution. The count, sum and sum of squared deviation are unchanged.
+func (d DistributionValue) Rebucket(bounds []float64) DistributionValue {
+	if equalBounds(d.Bounds, bounds) {
+		return d.copy()
+	}
+	rebucketed := d
+	rebucketed.Bounds = append([]float64(nil), bounds...)
+	rebucketed.Buckets = RebucketDistribution(d.Buckets, d.Bounds, bounds)
+	return rebucketed
+}
+
+// commonBounds returns the bounds that are in both a and b, which are increasing.
+func commonBounds(a, b []float64) []float64 {
+	var common []float64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			common = append(common, a[i])
+			i++
+			j++
+		}
+	}
+	return common
+}
+
+// MergeDistributions returns the distribution of the values of both a and b. If their
+// bucket bounds differ, both are rebucketed onto the bounds they have in common, so that
+// every bucket count stays exact. Exponential buckets of different scales have the bounds
+// of the smaller scale in common.
+func MergeDistributions(a, b DistributionValue) DistributionValue {
+	if !equalBounds(a.Bounds, b.Bounds) {
+		bounds := commonBounds(a.Bounds, b.Bounds)
+		a = a.Rebucket(bounds)
+		b = b.Rebucket(bounds)
+	}
+	return mergeDistributions(a, b)
+}
@@ -0,0 +1,172 @@
+package metricgenerator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// linearBucketIndex finds the bucket of val by walking the bounds, to check the faster indexing against.
+func linearBucketIndex(val float64, bounds []float64) int {
+	index := 0
+	for _, bound := range bounds {
+		if val >= bound {
+			index++
+		}
+	}
+	return index
+}
+
+// linearBucketIndexBelow is like linearBucketIndex for the layouts that put the values
+// equal to a bound in the bucket below it.
+func linearBucketIndexBelow(val float64, bounds []float64) int {
+	index := 0
+	for _, bound := range bounds {
+		if val > bound {
+			index++
+		}
+	}
+	return index
+}
+
+// assertIndexes checks the Index of layout for the bounds, the values next to them and
+// the values in between, against the linear search index of its bounds.
+func assertIndexes(t *testing.T, layout BucketLayout, index func(val float64, bounds []float64) int) {
+	bounds := layout.Bounds()
+	values := []float64{math.Inf(-1), -1, 0, math.Inf(1)}
+	for i, bound := range bounds {
+		values = append(values, bound, math.Nextafter(bound, math.Inf(-1)), math.Nextafter(bound, math.Inf(1)))
+		if i > 0 {
+			values = append(values, (bounds[i-1]+bound)/2)
+		}
+	}
+	values = append(values, bounds[len(bounds)-1]*2)
+
+	for _, val := range values {
+		assert.Equal(t, index(val, bounds), layout.Index(val), "index of %v", val)
+	}
+	assert.Equal(t, 0, layout.Index(math.NaN()))
+}
+
+func Test_GetBucketIndexWithoutBounds(t *testing.T) {
+	assert.Equal(t, 0, getBucketIndex(3, nil))
+}
+
+func Test_ExplicitBuckets(t *testing.T) {
+	layout := ExplicitBuckets{1, 2, 4, 100}
+	assert.Equal(t, []float64{1, 2, 4, 100}, layout.Bounds())
+	for _, val := range []float64{-1, 0, 1, 1.5, 2, 3, 4, 99, 100, 1000} {
+		assert.Equal(t, linearBucketIndex(val, layout), layout.Index(val), "index of %v", val)
+	}
+}
+
+func Test_NewExponentialBuckets(t *testing.T) {
+	layout, err := NewExponentialBuckets(0, 0, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2, 4, 8, 16}, layout.Bounds())
+	assert.Equal(t, int32(0), layout.Scale())
+	assert.Equal(t, int32(0), layout.Offset())
+
+	layout, err = NewExponentialBuckets(1, -2, 4)
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{0.5, 1 / math.Sqrt2, 1, math.Sqrt2, 2}, layout.Bounds(), 1e-15)
+
+	layout, err = NewExponentialBuckets(-1, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{4, 16, 64}, layout.Bounds())
+}
+
+func Test_NewExponentialBucketsErrors(t *testing.T) {
+	_, err := NewExponentialBuckets(21, 0, 4)
+	assert.Error(t, err)
+	_, err = NewExponentialBuckets(0, 0, 0)
+	assert.Error(t, err)
+	_, err = NewExponentialBuckets(0, 1020, 10)
+	assert.Error(t, err)
+}
+
+func Test_ExponentialBucketsIndex(t *testing.T) {
+	for _, scale := range []int32{-2, 0, 1, 3, 8} {
+		layout, err := NewExponentialBuckets(scale, -20, 100)
+		require.NoError(t, err)
+		assertIndexes(t, layout, linearBucketIndexBelow)
+	}
+}
+
+func Test_ExponentialBucketsIndexOnBound(t *testing.T) {
+	layout, err := NewExponentialBuckets(0, 0, 4)
+	require.NoError(t, err)
+
+	// The buckets are (-inf, 1], (1, 2], (2, 4], (4, 8], (8, 16] and (16, +inf).
+	assert.Equal(t, 0, layout.Index(1))
+	assert.Equal(t, 1, layout.Index(math.Nextafter(1, 2)))
+	assert.Equal(t, 1, layout.Index(2))
+	assert.Equal(t, 2, layout.Index(math.Nextafter(2, 4)))
+	assert.Equal(t, 4, layout.Index(16))
+	assert.Equal(t, 5, layout.Index(math.Nextafter(16, 32)))
+}
+
+func Test_NewLogLinearBuckets(t *testing.T) {
+	layout, err := NewLogLinearBuckets(10, 0, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 4, 7, 10, 40, 70, 100}, layout.Bounds())
+}
+
+func Test_NewLogLinearBucketsErrors(t *testing.T) {
+	_, err := NewLogLinearBuckets(1, 0, 2, 3)
+	assert.Error(t, err)
+	_, err = NewLogLinearBuckets(10, 2, 2, 3)
+	assert.Error(t, err)
+	_, err = NewLogLinearBuckets(10, 0, 2, 0)
+	assert.Error(t, err)
+	_, err = NewLogLinearBuckets(10, 300, 310, 1)
+	assert.Error(t, err)
+}
+
+func Test_LogLinearBucketsIndex(t *testing.T) {
+	for _, subBuckets := range []int{1, 3, 9, 90} {
+		layout, err := NewLogLinearBuckets(10, -3, 6, subBuckets)
+		require.NoError(t, err)
+		assertIndexes(t, layout, linearBucketIndex)
+	}
+	layout, err := NewLogLinearBuckets(2, -5, 5, 4)
+	require.NoError(t, err)
+	assertIndexes(t, layout, linearBucketIndex)
+}
+
+func Test_DistributionValueRebucket(t *testing.T) {
+	d := DistributionValue{Count: 6, Sum: 20, SumOfSquaredDeviation: 3, Bounds: []float64{1, 2, 4, 8}, Buckets: []int64{1, 1, 1, 1, 2}}
+	rebucketed := d.Rebucket([]float64{2, 8})
+	assert.Equal(t, DistributionValue{Count: 6, Sum: 20, SumOfSquaredDeviation: 3, Bounds: []float64{2, 8}, Buckets: []int64{2, 2, 2}}, rebucketed)
+	// The original distribution is unchanged.
+	assert.Equal(t, []int64{1, 1, 1, 1, 2}, d.Buckets)
+}
+
+func Test_MergeDistributionsWithDifferentBounds(t *testing.T) {
+	a := DistributionValue{Count: 2, Sum: 4, SumOfSquaredDeviation: 2, Bounds: []float64{1, 2, 4, 8}, Buckets: []int64{0, 0, 1, 1, 0}}
+	b := DistributionValue{Count: 3, Sum: 9, SumOfSquaredDeviation: 2, Bounds: []float64{2, 3, 8}, Buckets: []int64{0, 1, 2, 0}}
+
+	merged := MergeDistributions(a, b)
+	assert.Equal(t, int64(5), merged.Count)
+	assert.Equal(t, 13.0, merged.Sum)
+	// The values of a have the mean 2 and b the mean 3, so 2 + 2 + 1 * 2 * 3 / 5.
+	assert.InDelta(t, 5.2, merged.SumOfSquaredDeviation, 1e-9)
+	assert.Equal(t, []float64{2, 8}, merged.Bounds)
+	assert.Equal(t, []int64{0, 5, 0}, merged.Buckets)
+}
+
+func Test_MergeDistributionsWithExponentialScales(t *testing.T) {
+	fine, err := NewExponentialBuckets(1, 0, 4)
+	require.NoError(t, err)
+	coarse, err := NewExponentialBuckets(0, 0, 2)
+	require.NoError(t, err)
+
+	a := DistributionValue{Count: 4, Sum: 6, Bounds: fine.Bounds(), Buckets: []int64{0, 1, 1, 1, 1, 0}}
+	b := DistributionValue{Count: 2, Sum: 5, Bounds: coarse.Bounds(), Buckets: []int64{0, 1, 1, 0}}
+
+	merged := MergeDistributions(a, b)
+	assert.Equal(t, coarse.Bounds(), merged.Bounds)
+	assert.Equal(t, []int64{0, 3, 3, 0}, merged.Buckets)
+}
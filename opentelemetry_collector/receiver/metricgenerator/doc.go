@@ -1,4 +1,6 @@
 // Package metricgenerator provides utility functions intended to be used by
 // metric receivers the generate metrics rather taking in existing metrics from
-// an external source.
+// an external source. Metrics are built as pdata with a MetricsBuilder, and
+// sources producing deltas can be turned into cumulative series with a
+// DeltaToCumulative, or the other way around with a CumulativeToDelta.
 package metricgenerator
@@ -2,6 +2,8 @@ package metricgenerator
 
 import (
 	"math"
+	"math/big"
+	"sort"
 	"time"
 
 	timestamp "google.golang.org/protobuf/types/known/timestamppb"
@@ -10,6 +12,8 @@ import (
 )
 
 // MakeInt64TimeSeries generates a proto representation of a timeseries containing a single point for an int64 metric.
+//
+// Deprecated: generate pdata metrics with a MetricsBuilder instead.
 func MakeInt64TimeSeries(val int64, startTime, now time.Time, labels []*metricspb.LabelValue) *metricspb.TimeSeries {
 	return &metricspb.TimeSeries{
 		StartTimestamp: timestamp.New(startTime),
@@ -19,6 +23,8 @@ func MakeInt64TimeSeries(val int64, startTime, now time.Time, labels []*metricsp
 }
 
 // MakeDoubleTimeSeries generates a proto representation of a timeseries containing a single point for an double metric.
+//
+// Deprecated: generate pdata metrics with a MetricsBuilder instead.
 func MakeDoubleTimeSeries(val float64, startTime, now time.Time, labels []*metricspb.LabelValue) *metricspb.TimeSeries {
 	return &metricspb.TimeSeries{
 		StartTimestamp: timestamp.New(startTime),
@@ -75,16 +81,33 @@ func MakeBuckets(values, bounds []float64) []*metricspb.DistributionValue_Bucket
 	return buckets
 }
 
+// getBucketIndex returns the index of the bucket holding val, which is the number of
+// bounds lower than or equal to val, found with a binary search.
 func getBucketIndex(val float64, bounds []float64) int {
-	if val < bounds[0] {
-		return 0
+	return sort.Search(len(bounds), func(i int) bool { return bounds[i] > val })
+}
+
+// RebucketDistribution moves the bucket counts of a distribution with the bucket bounds
+// fromBounds onto the bucket bounds toBounds. The count of each source bucket is added to
+// the target bucket containing the source bucket's lower bound, so the result is exact
+// when toBounds is a subset of fromBounds.
+func RebucketDistribution(distribution []int64, fromBounds, toBounds []float64) []int64 {
+	rebucketed := make([]int64, len(toBounds)+1)
+	if len(toBounds) == 0 {
+		for _, count := range distribution {
+			rebucketed[0] += count
+		}
+		return rebucketed
 	}
-	for i, lowerBound := range bounds {
-		if i < len(bounds)-1 && val >= lowerBound && val < bounds[i+1] {
-			return i + 1
+
+	for i, count := range distribution {
+		lowerBound := math.Inf(-1)
+		if i > 0 && i <= len(fromBounds) {
+			lowerBound = fromBounds[i-1]
 		}
+		rebucketed[getBucketIndex(lowerBound, toBounds)] += count
 	}
-	return len(bounds)
+	return rebucketed
 }
 
 func formatBuckets(distribution []int64) []*metricspb.DistributionValue_Bucket {
@@ -99,6 +122,8 @@ func formatBuckets(distribution []int64) []*metricspb.DistributionValue_Bucket {
 }
 
 // MakeLabelValue generates a proto representation of a metric label with value as its value.
+//
+// Deprecated: generate pdata metrics with a MetricsBuilder instead.
 func MakeLabelValue(value string) *metricspb.LabelValue {
 	return &metricspb.LabelValue{
 		Value:    value,
@@ -109,6 +134,8 @@ func MakeLabelValue(value string) *metricspb.LabelValue {
 // MakeSingleValueDistributionTimeSeries generates a proto representation of a timeseries
 // containing a single point consisting of a distribution containing a single value.
 // The distribution bucket bounds are defined by the bucketOptions argument.
+//
+// Deprecated: generate pdata metrics with a MetricsBuilder instead.
 func MakeSingleValueDistributionTimeSeries(
 	val float64,
 	startTime, currentTime time.Time,
@@ -129,17 +156,23 @@ func MakeSingleValueDistributionTimeSeries(
 
 // GetSumOfSquaredDeviationsFromIntDist calculates the sum of squared deviations from the mean.
 // For values x_i this is:     Sum[i=1..n]((x_i - mean)^2)
-// Calculated from the count, sum, and sum of squares of the values.
+// Calculated from the count, sum, and sum of squares of the values. The intermediate
+// count * sumSquares - sum^2 is computed exactly with big integers, since it overflows int64
+// long before the counters do. The result is negative if the inputs are inconsistent.
 func GetSumOfSquaredDeviationsFromIntDist(sum, sumSquares, count int64) float64 {
 	if count <= 0 {
 		return 0
 	}
 
-	diff := count*sumSquares - sum*sum
-	return float64(diff) / float64(count)
+	diff := new(big.Int).Mul(big.NewInt(count), big.NewInt(sumSquares))
+	diff.Sub(diff, new(big.Int).Mul(big.NewInt(sum), big.NewInt(sum)))
+	deviation, _ := new(big.Float).Quo(new(big.Float).SetInt(diff), new(big.Float).SetInt64(count)).Float64()
+	return deviation
 }
 
 // MakeDistributionTimeSeries formats a distribution and its metadata as a TimeSeries.
+//
+// Deprecated: generate pdata metrics with a MetricsBuilder instead.
 func MakeDistributionTimeSeries(
 	distribution []int64,
 	sum float64,
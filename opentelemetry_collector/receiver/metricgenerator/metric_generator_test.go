@@ -176,6 +176,24 @@ func Test_FormatBucketOptions(t *testing.T) {
 	assert.Equal(t, expectedBucketOptions, bucketOptions)
 }
 
+func Test_RebucketDistribution(t *testing.T) {
+	distribution := []int64{1, 2, 3, 4, 5}
+	rebucketed := RebucketDistribution(distribution, []float64{1, 2, 4, 8}, []float64{2, 8})
+	assert.Equal(t, []int64{3, 7, 5}, rebucketed)
+}
+
+func Test_RebucketDistributionNonSubsetBounds(t *testing.T) {
+	distribution := []int64{1, 2, 3}
+	rebucketed := RebucketDistribution(distribution, []float64{2, 4}, []float64{3})
+	assert.Equal(t, []int64{3, 3}, rebucketed)
+}
+
+func Test_RebucketDistributionNoTargetBounds(t *testing.T) {
+	distribution := []int64{1, 2, 3}
+	rebucketed := RebucketDistribution(distribution, []float64{2, 4}, nil)
+	assert.Equal(t, []int64{6}, rebucketed)
+}
+
 func Test_FormatBuckets(t *testing.T) {
 	buckets := formatBuckets([]int64{1, 4, 3})
 	expectedBuckets := []*metricspb.DistributionValue_Bucket{
@@ -200,3 +218,14 @@ func Test_GetSumOfSquaredDeviationsFromIntFraction(t *testing.T) {
 	deviation := GetSumOfSquaredDeviationsFromIntDist(5, 13, 2)
 	assert.Equal(t, 0.5, deviation)
 }
+
+func Test_GetSumOfSquaredDeviationsFromIntLargeValues(t *testing.T) {
+	// count * sumSquares is 2e24, far beyond the int64 range.
+	deviation := GetSumOfSquaredDeviationsFromIntDist(1e12, 2e18, 1e6)
+	assert.Equal(t, 1e18, deviation)
+}
+
+func Test_GetSumOfSquaredDeviationsFromIntInconsistent(t *testing.T) {
+	deviation := GetSumOfSquaredDeviationsFromIntDist(10, 1, 2)
+	assert.Less(t, deviation, 0.0)
+}
@@ -0,0 +1,159 @@
+package metricgenerator
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// MetricType is the kind of data a metric has, named after the OpenCensus metric types
+// the receivers used to generate.
+type MetricType int
+
+const (
+	// GaugeInt64 is a gauge with int64 points.
+	GaugeInt64 MetricType = iota
+	// GaugeDouble is a gauge with double points.
+	GaugeDouble
+	// CumulativeInt64 is a monotonic cumulative sum with int64 points.
+	CumulativeInt64
+	// CumulativeDouble is a monotonic cumulative sum with double points.
+	CumulativeDouble
+	// CumulativeDistribution is a cumulative histogram with explicit bucket bounds.
+	CumulativeDistribution
+)
+
+// LabelKey describes an attribute of the data points of a metric.
+type LabelKey struct {
+	Key         string
+	Description string
+}
+
+// MetricDescriptor describes a metric generated with a MetricsBuilder.
+type MetricDescriptor struct {
+	Name        string
+	Description string
+	Unit        string
+	Type        MetricType
+	// LabelKeys are the attributes of the data points, in the order they are set in.
+	LabelKeys []LabelKey
+}
+
+// MetricsBuilder builds pdata.Metrics directly. It generates the same metrics as converting
+// the OpenCensus protos made by the time series helpers with opencensus.OCToMetrics, without
+// the intermediate protos.
+type MetricsBuilder struct {
+	metrics pdata.Metrics
+	slice   pdata.MetricSlice
+}
+
+// NewMetricsBuilder creates an empty MetricsBuilder.
+func NewMetricsBuilder() *MetricsBuilder {
+	return &MetricsBuilder{metrics: pdata.NewMetrics()}
+}
+
+// Metrics returns the metrics built so far.
+func (builder *MetricsBuilder) Metrics() pdata.Metrics {
+	return builder.metrics
+}
+
+// Len returns the number of metrics built so far.
+func (builder *MetricsBuilder) Len() int {
+	if builder.metrics.ResourceMetrics().Len() == 0 {
+		return 0
+	}
+	return builder.slice.Len()
+}
+
+// AddMetric adds an empty metric described by descriptor, to add data points to.
+func (builder *MetricsBuilder) AddMetric(descriptor *MetricDescriptor) Metric {
+	if builder.metrics.ResourceMetrics().Len() == 0 {
+		resourceMetrics := builder.metrics.ResourceMetrics().AppendEmpty()
+		builder.slice = resourceMetrics.InstrumentationLibraryMetrics().AppendEmpty().Metrics()
+	}
+
+	metric := builder.slice.AppendEmpty()
+	metric.SetName(descriptor.Name)
+	metric.SetDescription(descriptor.Description)
+	metric.SetUnit(descriptor.Unit)
+	switch descriptor.Type {
+	case GaugeInt64, GaugeDouble:
+		metric.SetDataType(pdata.MetricDataTypeGauge)
+	case CumulativeInt64, CumulativeDouble:
+		metric.SetDataType(pdata.MetricDataTypeSum)
+		metric.Sum().SetIsMonotonic(true)
+		metric.Sum().SetAggregationTemporality(pdata.MetricAggregationTemporalityCumulative)
+	case CumulativeDistribution:
+		metric.SetDataType(pdata.MetricDataTypeHistogram)
+		metric.Histogram().SetAggregationTemporality(pdata.MetricAggregationTemporalityCumulative)
+	}
+	return Metric{descriptor: descriptor, metric: metric}
+}
+
+// Metric is a metric being built by a MetricsBuilder.
+type Metric struct {
+	descriptor *MetricDescriptor
+	metric     pdata.Metric
+}
+
+func (m Metric) numberDataPoints() pdata.NumberDataPointSlice {
+	if m.metric.DataType() == pdata.MetricDataTypeSum {
+		return m.metric.Sum().DataPoints()
+	}
+	return m.metric.Gauge().DataPoints()
+}
+
+// setAttributes sets the attributes of a data point to the values of the label keys of the
+// metric in attributes, in the order of the label keys. Label keys without a value are left unset.
+func (m Metric) setAttributes(dest pdata.AttributeMap, attributes map[string]string) {
+	for _, labelKey := range m.descriptor.LabelKeys {
+		if value, ok := attributes[labelKey.Key]; ok {
+			dest.InsertString(labelKey.Key, value)
+		}
+	}
+}
+
+// AddInt64Point adds a data point with an int64 value to a gauge or cumulative metric.
+func (m Metric) AddInt64Point(val int64, startTime, now time.Time, attributes map[string]string) {
+	point := m.numberDataPoints().AppendEmpty()
+	point.SetStartTimestamp(pdata.NewTimestampFromTime(startTime))
+	point.SetTimestamp(pdata.NewTimestampFromTime(now))
+	m.setAttributes(point.Attributes(), attributes)
+	point.SetIntVal(val)
+}
+
+// AddDoublePoint adds a data point with a double value to a gauge or cumulative metric.
+func (m Metric) AddDoublePoint(val float64, startTime, now time.Time, attributes map[string]string) {
+	point := m.numberDataPoints().AppendEmpty()
+	point.SetStartTimestamp(pdata.NewTimestampFromTime(startTime))
+	point.SetTimestamp(pdata.NewTimestampFromTime(now))
+	m.setAttributes(point.Attributes(), attributes)
+	point.SetDoubleVal(val)
+}
+
+// AddDistributionPoint adds a histogram data point to a distribution metric. distribution
+// has the count of each of the buckets defined by bounds, which lists the upper boundaries of
+// the buckets except for the last bucket which has +infinity as an implied upper bound.
+func (m Metric) AddDistributionPoint(
+	distribution []int64,
+	bounds []float64,
+	sum float64,
+	count int64,
+	startTime, now time.Time,
+	attributes map[string]string) {
+
+	point := m.metric.Histogram().DataPoints().AppendEmpty()
+	point.SetStartTimestamp(pdata.NewTimestampFromTime(startTime))
+	point.SetTimestamp(pdata.NewTimestampFromTime(now))
+	m.setAttributes(point.Attributes(), attributes)
+	point.SetSum(sum)
+	point.SetCount(uint64(count))
+	if len(distribution) > 0 {
+		bucketCounts := make([]uint64, len(distribution))
+		for i, bucketCount := range distribution {
+			bucketCounts[i] = uint64(bucketCount)
+		}
+		point.SetBucketCounts(bucketCounts)
+	}
+	point.SetExplicitBounds(bounds)
+}
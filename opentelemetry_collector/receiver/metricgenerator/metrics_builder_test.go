@@ -0,0 +1,163 @@
+package metricgenerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/opencensus"
+)
+
+var (
+	testStartTime = time.Unix(1541015015, 123456789)
+	testNow       = time.Unix(1541015075, 123456789)
+)
+
+// ocDescriptor makes the OpenCensus equivalent of descriptor.
+func ocDescriptor(descriptor *MetricDescriptor) *metricspb.MetricDescriptor {
+	types := map[MetricType]metricspb.MetricDescriptor_Type{
+		GaugeInt64:             metricspb.MetricDescriptor_GAUGE_INT64,
+		GaugeDouble:            metricspb.MetricDescriptor_GAUGE_DOUBLE,
+		CumulativeInt64:        metricspb.MetricDescriptor_CUMULATIVE_INT64,
+		CumulativeDouble:       metricspb.MetricDescriptor_CUMULATIVE_DOUBLE,
+		CumulativeDistribution: metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION,
+	}
+	labelKeys := make([]*metricspb.LabelKey, len(descriptor.LabelKeys))
+	for i, labelKey := range descriptor.LabelKeys {
+		labelKeys[i] = &metricspb.LabelKey{Key: labelKey.Key, Description: labelKey.Description}
+	}
+	return &metricspb.MetricDescriptor{
+		Name:        descriptor.Name,
+		Description: descriptor.Description,
+		Unit:        descriptor.Unit,
+		Type:        types[descriptor.Type],
+		LabelKeys:   labelKeys,
+	}
+}
+
+func assertEquivalent(t *testing.T, expected []*metricspb.Metric, builder *MetricsBuilder) {
+	assert.Equal(t, len(expected), builder.Len())
+	assert.Equal(t, opencensus.OCToMetrics(nil, nil, expected), builder.Metrics())
+}
+
+var (
+	testStateLabel  = LabelKey{Key: "state", Description: "The state"}
+	testDeviceLabel = LabelKey{Key: "device", Description: "The device"}
+)
+
+func TestMetricsBuilderEmpty(t *testing.T) {
+	assertEquivalent(t, nil, NewMetricsBuilder())
+}
+
+func TestMetricsBuilderGauges(t *testing.T) {
+	intGauge := &MetricDescriptor{
+		Name:        "memory",
+		Description: "Memory in each state",
+		Unit:        "bytes",
+		Type:        GaugeInt64,
+		LabelKeys:   []LabelKey{testDeviceLabel, testStateLabel},
+	}
+	doubleGauge := &MetricDescriptor{
+		Name:        "age",
+		Description: "The age",
+		Unit:        "Days",
+		Type:        GaugeDouble,
+	}
+
+	builder := NewMetricsBuilder()
+	metric := builder.AddMetric(intGauge)
+	// The attributes are set in the order of the label keys regardless of the map order.
+	metric.AddInt64Point(10, testStartTime, testNow, map[string]string{"state": "used", "device": "sda"})
+	metric.AddInt64Point(20, testStartTime, testNow, map[string]string{"state": "free", "device": "sda"})
+	builder.AddMetric(doubleGauge).AddDoublePoint(1.5, testStartTime, testNow, nil)
+
+	assertEquivalent(t, []*metricspb.Metric{
+		{
+			MetricDescriptor: ocDescriptor(intGauge),
+			Timeseries: []*metricspb.TimeSeries{
+				MakeInt64TimeSeries(10, testStartTime, testNow, []*metricspb.LabelValue{MakeLabelValue("sda"), MakeLabelValue("used")}),
+				MakeInt64TimeSeries(20, testStartTime, testNow, []*metricspb.LabelValue{MakeLabelValue("sda"), MakeLabelValue("free")}),
+			},
+		},
+		{
+			MetricDescriptor: ocDescriptor(doubleGauge),
+			Timeseries:       []*metricspb.TimeSeries{MakeDoubleTimeSeries(1.5, testStartTime, testNow, []*metricspb.LabelValue{})},
+		},
+	}, builder)
+}
+
+func TestMetricsBuilderSums(t *testing.T) {
+	intSum := &MetricDescriptor{
+		Name:      "read_bytes_count",
+		Unit:      "bytes",
+		Type:      CumulativeInt64,
+		LabelKeys: []LabelKey{testDeviceLabel},
+	}
+	doubleSum := &MetricDescriptor{
+		Name:      "usage_time",
+		Unit:      "seconds",
+		Type:      CumulativeDouble,
+		LabelKeys: []LabelKey{testStateLabel},
+	}
+
+	builder := NewMetricsBuilder()
+	builder.AddMetric(intSum).AddInt64Point(4096, testStartTime, testNow, map[string]string{"device": "sda"})
+	builder.AddMetric(doubleSum).AddDoublePoint(12.5, testStartTime, testNow, map[string]string{"state": "idle"})
+
+	assertEquivalent(t, []*metricspb.Metric{
+		{
+			MetricDescriptor: ocDescriptor(intSum),
+			Timeseries:       []*metricspb.TimeSeries{MakeInt64TimeSeries(4096, testStartTime, testNow, []*metricspb.LabelValue{MakeLabelValue("sda")})},
+		},
+		{
+			MetricDescriptor: ocDescriptor(doubleSum),
+			Timeseries:       []*metricspb.TimeSeries{MakeDoubleTimeSeries(12.5, testStartTime, testNow, []*metricspb.LabelValue{MakeLabelValue("idle")})},
+		},
+	}, builder)
+}
+
+func TestMetricsBuilderMissingAttribute(t *testing.T) {
+	descriptor := &MetricDescriptor{
+		Name:      "memory",
+		Type:      GaugeInt64,
+		LabelKeys: []LabelKey{testDeviceLabel, testStateLabel},
+	}
+
+	builder := NewMetricsBuilder()
+	builder.AddMetric(descriptor).AddInt64Point(10, testStartTime, testNow, map[string]string{"state": "used"})
+
+	assertEquivalent(t, []*metricspb.Metric{{
+		MetricDescriptor: ocDescriptor(descriptor),
+		Timeseries: []*metricspb.TimeSeries{
+			MakeInt64TimeSeries(10, testStartTime, testNow, []*metricspb.LabelValue{{}, MakeLabelValue("used")}),
+		},
+	}}, builder)
+}
+
+func TestMetricsBuilderDistribution(t *testing.T) {
+	descriptor := &MetricDescriptor{
+		Name:      "latencies",
+		Unit:      "ms",
+		Type:      CumulativeDistribution,
+		LabelKeys: []LabelKey{testStateLabel},
+	}
+	bounds := []float64{1, 2, 4}
+	distribution := []int64{1, 0, 2, 3}
+
+	builder := NewMetricsBuilder()
+	builder.AddMetric(descriptor).AddDistributionPoint(distribution, bounds, 30, 6, testStartTime, testNow, map[string]string{"state": "ok"})
+
+	assertEquivalent(t, []*metricspb.Metric{{
+		MetricDescriptor: ocDescriptor(descriptor),
+		Timeseries: []*metricspb.TimeSeries{
+			MakeDistributionTimeSeries(distribution, 30, 12, 6, testStartTime, testNow, FormatBucketOptions(bounds), []*metricspb.LabelValue{MakeLabelValue("ok")}),
+		},
+	}}, builder)
+
+	point := builder.Metrics().ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0).Histogram().DataPoints().At(0)
+	assert.Equal(t, []uint64{1, 0, 2, 3}, point.BucketCounts())
+	assert.Equal(t, pdata.NewTimestampFromTime(testStartTime), point.StartTimestamp())
+}
@@ -0,0 +1,65 @@
+package metricgenerator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EstimateQuantile estimates the q-quantile, for q between 0 and 1, of the values counted in
+// the buckets defined by bounds. The quantile is interpolated linearly inside the bucket it
+// falls in, assuming the values are spread evenly in the bucket. The first bucket is taken to
+// start at 0 if its upper bound is positive, and a quantile falling in the last bucket, which
+// has no upper bound, is estimated as its lower bound.
+func EstimateQuantile(q float64, buckets []int64, bounds []float64) (float64, error) {
+	if !(q >= 0 && q <= 1) {
+		return 0, fmt.Errorf("quantile %v must be between 0 and 1", q)
+	}
+	if len(bounds) == 0 {
+		return 0, errors.New("can not estimate quantiles without bucket bounds")
+	}
+	if len(buckets) != len(bounds)+1 {
+		return 0, fmt.Errorf("%d bucket counts for %d bucket bounds", len(buckets), len(bounds))
+	}
+
+	var total int64
+	for _, count := range buckets {
+		if count < 0 {
+			return 0, errors.New("bucket counts must not be negative")
+		}
+		total += count
+	}
+	if total == 0 {
+		return 0, errors.New("can not estimate quantiles of an empty distribution")
+	}
+
+	rank := q * float64(total)
+	var below int64
+	for i, count := range buckets {
+		if count == 0 || float64(below+count) < rank {
+			below += count
+			continue
+		}
+		if i == len(bounds) {
+			return bounds[i-1], nil
+		}
+
+		upper := bounds[i]
+		var lower float64
+		switch {
+		case i > 0:
+			lower = bounds[i-1]
+		case upper > 0:
+			lower = 0
+		default:
+			return upper, nil
+		}
+		return lower + (upper-lower)*(rank-float64(below))/float64(count), nil
+	}
+	// Only reached through rounding errors with q = 1.
+	return bounds[len(bounds)-1], nil
+}
+
+// Quantile estimates the q-quantile of the distribution with EstimateQuantile.
+func (d DistributionValue) Quantile(q float64) (float64, error) {
+	return EstimateQuantile(q, d.Buckets, d.Bounds)
+}
@@ -0,0 +1,69 @@
+package metricgenerator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateQuantile(t *testing.T) {
+	bounds := []float64{10, 20, 40}
+	buckets := []int64{10, 20, 10, 0}
+
+	tests := []struct {
+		q        float64
+		expected float64
+	}{
+		{q: 0, expected: 0},
+		{q: 0.1, expected: 4},
+		{q: 0.25, expected: 10},
+		{q: 0.5, expected: 15},
+		{q: 0.75, expected: 20},
+		{q: 0.875, expected: 30},
+		{q: 1, expected: 40},
+	}
+	for _, tc := range tests {
+		quantile, err := EstimateQuantile(tc.q, buckets, bounds)
+		require.NoError(t, err)
+		assert.InDelta(t, tc.expected, quantile, 1e-9, "quantile %v", tc.q)
+	}
+}
+
+func TestEstimateQuantileSkipsEmptyBuckets(t *testing.T) {
+	quantile, err := EstimateQuantile(0, []int64{0, 0, 4, 0}, []float64{1, 2, 4})
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, quantile)
+}
+
+func TestEstimateQuantileInLastBucket(t *testing.T) {
+	quantile, err := EstimateQuantile(0.99, []int64{1, 0, 9}, []float64{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, quantile)
+}
+
+func TestEstimateQuantileNegativeFirstBound(t *testing.T) {
+	quantile, err := EstimateQuantile(0.5, []int64{4, 0}, []float64{-1})
+	require.NoError(t, err)
+	assert.Equal(t, -1.0, quantile)
+}
+
+func TestEstimateQuantileErrors(t *testing.T) {
+	_, err := EstimateQuantile(1.5, []int64{1, 1}, []float64{1})
+	assert.Error(t, err)
+	_, err = EstimateQuantile(0.5, []int64{1}, nil)
+	assert.Error(t, err)
+	_, err = EstimateQuantile(0.5, []int64{1, 1}, []float64{1, 2})
+	assert.Error(t, err)
+	_, err = EstimateQuantile(0.5, []int64{0, 0}, []float64{1})
+	assert.Error(t, err)
+	_, err = EstimateQuantile(0.5, []int64{-1, 2}, []float64{1})
+	assert.Error(t, err)
+}
+
+func TestDistributionValueQuantile(t *testing.T) {
+	d := DistributionValue{Count: 4, Sum: 10, Bounds: []float64{2, 4}, Buckets: []int64{2, 2, 0}}
+	quantile, err := d.Quantile(0.5)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, quantile)
+}
@@ -0,0 +1,216 @@
+package metricgenerator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+var (
+	// metricNamePattern matches metric names, which may be grouped with slashes like container/cpu/usage_time.
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*(/[a-zA-Z][a-zA-Z0-9_]*)*$`)
+	labelKeyPattern   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// unitTermPattern matches a UCUM term: an optional prefix, a unit atom and an optional exponent.
+	unitTermPattern = regexp.MustCompile(`^(Ki|Mi|Gi|Ti|k|M|G|T|m|u|n)?(By|bit|s|min|h|d|wk|Hz|Cel|K)(-?[1-9][0-9]*)?$`)
+	// unitAnnotationPattern matches a UCUM annotation such as {request}, which counts something dimensionless.
+	unitAnnotationPattern = regexp.MustCompile(`^\{[a-zA-Z0-9_ .-]+\}$`)
+	// legacyUnits are the units of the metrics exported before the units were checked, by
+	// metric name. They are not UCUM units, but changing the unit of a metric breaks its
+	// existing Cloud Monitoring descriptor, so these metrics keep them. New metrics use UCUM units.
+	legacyUnits = map[string]string{
+		"container/cpu/usage_time":               "seconds",
+		"container/cpu/limit":                    "seconds",
+		"container/memory/usage":                 "bytes",
+		"container/memory/limit":                 "bytes",
+		"container/network/received_bytes_count": "bytes",
+		"container/network/sent_bytes_count":     "byte",
+		"container/uptime":                       "seconds",
+		"container/restart_count":                "Count",
+		"on_vm_request_latencies":                "milliseconds",
+		"on_vm_upstream_latencies":               "milliseconds",
+		"web_socket/durations":                   "milliseconds",
+		"vm_image_age":                           "Days",
+		"vm_ready_time":                          "Seconds",
+		// The percentiles are estimated from on_vm_request_latencies and share its unit.
+		"on_vm_request_latency_percentiles": "milliseconds",
+	}
+)
+
+// Registry holds the descriptors of the metrics a receiver generates. Receivers declare each
+// of their metrics once with MustRegister, which checks the descriptors when the receiver
+// package is initialized, and check the metrics they generate with Validate before exporting them.
+type Registry struct {
+	descriptors map[string]*MetricDescriptor
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{descriptors: make(map[string]*MetricDescriptor)}
+}
+
+// validateUnit checks that unit is a UCUM unit made of the units the receivers use,
+// for example By, ms, By/s or {request}, or 1 for dimensionless values.
+func validateUnit(unit string) error {
+	if unit == "1" || unit == "%" {
+		return nil
+	}
+	for _, product := range strings.Split(unit, "/") {
+		for _, term := range strings.Split(product, ".") {
+			if !unitTermPattern.MatchString(term) && !unitAnnotationPattern.MatchString(term) {
+				return fmt.Errorf("unit %q is not a valid UCUM unit", unit)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateDescriptor checks that the name, unit, type and label keys of descriptor are valid.
+// The metrics listed in legacyUnits may keep their legacy unit.
+func ValidateDescriptor(descriptor *MetricDescriptor) error {
+	if !metricNamePattern.MatchString(descriptor.Name) {
+		return fmt.Errorf("metric name %q is not valid", descriptor.Name)
+	}
+	if legacyUnits[descriptor.Name] != descriptor.Unit {
+		if err := validateUnit(descriptor.Unit); err != nil {
+			return fmt.Errorf("metric %s: %v", descriptor.Name, err)
+		}
+	}
+	if descriptor.Type < GaugeInt64 || descriptor.Type > CumulativeDistribution {
+		return fmt.Errorf("metric %s: unknown metric type %d", descriptor.Name, descriptor.Type)
+	}
+	keys := make(map[string]bool, len(descriptor.LabelKeys))
+	for _, labelKey := range descriptor.LabelKeys {
+		if !labelKeyPattern.MatchString(labelKey.Key) {
+			return fmt.Errorf("metric %s: label key %q is not valid", descriptor.Name, labelKey.Key)
+		}
+		if keys[labelKey.Key] {
+			return fmt.Errorf("metric %s: label key %q is repeated", descriptor.Name, labelKey.Key)
+		}
+		keys[labelKey.Key] = true
+	}
+	return nil
+}
+
+// Register adds descriptor to the registry, after checking that it is valid and that no other
+// metric with the same name is registered.
+func (registry *Registry) Register(descriptor *MetricDescriptor) error {
+	if err := ValidateDescriptor(descriptor); err != nil {
+		return err
+	}
+	if _, ok := registry.descriptors[descriptor.Name]; ok {
+		return fmt.Errorf("metric %s is already registered", descriptor.Name)
+	}
+	registry.descriptors[descriptor.Name] = descriptor
+	return nil
+}
+
+// MustRegister is like Register but panics if the descriptor can not be registered.
+// It returns the descriptor, so it can be used to declare the metrics in package variables.
+func (registry *Registry) MustRegister(descriptor *MetricDescriptor) *MetricDescriptor {
+	if err := registry.Register(descriptor); err != nil {
+		panic(err)
+	}
+	return descriptor
+}
+
+// Lookup returns the registered descriptor of the metric named name.
+func (registry *Registry) Lookup(name string) (*MetricDescriptor, bool) {
+	descriptor, ok := registry.descriptors[name]
+	return descriptor, ok
+}
+
+// validateAttributes checks that attributes have a value for every label key of descriptor and nothing else.
+func validateAttributes(descriptor *MetricDescriptor, attributes pdata.AttributeMap) error {
+	if attributes.Len() != len(descriptor.LabelKeys) {
+		return fmt.Errorf("metric %s: %d attributes for %d label keys", descriptor.Name, attributes.Len(), len(descriptor.LabelKeys))
+	}
+	for _, labelKey := range descriptor.LabelKeys {
+		if _, ok := attributes.Get(labelKey.Key); !ok {
+			return fmt.Errorf("metric %s: attribute %s is missing", descriptor.Name, labelKey.Key)
+		}
+	}
+	return nil
+}
+
+// validateMetric checks that metric is registered and that its type and data points match its descriptor.
+func (registry *Registry) validateMetric(metric pdata.Metric) error {
+	descriptor, ok := registry.Lookup(metric.Name())
+	if !ok {
+		return fmt.Errorf("metric %s is not registered", metric.Name())
+	}
+	if metric.Unit() != descriptor.Unit {
+		return fmt.Errorf("metric %s: unit %q does not match the registered unit %q", descriptor.Name, metric.Unit(), descriptor.Unit)
+	}
+
+	var valueType pdata.MetricValueType
+	var dataType pdata.MetricDataType
+	switch descriptor.Type {
+	case GaugeInt64:
+		dataType, valueType = pdata.MetricDataTypeGauge, pdata.MetricValueTypeInt
+	case GaugeDouble:
+		dataType, valueType = pdata.MetricDataTypeGauge, pdata.MetricValueTypeDouble
+	case CumulativeInt64:
+		dataType, valueType = pdata.MetricDataTypeSum, pdata.MetricValueTypeInt
+	case CumulativeDouble:
+		dataType, valueType = pdata.MetricDataTypeSum, pdata.MetricValueTypeDouble
+	case CumulativeDistribution:
+		dataType = pdata.MetricDataTypeHistogram
+	}
+	if metric.DataType() != dataType {
+		return fmt.Errorf("metric %s: data type %s does not match the registered type", descriptor.Name, metric.DataType())
+	}
+
+	var points pdata.NumberDataPointSlice
+	switch dataType {
+	case pdata.MetricDataTypeGauge:
+		points = metric.Gauge().DataPoints()
+	case pdata.MetricDataTypeSum:
+		points = metric.Sum().DataPoints()
+	case pdata.MetricDataTypeHistogram:
+		histogramPoints := metric.Histogram().DataPoints()
+		for i := 0; i < histogramPoints.Len(); i++ {
+			point := histogramPoints.At(i)
+			if err := validateAttributes(descriptor, point.Attributes()); err != nil {
+				return err
+			}
+			if len(point.BucketCounts()) != len(point.ExplicitBounds())+1 {
+				return fmt.Errorf("metric %s: %d bucket counts for %d bucket bounds", descriptor.Name, len(point.BucketCounts()), len(point.ExplicitBounds()))
+			}
+		}
+		return nil
+	}
+	for i := 0; i < points.Len(); i++ {
+		point := points.At(i)
+		if err := validateAttributes(descriptor, point.Attributes()); err != nil {
+			return err
+		}
+		if point.ValueType() != valueType {
+			return fmt.Errorf("metric %s: value type %s does not match the registered type", descriptor.Name, point.ValueType())
+		}
+	}
+	return nil
+}
+
+// Validate checks that every metric in metrics is registered and matches its descriptor:
+// the unit, the data type, the value type and the attributes of every data point.
+func (registry *Registry) Validate(metrics pdata.Metrics) error {
+	var problems []string
+	resourceMetrics := metrics.ResourceMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		libraryMetrics := resourceMetrics.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < libraryMetrics.Len(); j++ {
+			metricSlice := libraryMetrics.At(j).Metrics()
+			for k := 0; k < metricSlice.Len(); k++ {
+				if err := registry.validateMetric(metricSlice.At(k)); err != nil {
+					problems = append(problems, err.Error())
+				}
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid metrics: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
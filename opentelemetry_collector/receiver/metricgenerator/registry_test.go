@@ -0,0 +1,165 @@
+package metricgenerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDescriptor(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor MetricDescriptor
+		valid      bool
+	}{
+		{
+			name:       "valid",
+			descriptor: MetricDescriptor{Name: "container/memory/usage", Unit: "By", LabelKeys: []LabelKey{{Key: "container"}}},
+			valid:      true,
+		},
+		{
+			name:       "rate unit",
+			descriptor: MetricDescriptor{Name: "requests", Unit: "{request}/s"},
+			valid:      true,
+		},
+		{
+			name:       "dimensionless unit",
+			descriptor: MetricDescriptor{Name: "ratio", Unit: "1"},
+			valid:      true,
+		},
+		{
+			name:       "legacy unit",
+			descriptor: MetricDescriptor{Name: "container/uptime", Unit: "seconds"},
+			valid:      true,
+		},
+		{
+			name:       "legacy unit of another metric",
+			descriptor: MetricDescriptor{Name: "host/uptime", Unit: "seconds"},
+		},
+		{
+			name:       "other legacy unit",
+			descriptor: MetricDescriptor{Name: "container/uptime", Unit: "Seconds"},
+		},
+		{
+			name:       "invalid name",
+			descriptor: MetricDescriptor{Name: "container//usage", Unit: "1"},
+		},
+		{
+			name:       "invalid unit",
+			descriptor: MetricDescriptor{Name: "usage", Unit: "megabytes"},
+		},
+		{
+			name:       "unknown type",
+			descriptor: MetricDescriptor{Name: "usage", Unit: "By", Type: MetricType(42)},
+		},
+		{
+			name:       "invalid label key",
+			descriptor: MetricDescriptor{Name: "usage", Unit: "By", LabelKeys: []LabelKey{{Key: "container-name"}}},
+		},
+		{
+			name:       "repeated label key",
+			descriptor: MetricDescriptor{Name: "usage", Unit: "By", LabelKeys: []LabelKey{{Key: "container"}, {Key: "container"}}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDescriptor(&tc.descriptor)
+			assert.Equal(t, tc.valid, err == nil, "error: %v", err)
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	registry := NewRegistry()
+	descriptor := &MetricDescriptor{Name: "usage", Unit: "By"}
+
+	assert.NoError(t, registry.Register(descriptor))
+	assert.Error(t, registry.Register(&MetricDescriptor{Name: "usage", Unit: "By"}))
+
+	registered, ok := registry.Lookup("usage")
+	assert.True(t, ok)
+	assert.Same(t, descriptor, registered)
+	_, ok = registry.Lookup("other")
+	assert.False(t, ok)
+}
+
+func TestMustRegisterPanicsOnInvalidDescriptor(t *testing.T) {
+	registry := NewRegistry()
+	assert.Panics(t, func() {
+		registry.MustRegister(&MetricDescriptor{Name: "usage", Unit: "megabytes"})
+	})
+}
+
+func TestValidate(t *testing.T) {
+	registry := NewRegistry()
+	gauge := registry.MustRegister(&MetricDescriptor{
+		Name:      "gauge",
+		Unit:      "By",
+		Type:      GaugeInt64,
+		LabelKeys: []LabelKey{{Key: "container"}},
+	})
+	distribution := registry.MustRegister(&MetricDescriptor{
+		Name: "distribution",
+		Unit: "ms",
+		Type: CumulativeDistribution,
+	})
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	attributes := map[string]string{"container": "app"}
+
+	builder := NewMetricsBuilder()
+	builder.AddMetric(gauge).AddInt64Point(1, now, now, attributes)
+	builder.AddMetric(distribution).AddDistributionPoint([]int64{1, 2}, []float64{10}, 15, 3, now, now, nil)
+	assert.NoError(t, registry.Validate(builder.Metrics()))
+
+	tests := []struct {
+		name  string
+		build func(builder *MetricsBuilder)
+	}{
+		{
+			name: "unregistered metric",
+			build: func(builder *MetricsBuilder) {
+				builder.AddMetric(&MetricDescriptor{Name: "other", Unit: "By"}).AddInt64Point(1, now, now, nil)
+			},
+		},
+		{
+			name: "unit mismatch",
+			build: func(builder *MetricsBuilder) {
+				builder.AddMetric(&MetricDescriptor{Name: "gauge", Unit: "s", LabelKeys: gauge.LabelKeys}).AddInt64Point(1, now, now, attributes)
+			},
+		},
+		{
+			name: "type mismatch",
+			build: func(builder *MetricsBuilder) {
+				builder.AddMetric(&MetricDescriptor{Name: "gauge", Unit: "By", Type: CumulativeInt64, LabelKeys: gauge.LabelKeys}).AddInt64Point(1, now, now, attributes)
+			},
+		},
+		{
+			name: "value type mismatch",
+			build: func(builder *MetricsBuilder) {
+				builder.AddMetric(gauge).AddDoublePoint(1, now, now, attributes)
+			},
+		},
+		{
+			name: "missing attribute",
+			build: func(builder *MetricsBuilder) {
+				builder.AddMetric(gauge).AddInt64Point(1, now, now, nil)
+			},
+		},
+		{
+			name: "bucket count mismatch",
+			build: func(builder *MetricsBuilder) {
+				builder.AddMetric(distribution).AddDistributionPoint([]int64{1, 2}, []float64{10, 20}, 15, 3, now, now, nil)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := NewMetricsBuilder()
+			tc.build(builder)
+			assert.Error(t, registry.Validate(builder.Metrics()))
+		})
+	}
+}
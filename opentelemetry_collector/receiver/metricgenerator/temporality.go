@@ -0,0 +1,309 @@
+package metricgenerator
+
+import (
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// DistributionValue is a distribution with explicit bucket bounds, in the form taken by
+// MakeDistributionTimeSeries and Metric.AddDistributionPoint. DistributionValueFromPoint and
+// Metric.AddDistributionValuePoint convert it from and to histogram data points.
+type DistributionValue struct {
+	Count                 int64
+	Sum                   float64
+	SumOfSquaredDeviation float64
+	// Bounds are the upper boundaries of the buckets, except for the last bucket which has
+	// +infinity as an implied upper bound.
+	Bounds []float64
+	// Buckets are the counts of the len(Bounds)+1 buckets.
+	Buckets []int64
+}
+
+// DistributionValueFromPoint returns the distribution of a histogram data point, like the
+// points added by Metric.AddDistributionPoint. Histogram data points do not carry the sum of
+// squared deviation, which is left at 0.
+func DistributionValueFromPoint(point pdata.HistogramDataPoint) DistributionValue {
+	buckets := make([]int64, len(point.BucketCounts()))
+	for i, bucketCount := range point.BucketCounts() {
+		buckets[i] = int64(bucketCount)
+	}
+	return DistributionValue{
+		Count:   int64(point.Count()),
+		Sum:     point.Sum(),
+		Bounds:  append([]float64(nil), point.ExplicitBounds()...),
+		Buckets: buckets,
+	}
+}
+
+// AddDistributionValuePoint adds a histogram data point with the distribution value to a
+// distribution metric, like AddDistributionPoint.
+func (m Metric) AddDistributionValuePoint(value DistributionValue, startTime, now time.Time, attributes map[string]string) {
+	m.AddDistributionPoint(value.Buckets, value.Bounds, value.Sum, value.Count, startTime, now, attributes)
+}
+
+func (d DistributionValue) mean() float64 {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.Sum / float64(d.Count)
+}
+
+func (d DistributionValue) copy() DistributionValue {
+	d.Bounds = append([]float64(nil), d.Bounds...)
+	d.Buckets = append([]int64(nil), d.Buckets...)
+	return d
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeDistributions returns the distribution of the values of both a and b, which must
+// have the same bucket bounds. The sums of squared deviations are combined with the
+// parallel variant of Welford's algorithm.
+func mergeDistributions(a, b DistributionValue) DistributionValue {
+	if a.Count == 0 {
+		return b.copy()
+	}
+	if b.Count == 0 {
+		return a.copy()
+	}
+
+	merged := a.copy()
+	merged.Count = a.Count + b.Count
+	merged.Sum = a.Sum + b.Sum
+	delta := b.mean() - a.mean()
+	merged.SumOfSquaredDeviation = a.SumOfSquaredDeviation + b.SumOfSquaredDeviation +
+		delta*delta*float64(a.Count)*float64(b.Count)/float64(merged.Count)
+	for i := range merged.Buckets {
+		merged.Buckets[i] += b.Buckets[i]
+	}
+	return merged
+}
+
+// subtractDistributions returns the distribution of the values of a that are not in b, which
+// must have the same bucket bounds. It returns false if b is not part of a, meaning a was reset.
+func subtractDistributions(a, b DistributionValue) (DistributionValue, bool) {
+	if a.Count < b.Count || len(a.Buckets) != len(b.Buckets) {
+		return DistributionValue{}, false
+	}
+	diff := a.copy()
+	for i := range diff.Buckets {
+		diff.Buckets[i] -= b.Buckets[i]
+		if diff.Buckets[i] < 0 {
+			return DistributionValue{}, false
+		}
+	}
+
+	diff.Count = a.Count - b.Count
+	if diff.Count == 0 {
+		diff.Sum = 0
+		diff.SumOfSquaredDeviation = 0
+		return diff, true
+	}
+	diff.Sum = a.Sum - b.Sum
+	if b.Count > 0 {
+		delta := diff.mean() - b.mean()
+		diff.SumOfSquaredDeviation = a.SumOfSquaredDeviation - b.SumOfSquaredDeviation -
+			delta*delta*float64(b.Count)*float64(diff.Count)/float64(a.Count)
+	}
+	if diff.SumOfSquaredDeviation < 0 {
+		// Rounding errors of the subtraction.
+		diff.SumOfSquaredDeviation = 0
+	}
+	return diff, true
+}
+
+// seriesKey identifies a series by the name of its metric and its label values.
+type seriesKey struct {
+	name   string
+	labels string
+}
+
+func makeSeriesKey(name string, labelValues []string) seriesKey {
+	return seriesKey{name: name, labels: strings.Join(labelValues, "\x00")}
+}
+
+// seriesState is what the converters remember about a series between two points.
+type seriesState struct {
+	// start is the start time of the cumulative series.
+	start time.Time
+	// last is the end time of the last point of the series.
+	last time.Time
+
+	int64Value   int64
+	doubleValue  float64
+	distribution DistributionValue
+}
+
+// seriesStates holds the state of the series of a converter, and evicts the series that
+// did not get a point for staleAfter.
+type seriesStates struct {
+	staleAfter time.Duration
+	states     map[seriesKey]*seriesState
+}
+
+func newSeriesStates(staleAfter time.Duration) seriesStates {
+	return seriesStates{staleAfter: staleAfter, states: make(map[seriesKey]*seriesState)}
+}
+
+func (s seriesStates) get(name string, labelValues []string) (*seriesState, bool) {
+	key := makeSeriesKey(name, labelValues)
+	state, ok := s.states[key]
+	if !ok {
+		state = &seriesState{}
+		s.states[key] = state
+	}
+	return state, ok
+}
+
+func (s seriesStates) removeStale(now time.Time) int {
+	removed := 0
+	for key, state := range s.states {
+		if now.Sub(state.last) > s.staleAfter {
+			delete(s.states, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// DeltaToCumulative accumulates delta points, each covering the time since the previous
+// point of its series, into cumulative series. A delta starting before the end of the
+// previous point of its series means its source restarted, so the cumulative series is
+// reset and restarts at the start of the delta. It is not safe for concurrent use.
+type DeltaToCumulative struct {
+	series seriesStates
+}
+
+// NewDeltaToCumulative creates a DeltaToCumulative that forgets the series that did not
+// get a point for staleAfter, when RemoveStale is called.
+func NewDeltaToCumulative(staleAfter time.Duration) *DeltaToCumulative {
+	return &DeltaToCumulative{series: newSeriesStates(staleAfter)}
+}
+
+// update returns the state of the series, reset if the delta from start to end does
+// not follow the previous point of the series.
+func (c *DeltaToCumulative) update(name string, labelValues []string, start, end time.Time) (*seriesState, bool) {
+	state, ok := c.series.get(name, labelValues)
+	if !ok || start.Before(state.last) {
+		*state = seriesState{start: start}
+		ok = false
+	}
+	state.last = end
+	return state, ok
+}
+
+// Int64 adds the delta from start to end to the series of the metric name with the label
+// values labelValues, and returns the cumulative value and the start time of the series.
+func (c *DeltaToCumulative) Int64(name string, labelValues []string, start, end time.Time, delta int64) (int64, time.Time) {
+	state, _ := c.update(name, labelValues, start, end)
+	state.int64Value += delta
+	return state.int64Value, state.start
+}
+
+// Double is like Int64 for double values.
+func (c *DeltaToCumulative) Double(name string, labelValues []string, start, end time.Time, delta float64) (float64, time.Time) {
+	state, _ := c.update(name, labelValues, start, end)
+	state.doubleValue += delta
+	return state.doubleValue, state.start
+}
+
+// Distribution is like Int64 for distributions. A change of the bucket bounds also resets the series.
+func (c *DeltaToCumulative) Distribution(name string, labelValues []string, start, end time.Time, delta DistributionValue) (DistributionValue, time.Time) {
+	state, ok := c.update(name, labelValues, start, end)
+	if ok && !equalBounds(state.distribution.Bounds, delta.Bounds) {
+		*state = seriesState{start: start, last: end}
+		ok = false
+	}
+	if ok {
+		state.distribution = mergeDistributions(state.distribution, delta)
+	} else {
+		state.distribution = delta.copy()
+	}
+	return state.distribution.copy(), state.start
+}
+
+// RemoveStale forgets the series that did not get a point in the staleness period before
+// now, and returns how many were removed. A removed series restarts with its next point.
+func (c *DeltaToCumulative) RemoveStale(now time.Time) int {
+	return c.series.removeStale(now)
+}
+
+// CumulativeToDelta turns cumulative points into delta points covering the time since the
+// previous point of their series. The first point of a series covers the time since the
+// start of the cumulative series. A cumulative value lower than the previous one or a
+// later start time means the source was reset, and the delta is the whole value since the
+// reset. It is not safe for concurrent use.
+type CumulativeToDelta struct {
+	series seriesStates
+}
+
+// NewCumulativeToDelta creates a CumulativeToDelta that forgets the series that did not
+// get a point for staleAfter, when RemoveStale is called.
+func NewCumulativeToDelta(staleAfter time.Duration) *CumulativeToDelta {
+	return &CumulativeToDelta{series: newSeriesStates(staleAfter)}
+}
+
+// update returns the previous state of the series and the start time of the delta ending
+// at now. reset is true if there is no previous point to subtract.
+func (c *CumulativeToDelta) update(name string, labelValues []string, start, now time.Time) (previous seriesState, state *seriesState, deltaStart time.Time, reset bool) {
+	state, ok := c.series.get(name, labelValues)
+	previous = *state
+	state.start = start
+	state.last = now
+	if !ok || start.After(previous.start) {
+		return previous, state, start, true
+	}
+	return previous, state, previous.last, false
+}
+
+// Int64 returns the delta of the series of the metric name with the label values labelValues
+// since its previous point, given the cumulative value since start at now, and the start time of the delta.
+func (c *CumulativeToDelta) Int64(name string, labelValues []string, start, now time.Time, cumulative int64) (int64, time.Time) {
+	previous, state, deltaStart, reset := c.update(name, labelValues, start, now)
+	state.int64Value = cumulative
+	if reset || cumulative < previous.int64Value {
+		return cumulative, deltaStart
+	}
+	return cumulative - previous.int64Value, deltaStart
+}
+
+// Double is like Int64 for double values.
+func (c *CumulativeToDelta) Double(name string, labelValues []string, start, now time.Time, cumulative float64) (float64, time.Time) {
+	previous, state, deltaStart, reset := c.update(name, labelValues, start, now)
+	state.doubleValue = cumulative
+	if reset || cumulative < previous.doubleValue {
+		return cumulative, deltaStart
+	}
+	return cumulative - previous.doubleValue, deltaStart
+}
+
+// Distribution is like Int64 for distributions. A change of the bucket bounds is also a reset.
+func (c *CumulativeToDelta) Distribution(name string, labelValues []string, start, now time.Time, cumulative DistributionValue) (DistributionValue, time.Time) {
+	previous, state, deltaStart, reset := c.update(name, labelValues, start, now)
+	state.distribution = cumulative.copy()
+	if !reset && equalBounds(previous.distribution.Bounds, cumulative.Bounds) {
+		if delta, ok := subtractDistributions(cumulative, previous.distribution); ok {
+			return delta, deltaStart
+		}
+	}
+	return cumulative.copy(), deltaStart
+}
+
+// RemoveStale forgets the series that did not get a point in the staleness period before
+// now, and returns how many were removed. The next point of a removed series is handled
+// like its first point.
+func (c *CumulativeToDelta) RemoveStale(now time.Time) int {
+	return c.series.removeStale(now)
+}
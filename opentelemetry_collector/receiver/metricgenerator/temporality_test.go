@@ -0,0 +1,197 @@
+package metricgenerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	timestamp "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var testBounds = []float64{10, 100}
+
+// distributionOf returns the distribution of values with the bucket bounds testBounds.
+func distributionOf(values ...float64) DistributionValue {
+	d := DistributionValue{Bounds: testBounds, Buckets: make([]int64, len(testBounds)+1)}
+	for _, val := range values {
+		d.Count++
+		d.Sum += val
+		d.Buckets[getBucketIndex(val, testBounds)]++
+	}
+	mean := d.mean()
+	for _, val := range values {
+		d.SumOfSquaredDeviation += (val - mean) * (val - mean)
+	}
+	return d
+}
+
+func assertDistribution(t *testing.T, expected, actual DistributionValue) {
+	assert.Equal(t, expected.Count, actual.Count)
+	assert.InDelta(t, expected.Sum, actual.Sum, 1e-9)
+	assert.InDelta(t, expected.SumOfSquaredDeviation, actual.SumOfSquaredDeviation, 1e-9)
+	assert.Equal(t, expected.Bounds, actual.Bounds)
+	assert.Equal(t, expected.Buckets, actual.Buckets)
+}
+
+func TestDeltaToCumulativeInt64(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewDeltaToCumulative(time.Hour)
+
+	value, start := converter.Int64("requests", []string{"200"}, t0, t0.Add(time.Minute), 3)
+	assert.Equal(t, int64(3), value)
+	assert.Equal(t, t0, start)
+
+	value, start = converter.Int64("requests", []string{"200"}, t0.Add(time.Minute), t0.Add(2*time.Minute), 4)
+	assert.Equal(t, int64(7), value)
+	assert.Equal(t, t0, start)
+
+	// Series with other label values or metric names are separate.
+	value, _ = converter.Int64("requests", []string{"500"}, t0.Add(time.Minute), t0.Add(2*time.Minute), 1)
+	assert.Equal(t, int64(1), value)
+	value, _ = converter.Int64("errors", []string{"200"}, t0.Add(time.Minute), t0.Add(2*time.Minute), 2)
+	assert.Equal(t, int64(2), value)
+}
+
+func TestDeltaToCumulativeReset(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewDeltaToCumulative(time.Hour)
+
+	converter.Double("latency", nil, t0, t0.Add(2*time.Minute), 1.5)
+	// The delta overlaps the previous one, so the source restarted.
+	value, start := converter.Double("latency", nil, t0.Add(time.Minute), t0.Add(3*time.Minute), 2)
+	assert.Equal(t, 2.0, value)
+	assert.Equal(t, t0.Add(time.Minute), start)
+}
+
+func TestDeltaToCumulativeDistribution(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewDeltaToCumulative(time.Hour)
+
+	converter.Distribution("latency", nil, t0, t0.Add(time.Minute), distributionOf(1, 50))
+	cumulative, start := converter.Distribution("latency", nil, t0.Add(time.Minute), t0.Add(2*time.Minute), distributionOf(7, 300, 20))
+	assertDistribution(t, distributionOf(1, 50, 7, 300, 20), cumulative)
+	assert.Equal(t, t0, start)
+
+	// A change of the bucket bounds resets the series.
+	delta := DistributionValue{Count: 1, Sum: 5, Bounds: []float64{10}, Buckets: []int64{1, 0}}
+	cumulative, start = converter.Distribution("latency", nil, t0.Add(2*time.Minute), t0.Add(3*time.Minute), delta)
+	assertDistribution(t, delta, cumulative)
+	assert.Equal(t, t0.Add(2*time.Minute), start)
+}
+
+func TestDeltaToCumulativeRemoveStale(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewDeltaToCumulative(10 * time.Minute)
+
+	converter.Int64("requests", []string{"a"}, t0, t0.Add(time.Minute), 1)
+	converter.Int64("requests", []string{"b"}, t0, t0.Add(10*time.Minute), 1)
+
+	assert.Equal(t, 1, converter.RemoveStale(t0.Add(15*time.Minute)))
+	value, start := converter.Int64("requests", []string{"a"}, t0.Add(15*time.Minute), t0.Add(16*time.Minute), 2)
+	assert.Equal(t, int64(2), value)
+	assert.Equal(t, t0.Add(15*time.Minute), start)
+	value, _ = converter.Int64("requests", []string{"b"}, t0.Add(10*time.Minute), t0.Add(16*time.Minute), 2)
+	assert.Equal(t, int64(3), value)
+}
+
+func TestCumulativeToDeltaInt64(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewCumulativeToDelta(time.Hour)
+
+	// The first point covers the time since the start of the cumulative series.
+	delta, start := converter.Int64("requests", nil, t0, t0.Add(time.Minute), 5)
+	assert.Equal(t, int64(5), delta)
+	assert.Equal(t, t0, start)
+
+	delta, start = converter.Int64("requests", nil, t0, t0.Add(2*time.Minute), 8)
+	assert.Equal(t, int64(3), delta)
+	assert.Equal(t, t0.Add(time.Minute), start)
+
+	// A lower value means the counter was reset.
+	delta, start = converter.Int64("requests", nil, t0, t0.Add(3*time.Minute), 2)
+	assert.Equal(t, int64(2), delta)
+	assert.Equal(t, t0.Add(2*time.Minute), start)
+
+	// So does a later start time.
+	delta, start = converter.Int64("requests", nil, t0.Add(3*time.Minute), t0.Add(4*time.Minute), 10)
+	assert.Equal(t, int64(10), delta)
+	assert.Equal(t, t0.Add(3*time.Minute), start)
+}
+
+func TestCumulativeToDeltaDouble(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewCumulativeToDelta(time.Hour)
+
+	converter.Double("cpu", []string{"user"}, t0, t0.Add(time.Minute), 1.25)
+	delta, start := converter.Double("cpu", []string{"user"}, t0, t0.Add(2*time.Minute), 2)
+	assert.Equal(t, 0.75, delta)
+	assert.Equal(t, t0.Add(time.Minute), start)
+}
+
+func TestCumulativeToDeltaDistribution(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewCumulativeToDelta(time.Hour)
+
+	converter.Distribution("latency", nil, t0, t0.Add(time.Minute), distributionOf(1, 50))
+	delta, start := converter.Distribution("latency", nil, t0, t0.Add(2*time.Minute), distributionOf(1, 50, 7, 300, 20))
+	assertDistribution(t, distributionOf(7, 300, 20), delta)
+	assert.Equal(t, t0.Add(time.Minute), start)
+
+	delta, _ = converter.Distribution("latency", nil, t0, t0.Add(3*time.Minute), distributionOf(1, 50, 7, 300, 20))
+	assertDistribution(t, distributionOf(), delta)
+
+	// A bucket count going down means the distribution was reset.
+	delta, _ = converter.Distribution("latency", nil, t0, t0.Add(4*time.Minute), distributionOf(400, 500, 600, 700, 800, 900))
+	assertDistribution(t, distributionOf(400, 500, 600, 700, 800, 900), delta)
+}
+
+func TestCumulativeToDeltaRemoveStale(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewCumulativeToDelta(10 * time.Minute)
+
+	converter.Int64("requests", nil, t0, t0.Add(time.Minute), 5)
+	assert.Equal(t, 0, converter.RemoveStale(t0.Add(5*time.Minute)))
+	assert.Equal(t, 1, converter.RemoveStale(t0.Add(20*time.Minute)))
+
+	delta, start := converter.Int64("requests", nil, t0, t0.Add(21*time.Minute), 8)
+	assert.Equal(t, int64(8), delta)
+	assert.Equal(t, t0, start)
+}
+
+func TestDeltaToCumulativeDistributionTimeSeries(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	converter := NewDeltaToCumulative(time.Hour)
+
+	converter.Distribution("latency", nil, t0, t0.Add(time.Minute), distributionOf(1, 50))
+	cumulative, start := converter.Distribution("latency", nil, t0.Add(time.Minute), t0.Add(2*time.Minute), distributionOf(300))
+
+	timeseries := MakeDistributionTimeSeries(
+		cumulative.Buckets,
+		cumulative.Sum,
+		cumulative.SumOfSquaredDeviation,
+		cumulative.Count,
+		start, t0.Add(2*time.Minute),
+		FormatBucketOptions(cumulative.Bounds),
+		nil)
+
+	assert.Equal(t, timestamp.New(t0), timeseries.StartTimestamp)
+	distribution := timeseries.Points[0].GetDistributionValue()
+	assert.Equal(t, int64(3), distribution.Count)
+	assert.Equal(t, 351.0, distribution.Sum)
+	assert.InDelta(t, distributionOf(1, 50, 300).SumOfSquaredDeviation, distribution.SumOfSquaredDeviation, 1e-9)
+	assert.Equal(t, testBounds, distribution.BucketOptions.GetExplicit().Bounds)
+	assert.Equal(t, formatBuckets([]int64{1, 1, 1}), distribution.Buckets)
+}
+
+func TestDistributionValuePointRoundTrip(t *testing.T) {
+	t0 := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	value := distributionOf(1, 20, 30, 200)
+	builder := NewMetricsBuilder()
+	builder.AddMetric(&MetricDescriptor{Name: "latency", Type: CumulativeDistribution}).
+		AddDistributionValuePoint(value, t0, t0.Add(time.Minute), nil)
+
+	point := builder.Metrics().ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0).Histogram().DataPoints().At(0)
+	// The sum of squared deviation is not carried by histogram data points.
+	value.SumOfSquaredDeviation = 0
+	assertDistribution(t, value, DistributionValueFromPoint(point))
+}
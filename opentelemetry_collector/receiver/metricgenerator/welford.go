@@ -0,0 +1,63 @@
+package metricgenerator
+
+// WelfordAccumulator accumulates the count, mean and sum of squared deviations of values
+// one at a time with Welford's algorithm. Unlike computing them from a sum of squares, it
+// neither overflows nor loses precision by subtracting large, nearly equal numbers.
+// The zero value is an empty accumulator.
+type WelfordAccumulator struct {
+	count int64
+	mean  float64
+	m2    float64
+}
+
+// Add adds val to the accumulated values.
+func (a *WelfordAccumulator) Add(val float64) {
+	a.count++
+	delta := val - a.mean
+	a.mean += delta / float64(a.count)
+	a.m2 += delta * (val - a.mean)
+}
+
+// Merge adds the values accumulated by other, with the parallel variant of Welford's algorithm.
+func (a *WelfordAccumulator) Merge(other WelfordAccumulator) {
+	if other.count == 0 {
+		return
+	}
+	if a.count == 0 {
+		*a = other
+		return
+	}
+	count := a.count + other.count
+	delta := other.mean - a.mean
+	a.m2 += other.m2 + delta*delta*float64(a.count)*float64(other.count)/float64(count)
+	a.mean += delta * float64(other.count) / float64(count)
+	a.count = count
+}
+
+// Count returns the number of accumulated values.
+func (a *WelfordAccumulator) Count() int64 {
+	return a.count
+}
+
+// Sum returns the sum of the accumulated values.
+func (a *WelfordAccumulator) Sum() float64 {
+	return a.mean * float64(a.count)
+}
+
+// Mean returns the mean of the accumulated values, or 0 if there are none.
+func (a *WelfordAccumulator) Mean() float64 {
+	return a.mean
+}
+
+// SumOfSquaredDeviation returns the sum of the squared deviations of the accumulated values from their mean.
+func (a *WelfordAccumulator) SumOfSquaredDeviation() float64 {
+	return a.m2
+}
+
+// Variance returns the population variance of the accumulated values, or 0 if there are none.
+func (a *WelfordAccumulator) Variance() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.m2 / float64(a.count)
+}
@@ -0,0 +1,52 @@
+package metricgenerator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWelfordAccumulator(t *testing.T) {
+	var accumulator WelfordAccumulator
+	assert.Equal(t, 0.0, accumulator.Variance())
+
+	for _, val := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		accumulator.Add(val)
+	}
+	assert.Equal(t, int64(8), accumulator.Count())
+	assert.Equal(t, 40.0, accumulator.Sum())
+	assert.Equal(t, 5.0, accumulator.Mean())
+	assert.Equal(t, 32.0, accumulator.SumOfSquaredDeviation())
+	assert.Equal(t, 4.0, accumulator.Variance())
+}
+
+func TestWelfordAccumulatorLargeValues(t *testing.T) {
+	// Computing the variance from the sum of squares of these values loses all precision.
+	var accumulator WelfordAccumulator
+	for _, val := range []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16} {
+		accumulator.Add(val)
+	}
+	assert.InDelta(t, 22.5, accumulator.Variance(), 1e-6)
+}
+
+func TestWelfordAccumulatorMerge(t *testing.T) {
+	var all, first, second WelfordAccumulator
+	for i, val := range []float64{1, 3, 8, 2, 9, 4, 4} {
+		all.Add(val)
+		if i < 3 {
+			first.Add(val)
+		} else {
+			second.Add(val)
+		}
+	}
+
+	var empty WelfordAccumulator
+	first.Merge(empty)
+	first.Merge(second)
+	assert.Equal(t, all.Count(), first.Count())
+	assert.InDelta(t, all.Mean(), first.Mean(), 1e-12)
+	assert.InDelta(t, all.SumOfSquaredDeviation(), first.SumOfSquaredDeviation(), 1e-9)
+
+	empty.Merge(all)
+	assert.Equal(t, all, empty)
+}
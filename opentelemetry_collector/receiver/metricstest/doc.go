@@ -0,0 +1,7 @@
+// Package metricstest provides helpers to test the receivers that generate metrics.
+// A Sink captures the metrics a receiver sends to its consumer, Render turns them into
+// stable, sorted text, and AssertGolden compares that text with a golden file in testdata.
+// Run the tests with -update to rewrite the golden files after an intended change, e.g.
+//
+//	go test ./receiver/nginxreceiver -update
+package metricstest
@@ -0,0 +1,49 @@
+package metricstest
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata instead of comparing with them")
+
+// AssertGolden compares actual with the content of the golden file at path, or rewrites
+// the file with actual when the tests run with -update.
+func AssertGolden(t testing.TB, path string, actual string) bool {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Could not create the directory of golden file %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("Could not update golden file %s: %v", path, err)
+		}
+		return true
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Errorf("Could not read golden file %s, run the tests with -update to create it: %v", path, err)
+		return false
+	}
+	return assert.Equal(t, string(expected), actual, "output differs from golden file %s, run the tests with -update if the change is intended", path)
+}
+
+// AssertMetricsGolden renders metrics with Render and compares them with the golden file at path.
+func AssertMetricsGolden(t testing.TB, path string, metrics pdata.Metrics) bool {
+	t.Helper()
+	return AssertGolden(t, path, Render(metrics))
+}
+
+// AssertSinkGolden renders every batch of metrics consumed by sink with RenderAll and
+// compares them with the golden file at path.
+func AssertSinkGolden(t testing.TB, path string, sink *Sink) bool {
+	t.Helper()
+	return AssertGolden(t, path, RenderAll(sink.AllMetrics()))
+}
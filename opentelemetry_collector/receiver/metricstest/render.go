@@ -0,0 +1,182 @@
+package metricstest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// Render formats metrics as text that only depends on their content: resources, metrics
+// and points are sorted, attributes are sorted by key and timestamps are written in UTC.
+// Each point is a single line, so that a diff points at the values that changed.
+func Render(metrics pdata.Metrics) string {
+	var resources []string
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		resources = append(resources, renderResourceMetrics(rms.At(i)))
+	}
+	sort.Strings(resources)
+	return strings.Join(resources, "")
+}
+
+// RenderAll renders each batch of metrics with Render, numbering them in order.
+func RenderAll(batches []pdata.Metrics) string {
+	var b strings.Builder
+	for i, metrics := range batches {
+		fmt.Fprintf(&b, "# batch %d\n", i+1)
+		b.WriteString(Render(metrics))
+	}
+	return b.String()
+}
+
+func renderResourceMetrics(rm pdata.ResourceMetrics) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "resource %s\n", renderAttributes(rm.Resource().Attributes()))
+
+	var libraries []string
+	ilms := rm.InstrumentationLibraryMetrics()
+	for i := 0; i < ilms.Len(); i++ {
+		ilm := ilms.At(i)
+		var lb strings.Builder
+		library := ilm.InstrumentationLibrary()
+		if library.Name() != "" || library.Version() != "" {
+			fmt.Fprintf(&lb, "  library %s %s\n", library.Name(), library.Version())
+		}
+
+		var rendered []string
+		metrics := ilm.Metrics()
+		for j := 0; j < metrics.Len(); j++ {
+			rendered = append(rendered, renderMetric(metrics.At(j)))
+		}
+		sort.Strings(rendered)
+		lb.WriteString(strings.Join(rendered, ""))
+		libraries = append(libraries, lb.String())
+	}
+	sort.Strings(libraries)
+	b.WriteString(strings.Join(libraries, ""))
+	return b.String()
+}
+
+func renderMetric(metric pdata.Metric) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  metric %s\n", metric.Name())
+	fmt.Fprintf(&b, "    description: %s\n", metric.Description())
+	fmt.Fprintf(&b, "    unit: %s\n", metric.Unit())
+
+	var points []string
+	switch metric.DataType() {
+	case pdata.MetricDataTypeGauge:
+		b.WriteString("    type: Gauge\n")
+		points = renderNumberDataPoints(metric.Gauge().DataPoints())
+	case pdata.MetricDataTypeSum:
+		sum := metric.Sum()
+		fmt.Fprintf(&b, "    type: Sum %s monotonic=%t\n", formatTemporality(sum.AggregationTemporality()), sum.IsMonotonic())
+		points = renderNumberDataPoints(sum.DataPoints())
+	case pdata.MetricDataTypeHistogram:
+		histogram := metric.Histogram()
+		fmt.Fprintf(&b, "    type: Histogram %s\n", formatTemporality(histogram.AggregationTemporality()))
+		points = renderHistogramDataPoints(histogram.DataPoints())
+	case pdata.MetricDataTypeSummary:
+		b.WriteString("    type: Summary\n")
+		points = renderSummaryDataPoints(metric.Summary().DataPoints())
+	default:
+		fmt.Fprintf(&b, "    type: %s (points not rendered)\n", metric.DataType())
+	}
+
+	sort.Strings(points)
+	for _, point := range points {
+		fmt.Fprintf(&b, "    point %s\n", point)
+	}
+	return b.String()
+}
+
+func renderNumberDataPoints(points pdata.NumberDataPointSlice) []string {
+	rendered := make([]string, points.Len())
+	for i := range rendered {
+		point := points.At(i)
+		var value string
+		switch point.ValueType() {
+		case pdata.MetricValueTypeInt:
+			value = strconv.FormatInt(point.IntVal(), 10)
+		case pdata.MetricValueTypeDouble:
+			value = formatFloat(point.DoubleVal())
+		}
+		rendered[i] = fmt.Sprintf("%s value=%s",
+			renderPointHeader(point.Attributes(), point.StartTimestamp(), point.Timestamp()), value)
+	}
+	return rendered
+}
+
+func renderHistogramDataPoints(points pdata.HistogramDataPointSlice) []string {
+	rendered := make([]string, points.Len())
+	for i := range rendered {
+		point := points.At(i)
+		rendered[i] = fmt.Sprintf("%s count=%d sum=%s bounds=%s buckets=%v",
+			renderPointHeader(point.Attributes(), point.StartTimestamp(), point.Timestamp()),
+			point.Count(),
+			formatFloat(point.Sum()),
+			formatFloats(point.ExplicitBounds()),
+			point.BucketCounts())
+	}
+	return rendered
+}
+
+func renderSummaryDataPoints(points pdata.SummaryDataPointSlice) []string {
+	rendered := make([]string, points.Len())
+	for i := range rendered {
+		point := points.At(i)
+		quantiles := make([]string, point.QuantileValues().Len())
+		for j := range quantiles {
+			quantile := point.QuantileValues().At(j)
+			quantiles[j] = formatFloat(quantile.Quantile()) + ":" + formatFloat(quantile.Value())
+		}
+		rendered[i] = fmt.Sprintf("%s count=%d sum=%s quantiles=[%s]",
+			renderPointHeader(point.Attributes(), point.StartTimestamp(), point.Timestamp()),
+			point.Count(),
+			formatFloat(point.Sum()),
+			strings.Join(quantiles, " "))
+	}
+	return rendered
+}
+
+// renderPointHeader formats the attributes first, so that the points of a metric are sorted by them.
+func renderPointHeader(attributes pdata.AttributeMap, start, timestamp pdata.Timestamp) string {
+	return fmt.Sprintf("%s start=%s time=%s", renderAttributes(attributes), formatTimestamp(start), formatTimestamp(timestamp))
+}
+
+func renderAttributes(attributes pdata.AttributeMap) string {
+	var pairs []string
+	attributes.Range(func(key string, value pdata.AttributeValue) bool {
+		pairs = append(pairs, key+"="+strconv.Quote(value.AsString()))
+		return true
+	})
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func formatTemporality(temporality pdata.MetricAggregationTemporality) string {
+	return strings.TrimPrefix(temporality.String(), "AGGREGATION_TEMPORALITY_")
+}
+
+func formatTimestamp(timestamp pdata.Timestamp) string {
+	if timestamp == 0 {
+		return "unset"
+	}
+	return timestamp.AsTime().UTC().Format(time.RFC3339Nano)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func formatFloats(values []float64) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = formatFloat(value)
+	}
+	return "[" + strings.Join(formatted, " ") + "]"
+}
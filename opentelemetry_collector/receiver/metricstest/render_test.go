@@ -0,0 +1,93 @@
+package metricstest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+)
+
+var (
+	testStartTime = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	testNow       = testStartTime.Add(time.Minute)
+
+	requestsMetric = &metricgenerator.MetricDescriptor{
+		Name:        "requests",
+		Description: "The number of requests",
+		Unit:        "1",
+		Type:        metricgenerator.CumulativeInt64,
+		LabelKeys:   []metricgenerator.LabelKey{{Key: "status"}},
+	}
+	latencyMetric = &metricgenerator.MetricDescriptor{
+		Name:        "latency",
+		Description: "The request latency",
+		Unit:        "ms",
+		Type:        metricgenerator.CumulativeDistribution,
+	}
+	cpuMetric = &metricgenerator.MetricDescriptor{
+		Name:        "cpu",
+		Description: "The CPU usage",
+		Unit:        "1",
+		Type:        metricgenerator.GaugeDouble,
+	}
+)
+
+// testMetrics builds the same metrics in the order or in reverse.
+func testMetrics(reverse bool) pdata.Metrics {
+	adders := []func(builder *metricgenerator.MetricsBuilder){
+		func(builder *metricgenerator.MetricsBuilder) {
+			metric := builder.AddMetric(requestsMetric)
+			statuses := []string{"200", "500"}
+			values := []int64{7, 3}
+			for i := range statuses {
+				if reverse {
+					i = len(statuses) - 1 - i
+				}
+				metric.AddInt64Point(values[i], testStartTime, testNow, map[string]string{"status": statuses[i]})
+			}
+		},
+		func(builder *metricgenerator.MetricsBuilder) {
+			builder.AddMetric(latencyMetric).AddDistributionPoint([]int64{1, 2, 0}, []float64{2, 4}, 7.5, 3, testStartTime, testNow, nil)
+		},
+		func(builder *metricgenerator.MetricsBuilder) {
+			builder.AddMetric(cpuMetric).AddDoublePoint(0.25, testStartTime, testNow, nil)
+		},
+	}
+
+	builder := metricgenerator.NewMetricsBuilder()
+	for i := range adders {
+		if reverse {
+			i = len(adders) - 1 - i
+		}
+		adders[i](builder)
+	}
+	return builder.Metrics()
+}
+
+func TestRender(t *testing.T) {
+	AssertMetricsGolden(t, "testdata/render.golden", testMetrics(false))
+}
+
+func TestRenderIsSorted(t *testing.T) {
+	assert.Equal(t, Render(testMetrics(false)), Render(testMetrics(true)))
+}
+
+func TestSink(t *testing.T) {
+	sink := &Sink{}
+	assert.Equal(t, 0, sink.Last().MetricCount())
+
+	first := testMetrics(false)
+	second := pdata.NewMetrics()
+	assert.NoError(t, sink.ConsumeMetrics(context.Background(), first))
+	assert.NoError(t, sink.ConsumeMetrics(context.Background(), second))
+	assert.Equal(t, []pdata.Metrics{first, second}, sink.AllMetrics())
+	assert.Equal(t, second, sink.Last())
+	assert.Equal(t, "# batch 1\n"+Render(first)+"# batch 2\n", RenderAll(sink.AllMetrics()))
+
+	sink.Reset()
+	assert.Empty(t, sink.AllMetrics())
+}
@@ -0,0 +1,53 @@
+package metricstest
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// Sink is a consumer.Metrics that keeps every batch of metrics it is sent.
+// It is safe to use from the goroutine of a receiver's scrape loop.
+type Sink struct {
+	mu      sync.Mutex
+	batches []pdata.Metrics
+}
+
+// Capabilities returns the consumer capabilities of the sink, which does not modify the metrics.
+func (s *Sink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// ConsumeMetrics stores the metrics.
+func (s *Sink) ConsumeMetrics(ctx context.Context, metrics pdata.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, metrics)
+	return nil
+}
+
+// AllMetrics returns every batch of metrics consumed so far, in the order they were sent.
+func (s *Sink) AllMetrics() []pdata.Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]pdata.Metrics(nil), s.batches...)
+}
+
+// Last returns the last batch of metrics consumed, or empty metrics if there was none.
+func (s *Sink) Last() pdata.Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.batches) == 0 {
+		return pdata.NewMetrics()
+	}
+	return s.batches[len(s.batches)-1]
+}
+
+// Reset drops the metrics consumed so far.
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = nil
+}
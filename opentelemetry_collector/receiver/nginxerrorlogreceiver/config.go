@@ -0,0 +1,26 @@
+package nginxerrorlogreceiver
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/config"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
+)
+
+// Config defines the configuration for the nginx error log receiver.
+type Config struct {
+	config.ReceiverSettings `mapstructure:",squash"`
+	scrapeloop.Settings     `mapstructure:",squash"`
+	ExportInterval          time.Duration `mapstructure:"export_interval"`
+	// ErrorLogPath is the path of the nginx error log to tail.
+	ErrorLogPath string `mapstructure:"error_log_path"`
+	// PositionFile is where the read position in the error log is saved so that
+	// it survives collector restarts. If empty, the position is not saved and
+	// reading starts over when the collector starts.
+	PositionFile string `mapstructure:"position_file"`
+	// ReadFromBeginning makes the collector read the entries already in the error
+	// log when there is no saved position. By default only the entries written
+	// after the collector starts are counted.
+	ReadFromBeginning bool `mapstructure:"read_from_beginning"`
+}
@@ -0,0 +1,43 @@
+package nginxerrorlogreceiver
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/service/servicetest"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
+)
+
+func TestLoadConfig(t *testing.T) {
+	factories, err := componenttest.NopFactories()
+	assert.Nil(t, err)
+
+	factory := NewFactory()
+	factories.Receivers[typeStr] = factory
+	cfg, err := servicetest.LoadConfigAndValidate(path.Join(".", "testdata", "config.yaml"), factories)
+
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	assert.Equal(t, len(cfg.Receivers), 2)
+
+	defaultReceiver := cfg.Receivers[config.NewComponentID("nginxerrorlog")]
+	assert.Equal(t, defaultReceiver, factory.CreateDefaultConfig())
+
+	customReceiver := cfg.Receivers[config.NewComponentIDWithName("nginxerrorlog", "customname")]
+	assert.Equal(t, customReceiver,
+		&Config{
+			ReceiverSettings:  config.NewReceiverSettings(config.NewComponentIDWithName("nginxerrorlog", "customname")),
+			ExportInterval:    10 * time.Minute,
+			Settings:          scrapeloop.Settings{InitialDelay: 5 * time.Second, Jitter: 10 * time.Second, Timeout: 30 * time.Second},
+			ErrorLogPath:      "/var/log/app_engine/nginx/error.log",
+			PositionFile:      "/var/lib/otel/nginx_error_log.position",
+			ReadFromBeginning: true,
+		})
+}
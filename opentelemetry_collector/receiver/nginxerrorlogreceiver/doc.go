@@ -0,0 +1,5 @@
+// Package nginxerrorlogreceiver tails the nginx error log, classifies each
+// entry into a known error category and generates cumulative metrics counting
+// the entries by category and severity.
+// It is a metric receiver designed to work with OpenTelemetry Collector.
+package nginxerrorlogreceiver
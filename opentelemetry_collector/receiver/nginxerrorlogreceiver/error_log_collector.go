@@ -0,0 +1,156 @@
+package nginxerrorlogreceiver
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
+)
+
+// ErrorLogCollector is a struct that generates metrics by tailing the nginx error log.
+type ErrorLogCollector struct {
+	consumer consumer.Metrics
+
+	now        func() time.Time
+	startTime  time.Time
+	controller *scrapeloop.Controller
+	logger     *zap.Logger
+	tailer     *logTailer
+
+	counts map[errorKey]int64
+}
+
+// errorKey identifies the timeseries an error log entry is counted in.
+type errorKey struct {
+	category string
+	severity string
+}
+
+// NewErrorLogCollector creates a new ErrorLogCollector that generates metrics
+// based on the entries of the nginx error log at errorLogPath. Unless readFromBeginning
+// is set, the entries already in the log are skipped when there is no saved position.
+func NewErrorLogCollector(scrapeCfg scrapeloop.Config, errorLogPath, positionFile string, readFromBeginning bool, logger *zap.Logger, consumer consumer.Metrics) (*ErrorLogCollector, error) {
+	if scrapeCfg.Interval <= 0 {
+		return nil, errors.New("ExportInterval must be greater than 0")
+	}
+
+	if errorLogPath == "" {
+		return nil, errors.New("ErrorLogPath must be set")
+	}
+
+	collector := &ErrorLogCollector{
+		consumer: consumer,
+		now:      time.Now,
+		logger:   logger,
+		tailer:   newLogTailer(errorLogPath, positionFile, readFromBeginning),
+		counts:   make(map[errorKey]int64),
+	}
+
+	controller, err := scrapeloop.NewController(scrapeCfg, collector.scrapeAndExport)
+	if err != nil {
+		return nil, err
+	}
+	collector.controller = controller
+	return collector, nil
+}
+
+// StartCollection starts a go routine that reads the new error log entries right away and
+// then periodically, and exports metrics based on them.
+func (collector *ErrorLogCollector) StartCollection() {
+	collector.startTime = collector.now()
+	collector.controller.Start()
+}
+
+// StopCollection stops tailing the error log and the export of the metrics, and waits for
+// an export in progress to finish or for ctx to be done.
+func (collector *ErrorLogCollector) StopCollection(ctx context.Context) error {
+	if err := collector.controller.Shutdown(ctx); err != nil {
+		// The export in progress may still be reading the error log.
+		return err
+	}
+	collector.tailer.close()
+	return nil
+}
+
+// classifyLine returns the category and severity of an error log entry.
+// It returns false if the line is not the start of an error log entry.
+func classifyLine(line string) (errorKey, bool) {
+	match := errorLogLinePattern.FindStringSubmatchIndex(line)
+	if match == nil {
+		return errorKey{}, false
+	}
+
+	severity := line[match[2]:match[3]]
+	if !knownSeverities[severity] {
+		severity = severityUnknown
+	}
+
+	message := line[match[1]:]
+	for _, category := range errorCategories {
+		if category.pattern.MatchString(message) {
+			return errorKey{category: category.name, severity: severity}, true
+		}
+	}
+	return errorKey{category: categoryOther, severity: severity}, true
+}
+
+func (collector *ErrorLogCollector) countLine(line string) {
+	if key, ok := classifyLine(line); ok {
+		collector.counts[key]++
+	}
+}
+
+func (collector *ErrorLogCollector) makeMetrics() *metricgenerator.MetricsBuilder {
+	builder := metricgenerator.NewMetricsBuilder()
+	if len(collector.counts) == 0 {
+		return builder
+	}
+
+	keys := make([]errorKey, 0, len(collector.counts))
+	for key := range collector.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].category != keys[j].category {
+			return keys[i].category < keys[j].category
+		}
+		return keys[i].severity < keys[j].severity
+	})
+
+	now := collector.now()
+	metric := builder.AddMetric(errorLogEntriesMetric)
+	for _, key := range keys {
+		attributes := map[string]string{
+			categoryLabel.Key: key.category,
+			severityLabel.Key: key.severity,
+		}
+		metric.AddInt64Point(collector.counts[key], collector.startTime, now, attributes)
+	}
+	return builder
+}
+
+func (collector *ErrorLogCollector) scrapeAndExport(ctx context.Context) {
+	err := collector.tailer.readLines(collector.countLine)
+	if err != nil {
+		collector.logger.Error("Could not read the nginx error log", zap.Error(err))
+	}
+
+	if err = collector.tailer.savePosition(); err != nil {
+		collector.logger.Error("Could not save the nginx error log position", zap.Error(err))
+	}
+
+	metrics := collector.makeMetrics().Metrics()
+	if err := metricRegistry.Validate(metrics); err != nil {
+		collector.logger.Error("Generated metrics do not match their descriptors", zap.Error(err))
+	}
+	err = collector.consumer.ConsumeMetrics(ctx, metrics)
+	if err != nil {
+		collector.logger.Error("Error sending nginx error log metrics", zap.Error(err))
+	}
+}
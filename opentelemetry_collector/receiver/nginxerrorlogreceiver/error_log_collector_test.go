@@ -0,0 +1,132 @@
+package nginxerrorlogreceiver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
+)
+
+const testErrorLog = `2020/01/01 00:00:00 [error] 7#7: *1 connect() failed (111: Connection refused) while connecting to upstream, client: 10.0.0.1, server: , request: "GET / HTTP/1.1", upstream: "http://172.17.0.1:8080/"
+2020/01/01 00:00:01 [error] 7#7: *2 upstream timed out (110: Connection timed out) while reading response header from upstream, client: 10.0.0.1
+2020/01/01 00:00:02 [error] 7#7: *3 no live upstreams while connecting to upstream, client: 10.0.0.1
+2020/01/01 00:00:03 [error] 7#7: *4 connect() failed (111: Connection refused) while connecting to upstream, client: 10.0.0.1
+2020/01/01 00:00:04 [warn] 7#7: *5 an upstream response is buffered to a temporary file
+2020/01/01 00:00:05 [crit] 7#7: *6 connect() failed (113: No route to host) while connecting to upstream
+not an error log entry
+`
+
+func fakeNow() time.Time {
+	t, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+	return t
+}
+
+func newTestCollector(t *testing.T, logPath string, consumer consumer.Metrics) *ErrorLogCollector {
+	collector, err := NewErrorLogCollector(scrapeloop.Config{Interval: time.Minute}, logPath, "", true, zap.NewNop(), consumer)
+	require.NoError(t, err)
+	collector.now = fakeNow
+	collector.startTime = fakeNow()
+	return collector
+}
+
+func TestNewErrorLogCollectorInvalidInterval(t *testing.T) {
+	_, err := NewErrorLogCollector(scrapeloop.Config{}, "/var/log/nginx/error.log", "", false, zap.NewNop(), nil)
+	assert.Error(t, err)
+}
+
+func TestClassifyLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		key     errorKey
+		isEntry bool
+	}{
+		{
+			line:    "2020/01/01 00:00:00 [error] 7#7: *1 connect() failed (111: Connection refused) while connecting to upstream",
+			key:     errorKey{category: categoryConnectionRefused, severity: "error"},
+			isEntry: true,
+		},
+		{
+			line:    "2020/01/01 00:00:00 [crit] 7#7: *1 connect() failed (113: No route to host) while connecting to upstream",
+			key:     errorKey{category: categoryConnectFailed, severity: "crit"},
+			isEntry: true,
+		},
+		{
+			line:    "2020/01/01 00:00:00 [error] 7#7: *1 upstream timed out (110: Connection timed out)",
+			key:     errorKey{category: categoryUpstreamTimedOut, severity: "error"},
+			isEntry: true,
+		},
+		{
+			line:    "2020/01/01 00:00:00 [error] 7#7: *1 no live upstreams while connecting to upstream",
+			key:     errorKey{category: categoryNoLiveUpstreams, severity: "error"},
+			isEntry: true,
+		},
+		{
+			line:    "2020/01/01 00:00:00 [error] 7#7: *1 upstream prematurely closed connection while reading response header",
+			key:     errorKey{category: categoryUpstreamPrematurelyClosed, severity: "error"},
+			isEntry: true,
+		},
+		{
+			line:    "2020/01/01 00:00:00 [notice] 1#1: signal process started",
+			key:     errorKey{category: categoryOther, severity: "notice"},
+			isEntry: true,
+		},
+		{
+			line:    "2020/01/01 00:00:00 [bogus] 1#1: connect() failed",
+			key:     errorKey{category: categoryConnectFailed, severity: severityUnknown},
+			isEntry: true,
+		},
+		{
+			line:    "connect() failed (111: Connection refused)",
+			isEntry: false,
+		},
+	}
+
+	for _, tc := range tests {
+		key, isEntry := classifyLine(tc.line)
+		assert.Equal(t, tc.isEntry, isEntry, tc.line)
+		assert.Equal(t, tc.key, key, tc.line)
+	}
+}
+
+func TestScrapeAndExport(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "error.log")
+	appendToFile(t, logPath, testErrorLog)
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(t, logPath, sink)
+	defer collector.tailer.close()
+
+	collector.scrapeAndExport(context.Background())
+
+	assert.NoError(t, metricRegistry.Validate(sink.Last()))
+	metricstest.AssertMetricsGolden(t, "testdata/scrape_and_export.golden", sink.Last())
+}
+
+func TestScrapeAndExportIsCumulative(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "error.log")
+	appendToFile(t, logPath, testErrorLog)
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(t, logPath, sink)
+	defer collector.tailer.close()
+
+	collector.scrapeAndExport(context.Background())
+	appendToFile(t, logPath, "2020/01/01 00:01:00 [error] 7#7: *7 connect() failed (111: Connection refused) while connecting to upstream\n")
+	collector.scrapeAndExport(context.Background())
+
+	metricstest.AssertSinkGolden(t, "testdata/scrape_and_export_is_cumulative.golden", sink)
+}
+
+func TestScrapeAndExportMissingLog(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(t, filepath.Join(t.TempDir(), "error.log"), sink)
+
+	collector.scrapeAndExport(context.Background())
+	assert.Equal(t, 0, sink.Last().MetricCount())
+}
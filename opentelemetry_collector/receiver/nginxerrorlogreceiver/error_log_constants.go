@@ -0,0 +1,69 @@
+package nginxerrorlogreceiver
+
+import (
+	"regexp"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+)
+
+// metricRegistry holds the descriptors of the metrics generated by the nginx error log receiver.
+var metricRegistry = metricgenerator.NewRegistry()
+
+var categoryLabel = metricgenerator.LabelKey{
+	Key:         "category",
+	Description: "The category of the error, derived from the error message",
+}
+
+var severityLabel = metricgenerator.LabelKey{
+	Key:         "severity",
+	Description: "The nginx log level of the error log entry",
+}
+
+var errorLogEntriesMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "nginx/error_log_entries",
+	Description: "The number of entries written to the nginx error log, by error category and severity.",
+	Unit:        "1",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{categoryLabel, severityLabel},
+})
+
+const (
+	categoryConnectionRefused         = "connection_refused"
+	categoryConnectFailed             = "connect_failed"
+	categoryUpstreamTimedOut          = "upstream_timed_out"
+	categoryNoLiveUpstreams           = "no_live_upstreams"
+	categoryUpstreamPrematurelyClosed = "upstream_prematurely_closed"
+	categoryOther                     = "other"
+
+	severityUnknown = "unknown"
+)
+
+type errorCategory struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// errorCategories are matched against each error log message in order, the
+// first match wins. Messages that match none of them are counted as other.
+var errorCategories = []errorCategory{
+	{categoryConnectionRefused, regexp.MustCompile(`connect\(\) failed \(111: Connection refused\)`)},
+	{categoryConnectFailed, regexp.MustCompile(`connect\(\) failed`)},
+	{categoryUpstreamTimedOut, regexp.MustCompile(`upstream timed out`)},
+	{categoryNoLiveUpstreams, regexp.MustCompile(`no live upstreams`)},
+	{categoryUpstreamPrematurelyClosed, regexp.MustCompile(`upstream prematurely closed connection`)},
+}
+
+// errorLogLinePattern matches the prefix nginx writes on every error log entry,
+// for example "2020/01/01 00:00:00 [error] 7#7: ", and captures the severity.
+var errorLogLinePattern = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} \[(\w+)\] `)
+
+var knownSeverities = map[string]bool{
+	"debug":  true,
+	"info":   true,
+	"notice": true,
+	"warn":   true,
+	"error":  true,
+	"crit":   true,
+	"alert":  true,
+	"emerg":  true,
+}
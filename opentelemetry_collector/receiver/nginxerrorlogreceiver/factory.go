@@ -0,0 +1,57 @@
+package nginxerrorlogreceiver
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
+)
+
+const (
+	typeStr = "nginxerrorlog"
+
+	defaultErrorLogPath = "/var/log/nginx/error.log"
+)
+
+// CreateDefaultConfig creates the default configuration for the receiver.
+func createDefaultConfig() config.Receiver {
+	return &Config{
+		ReceiverSettings: config.NewReceiverSettings(config.NewComponentID(typeStr)),
+		ExportInterval:   time.Minute,
+		ErrorLogPath:     defaultErrorLogPath,
+	}
+}
+
+// CreateMetricsReceiver creates a metrics receiver based on the provided config.
+func createMetricsReceiver(
+	ctx context.Context,
+	params component.ReceiverCreateSettings,
+	config config.Receiver,
+	consumer consumer.Metrics,
+) (component.MetricsReceiver, error) {
+
+	cfg := config.(*Config)
+	collector, err := NewErrorLogCollector(scrapeloop.Config{Interval: cfg.ExportInterval, Settings: cfg.Settings}, cfg.ErrorLogPath, cfg.PositionFile, cfg.ReadFromBeginning, params.Logger, consumer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	receiver := &Receiver{
+		errorLogCollector: collector,
+	}
+
+	return receiver, nil
+}
+
+// NewFactory creates and returns a factory for the nginx error log receiver.
+func NewFactory() component.ReceiverFactory {
+	return component.NewReceiverFactory(
+		typeStr,
+		createDefaultConfig,
+		component.WithMetricsReceiver(createMetricsReceiver))
+}
@@ -0,0 +1,328 @@
+package nginxerrorlogreceiver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// fingerprintSize is the maximum number of bytes from the start of the log
+	// used to recognise the same file again, after a collector restart or a truncation.
+	fingerprintSize = 256
+	// maxLineLength is the maximum length of the lines returned. Longer lines are cut,
+	// so that a runaway line does not have to be held in memory.
+	maxLineLength = 16 * 1024
+	// readBufferSize is the size of the chunks the log is read in.
+	readBufferSize = 64 * 1024
+)
+
+// logPosition is the read position saved in the position file.
+type logPosition struct {
+	Offset int64 `json:"offset"`
+	// FingerprintLength is the number of bytes from the start of the file that
+	// Fingerprint was computed over.
+	FingerprintLength int64  `json:"fingerprint_length"`
+	Fingerprint       string `json:"fingerprint"`
+}
+
+// logTailer reads the complete lines appended to a log file since the last read.
+// It follows the file across rotation, both when it is moved aside and
+// recreated and when it is truncated in place.
+type logTailer struct {
+	path         string
+	positionFile string
+	// startAtEnd is set until the log is first opened, if the lines already in
+	// it are to be skipped when there is no saved position.
+	startAtEnd bool
+
+	file   *os.File
+	offset int64
+	// fingerprintLength and fingerprint identify the content read so far, to
+	// recognise a truncation even when the file has grown past offset again.
+	fingerprintLength int64
+	fingerprint       string
+}
+
+func newLogTailer(path, positionFile string, readFromBeginning bool) *logTailer {
+	return &logTailer{
+		path:         path,
+		positionFile: positionFile,
+		startAtEnd:   !readFromBeginning,
+	}
+}
+
+// readLines calls handle with each complete line written to the log since the previous call.
+// A line that has not been terminated yet is left to be read by a later call.
+// The log is read in chunks, so the lines are never all held in memory at once.
+func (t *logTailer) readLines(handle func(line string)) error {
+	if t.file == nil {
+		opened, err := t.open(true)
+		// A log created after the collector started only holds new lines.
+		t.startAtEnd = false
+		if err != nil || !opened {
+			return err
+		}
+	}
+
+	pathInfo, err := os.Stat(t.path)
+	if os.IsNotExist(err) {
+		// The log has been moved aside and not recreated yet. Keep reading
+		// the old file until the new one shows up.
+		return t.readFromOffset(handle)
+	}
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(pathInfo, fileInfo) {
+		// The log was rotated. Drain what is left of the old file before
+		// switching to the new one.
+		if err = t.readFromOffset(handle); err != nil {
+			return err
+		}
+		t.close()
+		if _, err = t.open(false); err != nil {
+			return err
+		}
+		return t.readFromOffset(handle)
+	}
+
+	truncated, err := t.truncated(fileInfo.Size())
+	if err != nil {
+		return err
+	}
+	if truncated {
+		t.offset = 0
+		t.fingerprintLength = 0
+		t.fingerprint = ""
+	}
+	return t.readFromOffset(handle)
+}
+
+// truncated returns whether the open log was truncated in place since it was last read.
+// The start of the log no longer matching the fingerprint also catches a log that was
+// truncated and has grown past the read offset again.
+func (t *logTailer) truncated(size int64) (bool, error) {
+	if size < t.offset {
+		return true, nil
+	}
+	if t.fingerprintLength == 0 {
+		return false, nil
+	}
+	fingerprint, err := t.computeFingerprint(t.fingerprintLength)
+	if err != nil {
+		return false, err
+	}
+	return fingerprint != t.fingerprint, nil
+}
+
+// open opens the log. If resume is set, reading continues from the saved
+// position as long as it still refers to the same file, or starts at the end
+// of the log if there is no saved position and startAtEnd is set.
+// It returns false without an error if the log does not exist.
+func (t *logTailer) open(resume bool) (bool, error) {
+	file, err := os.Open(t.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	t.file = file
+	t.offset = 0
+	t.fingerprintLength = 0
+	t.fingerprint = ""
+
+	if !resume {
+		return true, nil
+	}
+	var position *logPosition
+	if t.positionFile != "" {
+		if position, err = t.loadPosition(); err != nil {
+			return true, err
+		}
+	}
+	if position == nil {
+		if t.startAtEnd {
+			return true, t.skipToEnd()
+		}
+		return true, nil
+	}
+	fingerprint, err := t.computeFingerprint(position.FingerprintLength)
+	if err != nil {
+		return true, err
+	}
+	if fingerprint == position.Fingerprint {
+		t.offset = position.Offset
+		t.fingerprintLength = position.FingerprintLength
+		t.fingerprint = position.Fingerprint
+	}
+	return true, nil
+}
+
+// skipToEnd moves the read offset past the last complete line of the open log.
+// The last line is only looked for in the last readBufferSize bytes.
+func (t *logTailer) skipToEnd() error {
+	info, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	start := info.Size() - readBufferSize
+	if start < 0 {
+		start = 0
+	}
+	buf := make([]byte, info.Size()-start)
+	n, err := t.file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+		t.offset = start + int64(i) + 1
+	} else {
+		t.offset = info.Size()
+	}
+	return t.updateFingerprint()
+}
+
+func (t *logTailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// readFromOffset calls handle with each complete line after the read offset, and moves
+// the offset past them. Lines longer than maxLineLength are cut.
+func (t *logTailer) readFromOffset(handle func(line string)) error {
+	if _, err := t.file.Seek(t.offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReaderSize(t.file, readBufferSize)
+
+	var line []byte
+	var length int64
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		length += int64(len(chunk))
+		if room := maxLineLength - len(line); room > 0 {
+			if len(chunk) > room {
+				line = append(line, chunk[:room]...)
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			// The last line is not complete yet.
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		t.offset += length
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+		handle(string(line))
+		line = line[:0]
+		length = 0
+	}
+	return t.updateFingerprint()
+}
+
+// updateFingerprint extends the fingerprint of the open log to the content read so far,
+// up to fingerprintSize bytes.
+func (t *logTailer) updateFingerprint() error {
+	length := t.offset
+	if length > fingerprintSize {
+		length = fingerprintSize
+	}
+	if length == t.fingerprintLength {
+		return nil
+	}
+	fingerprint, err := t.computeFingerprint(length)
+	if err != nil {
+		return err
+	}
+	t.fingerprintLength = length
+	t.fingerprint = fingerprint
+	return nil
+}
+
+// computeFingerprint hashes the first length bytes of the open log.
+func (t *logTailer) computeFingerprint(length int64) (string, error) {
+	buf := make([]byte, length)
+	n, err := t.file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if int64(n) < length {
+		// The file is shorter than the one the position was saved for.
+		return "", nil
+	}
+	hash := fnv.New64a()
+	hash.Write(buf)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (t *logTailer) loadPosition() (*logPosition, error) {
+	data, err := ioutil.ReadFile(t.positionFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var position logPosition
+	if err = json.Unmarshal(data, &position); err != nil {
+		return nil, fmt.Errorf("invalid position file %s: %v", t.positionFile, err)
+	}
+	return &position, nil
+}
+
+// savePosition writes the current read position to the position file.
+func (t *logTailer) savePosition() error {
+	if t.positionFile == "" || t.file == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(&logPosition{
+		Offset:            t.offset,
+		FingerprintLength: t.fingerprintLength,
+		Fingerprint:       t.fingerprint,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so that a crash never leaves a
+	// partially written position file behind.
+	tmp, err := ioutil.TempFile(filepath.Dir(t.positionFile), filepath.Base(t.positionFile))
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), t.positionFile)
+}
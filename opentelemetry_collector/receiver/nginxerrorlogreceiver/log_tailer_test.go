@@ -0,0 +1,207 @@
+package nginxerrorlogreceiver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendToFile(t *testing.T, path, content string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+// readAllLines returns the lines the tailer reads.
+func readAllLines(tailer *logTailer) ([]string, error) {
+	var lines []string
+	err := tailer.readLines(func(line string) {
+		lines = append(lines, line)
+	})
+	return lines, err
+}
+
+func TestReadLinesMissingFile(t *testing.T) {
+	tailer := newLogTailer(filepath.Join(t.TempDir(), "error.log"), "", true)
+
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Empty(t, lines)
+}
+
+func TestReadLinesOnlyReturnsNewCompleteLines(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "error.log")
+	appendToFile(t, logPath, "line1\nline2\npartial")
+	tailer := newLogTailer(logPath, "", true)
+	defer tailer.close()
+
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line1", "line2"}, lines)
+
+	lines, err = readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Empty(t, lines)
+
+	appendToFile(t, logPath, " line3\nline4\n")
+	lines, err = readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"partial line3", "line4"}, lines)
+}
+
+func TestReadLinesSkipsExistingLines(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "error.log")
+	appendToFile(t, logPath, "line1\nline2\npartial")
+	tailer := newLogTailer(logPath, "", false)
+	defer tailer.close()
+
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Empty(t, lines)
+
+	appendToFile(t, logPath, " line3\nline4\n")
+	lines, err = readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"partial line3", "line4"}, lines)
+}
+
+func TestReadLinesReadsLogCreatedAfterStart(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "error.log")
+	tailer := newLogTailer(logPath, "", false)
+	defer tailer.close()
+
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Empty(t, lines)
+
+	appendToFile(t, logPath, "line1\nline2\n")
+	lines, err = readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line1", "line2"}, lines)
+}
+
+func TestReadLinesAfterRename(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "error.log")
+	appendToFile(t, logPath, "line1\n")
+	tailer := newLogTailer(logPath, "", true)
+	defer tailer.close()
+
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line1"}, lines)
+
+	appendToFile(t, logPath, "line2\n")
+	require.NoError(t, os.Rename(logPath, filepath.Join(dir, "error.log.1")))
+
+	lines, err = readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line2"}, lines)
+
+	appendToFile(t, logPath, "line3\n")
+	lines, err = readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line3"}, lines)
+}
+
+func TestReadLinesAfterTruncate(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "error.log")
+	appendToFile(t, logPath, "line1\nline2\n")
+	tailer := newLogTailer(logPath, "", true)
+	defer tailer.close()
+
+	_, err := readAllLines(tailer)
+	assert.NoError(t, err)
+
+	require.NoError(t, os.Truncate(logPath, 0))
+	appendToFile(t, logPath, "line3\n")
+
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line3"}, lines)
+}
+
+func TestReadLinesAfterTruncateAndGrowth(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "error.log")
+	appendToFile(t, logPath, "line1\nline2\n")
+	tailer := newLogTailer(logPath, "", true)
+	defer tailer.close()
+
+	_, err := readAllLines(tailer)
+	assert.NoError(t, err)
+
+	// The log is truncated and grows past the previous read offset between two reads.
+	require.NoError(t, os.Truncate(logPath, 0))
+	appendToFile(t, logPath, "other line3\nother line4\n")
+
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other line3", "other line4"}, lines)
+}
+
+func TestReadLinesCutsLongLines(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "error.log")
+	long := strings.Repeat("x", 2*readBufferSize)
+	appendToFile(t, logPath, long+"\r\nline2\n")
+	tailer := newLogTailer(logPath, "", true)
+	defer tailer.close()
+
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{long[:maxLineLength], "line2"}, lines)
+
+	appendToFile(t, logPath, "line3\n")
+	lines, err = readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line3"}, lines)
+}
+
+func TestReadLinesResumesFromSavedPosition(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "error.log")
+	positionFile := filepath.Join(dir, "position")
+	appendToFile(t, logPath, "line1\nline2\n")
+
+	tailer := newLogTailer(logPath, positionFile, true)
+	lines, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line1", "line2"}, lines)
+	assert.NoError(t, tailer.savePosition())
+	tailer.close()
+
+	appendToFile(t, logPath, "line3\n")
+
+	restarted := newLogTailer(logPath, positionFile, false)
+	defer restarted.close()
+	lines, err = readAllLines(restarted)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line3"}, lines)
+}
+
+func TestReadLinesIgnoresSavedPositionOfDifferentFile(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "error.log")
+	positionFile := filepath.Join(dir, "position")
+	appendToFile(t, logPath, "line1\nline2\n")
+
+	tailer := newLogTailer(logPath, positionFile, true)
+	_, err := readAllLines(tailer)
+	assert.NoError(t, err)
+	assert.NoError(t, tailer.savePosition())
+	tailer.close()
+
+	require.NoError(t, os.Remove(logPath))
+	appendToFile(t, logPath, "other1\nother2\nother3\n")
+
+	restarted := newLogTailer(logPath, positionFile, false)
+	defer restarted.close()
+	lines, err := readAllLines(restarted)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other1", "other2", "other3"}, lines)
+}
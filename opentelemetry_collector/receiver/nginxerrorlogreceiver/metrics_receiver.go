@@ -0,0 +1,33 @@
+package nginxerrorlogreceiver
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// Receiver is the type that provides Receiver functionality for the nginx error log metrics.
+type Receiver struct {
+	errorLogCollector *ErrorLogCollector
+
+	stopOnce  sync.Once
+	startOnce sync.Once
+}
+
+// Start starts the underlying nginx error log metrics generator.
+func (receiver *Receiver) Start(ctx context.Context, host component.Host) error {
+	receiver.startOnce.Do(func() {
+		receiver.errorLogCollector.StartCollection()
+	})
+	return nil
+}
+
+// Shutdown stops and cancels the underlying nginx error log metrics generator.
+func (receiver *Receiver) Shutdown(ctx context.Context) error {
+	var err error
+	receiver.stopOnce.Do(func() {
+		err = receiver.errorLogCollector.StopCollection(ctx)
+	})
+	return err
+}
@@ -4,11 +4,41 @@ import (
 	"time"
 
 	"go.opentelemetry.io/collector/config"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 // Config defines the configuration for the nginx stats receiver.
 type Config struct {
 	config.ReceiverSettings `mapstructure:",squash"`
+	scrapeloop.Settings     `mapstructure:",squash"`
 	ExportInterval          time.Duration `mapstructure:"export_interval"`
 	StatsURL                string        `mapstructure:"stats_url"`
+	// Format is the format of the stats served at StatsURL, either json for
+	// the nginx_latency_status_module status page or prometheus for the
+	// Prometheus text exposition format.
+	Format string `mapstructure:"format"`
+	// PrometheusHistograms names the histogram families read when Format is prometheus.
+	PrometheusHistograms PrometheusHistograms `mapstructure:"prometheus_histograms"`
+	// LatencyBucketBounds, if set, are the bucket bounds the latency distributions are
+	// exported with. The distributions reported by nginx are re-bucketed onto them,
+	// so the exported distributions keep their shape when the nginx bounds change.
+	LatencyBucketBounds []float64 `mapstructure:"latency_bucket_bounds"`
+	// SizeBucketBounds, if set, are the bucket bounds the request and response size
+	// distributions are exported with, like LatencyBucketBounds.
+	SizeBucketBounds []float64 `mapstructure:"size_bucket_bounds"`
+	// LatencyPercentiles, if set, are the percentiles of the request latency exported as
+	// gauges, estimated from the requests received since the previous export.
+	LatencyPercentiles []float64 `mapstructure:"latency_percentiles"`
+}
+
+// PrometheusHistograms defines which Prometheus histogram families are
+// exported as which nginx latency or size metric. The latency families are expected
+// in milliseconds, or in seconds if their name ends with _seconds.
+type PrometheusHistograms struct {
+	RequestLatency   string `mapstructure:"request_latency"`
+	UpstreamLatency  string `mapstructure:"upstream_latency"`
+	WebsocketLatency string `mapstructure:"websocket_latency"`
+	RequestSize      string `mapstructure:"request_size"`
+	ResponseSize     string `mapstructure:"response_size"`
 }
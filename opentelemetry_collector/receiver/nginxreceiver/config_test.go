@@ -10,6 +10,8 @@ import (
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/service/servicetest"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -33,6 +35,18 @@ func TestLoadConfig(t *testing.T) {
 		&Config{
 			ReceiverSettings: config.NewReceiverSettings(config.NewComponentIDWithName("nginxstats", "customname")),
 			ExportInterval:   10 * time.Minute,
+			Settings:         scrapeloop.Settings{InitialDelay: 5 * time.Second, Jitter: 10 * time.Second, Timeout: 30 * time.Second},
 			StatsURL:         "http://example.com",
+			Format:           "prometheus",
+			PrometheusHistograms: PrometheusHistograms{
+				RequestLatency:   "request_latency_ms",
+				UpstreamLatency:  "upstream_latency_ms",
+				WebsocketLatency: "websocket_latency_ms",
+				RequestSize:      "request_size_bytes",
+				ResponseSize:     "response_size_bytes",
+			},
+			LatencyBucketBounds: []float64{1, 10, 100},
+			SizeBucketBounds:    []float64{100, 1000},
+			LatencyPercentiles:  []float64{50, 95, 99},
 		})
 }
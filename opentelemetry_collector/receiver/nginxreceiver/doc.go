@@ -1,4 +1,5 @@
 // Package nginxreceiver polls the status page provided by the nginx module
-// nginx_latency_status_module and generates metrics based on it.
+// nginx_latency_status_module, or an nginx Prometheus exporter, and generates
+// metrics based on it.
 // It is a metric receiver designed to work with OpenTelemetry Collector.
 package nginxreceiver
@@ -18,6 +18,14 @@ func createDefaultConfig() config.Receiver {
 	return &Config{
 		ReceiverSettings: config.NewReceiverSettings(config.NewComponentID(typeStr)),
 		ExportInterval:   time.Minute,
+		Format:           formatJSON,
+		PrometheusHistograms: PrometheusHistograms{
+			RequestLatency:   "nginx_request_latency_milliseconds",
+			UpstreamLatency:  "nginx_upstream_latency_milliseconds",
+			WebsocketLatency: "nginx_websocket_latency_milliseconds",
+			RequestSize:      "nginx_request_size_bytes",
+			ResponseSize:     "nginx_response_size_bytes",
+		},
 	}
 }
 
@@ -30,7 +38,7 @@ func createMetricsReceiver(
 ) (component.MetricsReceiver, error) {
 
 	cfg := config.(*Config)
-	collector, err := NewNginxStatsCollector(cfg.ExportInterval, cfg.StatsURL, params.Logger, consumer)
+	collector, err := NewNginxStatsCollector(cfg, params.Logger, consumer)
 
 	if err != nil {
 		return nil, err
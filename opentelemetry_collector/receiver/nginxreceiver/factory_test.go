@@ -38,3 +38,20 @@ func TestCreateReceiver(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, mReceiver)
 }
+
+func TestCreateReceiverInvalidFormat(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig()
+	config := cfg.(*Config)
+	config.StatsURL = "http://example.com"
+	config.Format = "xml"
+	params := component.ReceiverCreateSettings{
+		TelemetrySettings: component.TelemetrySettings{
+			Logger: zap.NewNop(),
+		},
+	}
+
+	mReceiver, err := factory.CreateMetricsReceiver(context.Background(), params, cfg, nil)
+	assert.Error(t, err)
+	assert.Nil(t, mReceiver)
+}
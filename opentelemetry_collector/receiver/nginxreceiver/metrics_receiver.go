@@ -25,8 +25,9 @@ func (receiver *Receiver) Start(ctx context.Context, host component.Host) error
 
 // Shutdown stops and cancels the underlying nginx metrics generator.
 func (receiver *Receiver) Shutdown(ctx context.Context) error {
+	var err error
 	receiver.stopOnce.Do(func() {
-		receiver.nginxStatsCollector.StopCollection()
+		err = receiver.nginxStatsCollector.StopCollection(ctx)
 	})
-	return nil
+	return err
 }
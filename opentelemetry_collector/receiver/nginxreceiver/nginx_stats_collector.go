@@ -8,37 +8,76 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/collector/consumer"
 	"go.uber.org/zap"
 
 	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
-
-	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/opencensus"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 // NginxStatsCollector is a struct that generates metrics by polling the nginx status page at statsURL.
 type NginxStatsCollector struct {
 	consumer consumer.Metrics
 
-	now       func() time.Time
-	startTime time.Time
-	done      chan struct{}
-	logger    *zap.Logger
-	getStatus func(string) (resp *http.Response, err error)
-
-	exportInterval time.Duration
-	statsURL       string
+	now        func() time.Time
+	startTime  time.Time
+	controller *scrapeloop.Controller
+	logger     *zap.Logger
+	getStatus  func(ctx context.Context, url string) (resp *http.Response, err error)
+
+	statsURL             string
+	format               string
+	prometheusHistograms PrometheusHistograms
+	// fixedBucketBounds and fixedSizeBucketBounds, if set, are the bucket bounds the latency
+	// and size distributions are exported with, regardless of the bucket bounds reported by nginx.
+	fixedBucketBounds     []float64
+	fixedSizeBucketBounds []float64
+	// latencyPercentiles are the request latency percentiles exported as gauges, and
+	// latencyDeltas turns the cumulative request latency distribution into the
+	// distribution of the requests received since the previous export to estimate them from.
+	latencyPercentiles []float64
+	latencyDeltas      *metricgenerator.CumulativeToDelta
+
+	latencyBounds boundsState
+	sizeBounds    boundsState
+
+	// invalidCounts counts the problems found in the nginx stats by field.
+	invalidCounts    map[string]int64
+	invalidStartTime time.Time
 }
 
+const (
+	formatJSON       = "json"
+	formatPrometheus = "prometheus"
+)
+
 // LatencyStats is a struct to parse the latency stats json into.
 type LatencyStats struct {
 	RequestCount int64   `json:"request_count"`
 	LatencySum   int64   `json:"latency_sum"`
 	SumSquares   int64   `json:"sum_squares"`
 	Distribution []int64 `json:"distribution"`
+
+	// sumSquaresUnknown is set when the stats source does not report the sum of
+	// squares, in which case SumSquares is ignored.
+	sumSquaresUnknown bool
+}
+
+// SizeStats is a struct to parse the request and response body size stats json into.
+type SizeStats struct {
+	RequestCount int64   `json:"request_count"`
+	SizeSum      int64   `json:"size_sum"`
+	SumSquares   int64   `json:"sum_squares"`
+	Distribution []int64 `json:"distribution"`
+
+	// sumSquaresUnknown is set when the stats source does not report the sum of
+	// squares, in which case SumSquares is ignored.
+	sumSquaresUnknown bool
 }
 
 // NginxStats is a struct to parse the nginx stats json into.
@@ -47,59 +86,161 @@ type NginxStats struct {
 	UpstreamLatency     LatencyStats `json:"upstream_latency"`
 	WebsocketLatency    LatencyStats `json:"websocket_latency"`
 	LatencyBucketBounds []float64    `json:"latency_bucket_bounds"`
+	RequestSize         SizeStats    `json:"request_size"`
+	ResponseSize        SizeStats    `json:"response_size"`
+	SizeBucketBounds    []float64    `json:"size_bucket_bounds"`
+}
+
+// distributionStats is the form shared by the latency and size stats that the
+// distribution metrics are generated from.
+type distributionStats struct {
+	count             int64
+	sum               int64
+	sumSquares        int64
+	distribution      []int64
+	sumSquaresUnknown bool
+	// sumField is the name of the sum in the stats json.
+	sumField string
+}
+
+func (stats *LatencyStats) distributionStats() *distributionStats {
+	return &distributionStats{
+		count:             stats.RequestCount,
+		sum:               stats.LatencySum,
+		sumSquares:        stats.SumSquares,
+		distribution:      stats.Distribution,
+		sumSquaresUnknown: stats.sumSquaresUnknown,
+		sumField:          "latency_sum",
+	}
+}
+
+func (stats *SizeStats) distributionStats() *distributionStats {
+	return &distributionStats{
+		count:             stats.RequestCount,
+		sum:               stats.SizeSum,
+		sumSquares:        stats.SumSquares,
+		distribution:      stats.Distribution,
+		sumSquaresUnknown: stats.sumSquaresUnknown,
+		sumField:          "size_sum",
+	}
+}
+
+// distributionValue returns the distribution of the stats with the bucket bounds.
+func (stats *distributionStats) distributionValue(bounds []float64) metricgenerator.DistributionValue {
+	value := metricgenerator.DistributionValue{
+		Count:   stats.count,
+		Sum:     float64(stats.sum),
+		Bounds:  bounds,
+		Buckets: stats.distribution,
+	}
+	if !stats.sumSquaresUnknown {
+		value.SumOfSquaredDeviation = metricgenerator.GetSumOfSquaredDeviationsFromIntDist(stats.sum, stats.sumSquares, stats.count)
+	}
+	return value
+}
+
+// isUnset returns true if none of the size stats were reported, as is the
+// case for versions of the latency module that don't publish them.
+func (stats *SizeStats) isUnset() bool {
+	return stats.RequestCount == -1 && stats.SizeSum == -1 && stats.Distribution == nil
+}
+
+// boundsState tracks the bucket bounds shared by a set of distributions between scrapes.
+type boundsState struct {
+	// bounds are the bucket bounds reported by nginx in the previous scrape.
+	bounds []float64
+	// startTime is the start time of the cumulative series of the distributions.
+	startTime time.Time
+	// counters are the distributions reported by nginx in the previous scrape, by field.
+	counters map[string]*distributionStats
+}
+
+func checkStrictlyIncreasing(name string, bounds []float64) error {
+	if !strictlyIncreasing(bounds) {
+		return fmt.Errorf("%s %v must be strictly increasing", name, bounds)
+	}
+	return nil
 }
 
 // NewNginxStatsCollector creates a new NginxStatsCollector that generates metrics
-// based on nginx stats found by polling the url
-func NewNginxStatsCollector(interval time.Duration, statsURL string, logger *zap.Logger, consumer consumer.Metrics) (*NginxStatsCollector, error) {
-	if interval <= 0 {
+// based on nginx stats found by polling the url in the config.
+func NewNginxStatsCollector(cfg *Config, logger *zap.Logger, consumer consumer.Metrics) (*NginxStatsCollector, error) {
+	if cfg.ExportInterval <= 0 {
 		return nil, errors.New("ExportInterval must be greater than 0")
 	}
 
-	if _, err := url.ParseRequestURI(statsURL); err != nil {
-		return nil, fmt.Errorf("StatsURL %s is not valid: %v", statsURL, err)
+	format := cfg.Format
+	switch format {
+	case "":
+		format = formatJSON
+	case formatJSON, formatPrometheus:
+	default:
+		return nil, fmt.Errorf("Format %q is not valid, must be %s or %s", format, formatJSON, formatPrometheus)
+	}
+
+	if err := checkStrictlyIncreasing("LatencyBucketBounds", cfg.LatencyBucketBounds); err != nil {
+		return nil, err
+	}
+	if err := checkStrictlyIncreasing("SizeBucketBounds", cfg.SizeBucketBounds); err != nil {
+		return nil, err
+	}
+
+	for _, percentile := range cfg.LatencyPercentiles {
+		if !(percentile >= 0 && percentile <= 100) {
+			return nil, fmt.Errorf("LatencyPercentiles %v must be between 0 and 100", cfg.LatencyPercentiles)
+		}
+	}
+
+	if _, err := url.ParseRequestURI(cfg.StatsURL); err != nil {
+		return nil, fmt.Errorf("StatsURL %s is not valid: %v", cfg.StatsURL, err)
 	}
 
 	collector := &NginxStatsCollector{
-		consumer:       consumer,
-		now:            time.Now,
-		done:           make(chan struct{}),
-		logger:         logger,
-		exportInterval: interval,
-		statsURL:       statsURL,
-		getStatus:      http.Get,
+		consumer:              consumer,
+		now:                   time.Now,
+		logger:                logger,
+		statsURL:              cfg.StatsURL,
+		format:                format,
+		prometheusHistograms:  cfg.PrometheusHistograms,
+		fixedBucketBounds:     cfg.LatencyBucketBounds,
+		fixedSizeBucketBounds: cfg.SizeBucketBounds,
+		latencyPercentiles:    cfg.LatencyPercentiles,
+		getStatus:             httpGet,
 	}
 
+	controller, err := scrapeloop.NewController(scrapeloop.Config{Interval: cfg.ExportInterval, Settings: cfg.Settings}, collector.scrapeAndExport)
+	if err != nil {
+		return nil, err
+	}
+	collector.controller = controller
 	return collector, nil
 }
 
-// StartCollection starts a go routine that periodically polls nginx for stats and exports metrics based on them.
+// StartCollection starts a go routine that polls nginx for stats right away and then
+// periodically, and exports metrics based on them.
 func (collector *NginxStatsCollector) StartCollection() {
 	collector.startTime = collector.now()
+	collector.controller.Start()
+}
 
-	go func() {
-		ticker := time.NewTicker(collector.exportInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				collector.scrapeAndExport()
-			case <-collector.done:
-				return
-			}
-		}
-	}()
+// StopCollection stops the polling for nginx stats and the export of the metrics, and
+// waits for an export in progress to finish or for ctx to be done.
+func (collector *NginxStatsCollector) StopCollection(ctx context.Context) error {
+	return collector.controller.Shutdown(ctx)
 }
 
-// StopCollection stops the polling for nginx stats and the export of the metrics.
-func (collector *NginxStatsCollector) StopCollection() {
-	close(collector.done)
+// httpGet is like http.Get but the request is cancelled when ctx is done.
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
 }
 
-// Get the stats from the nginx latency status module and parse them into the NginxStats struct.
-func (collector *NginxStatsCollector) scrapeNginxStats() (*NginxStats, error) {
-	resp, err := collector.getStatus(collector.statsURL)
+// Get the stats from the nginx status page and parse them into the NginxStats struct.
+func (collector *NginxStatsCollector) scrapeNginxStats(ctx context.Context) (*NginxStats, error) {
+	resp, err := collector.getStatus(ctx, collector.statsURL)
 	if err != nil {
 		return nil, err
 	}
@@ -114,14 +255,17 @@ func (collector *NginxStatsCollector) scrapeNginxStats() (*NginxStats, error) {
 		return nil, err
 	}
 
+	if collector.format == formatPrometheus {
+		return readStatsPrometheus(body, collector.prometheusHistograms)
+	}
 	return readStatsJSON(body)
 }
 
-// readStatsJSON parses the stats JSON and sets defaults.
-func readStatsJSON(statsJSON []byte) (*NginxStats, error) {
-	// Setting the default int value to -1 makes it possible to tell when a value is missing from the json
-	// since the regular default is 0, which is a valid value for the stats.
-	stats := NginxStats{
+// newDefaultNginxStats returns NginxStats with every value marked as missing.
+// Setting the default int value to -1 makes it possible to tell when a value is missing from the stats
+// since the regular default is 0, which is a valid value for the stats.
+func newDefaultNginxStats() *NginxStats {
+	return &NginxStats{
 		RequestLatency: LatencyStats{
 			RequestCount: -1,
 			LatencySum:   -1,
@@ -137,96 +281,283 @@ func readStatsJSON(statsJSON []byte) (*NginxStats, error) {
 			LatencySum:   -1,
 			SumSquares:   -1,
 		},
+		RequestSize: SizeStats{
+			RequestCount: -1,
+			SizeSum:      -1,
+			SumSquares:   -1,
+		},
+		ResponseSize: SizeStats{
+			RequestCount: -1,
+			SizeSum:      -1,
+			SumSquares:   -1,
+		},
 	}
-	if err := json.Unmarshal(statsJSON, &stats); err != nil {
-		return nil, err
+}
+
+// readStatsJSON parses the stats JSON and sets defaults.
+func readStatsJSON(statsJSON []byte) (*NginxStats, error) {
+	stats := newDefaultNginxStats()
+	if err := json.Unmarshal(statsJSON, stats); err != nil {
+		return nil, asValidationError(jsonFieldErrors(err, statsJSON))
 	}
-	return &stats, nil
+	return stats, nil
 }
 
-func (collector *NginxStatsCollector) appendDistributionMetric(
-	stats *LatencyStats,
-	bucketOptions *metricspb.DistributionValue_BucketOptions,
-	metrics []*metricspb.Metric,
-	descriptor *metricspb.MetricDescriptor) []*metricspb.Metric {
+func (collector *NginxStatsCollector) addDistributionMetric(
+	builder *metricgenerator.MetricsBuilder,
+	stats *distributionStats,
+	startTime time.Time,
+	bounds []float64,
+	descriptor *metricgenerator.MetricDescriptor) {
 
-	sumSquaredDeviation := metricgenerator.GetSumOfSquaredDeviationsFromIntDist(
-		stats.LatencySum, stats.SumSquares, stats.RequestCount)
-	timeseries := metricgenerator.MakeDistributionTimeSeries(
-		stats.Distribution,
-		float64(stats.LatencySum),
-		sumSquaredDeviation,
-		stats.RequestCount,
-		collector.startTime,
-		collector.now(),
-		bucketOptions,
-		[]*metricspb.LabelValue{},
-	)
-	return append(metrics, &metricspb.Metric{
-		MetricDescriptor: descriptor,
-		Timeseries:       []*metricspb.TimeSeries{timeseries},
-	},
-	)
+	builder.AddMetric(descriptor).AddDistributionValuePoint(stats.distributionValue(bounds), startTime, collector.now(), nil)
 }
 
-func (stats *LatencyStats) checkConsistency(bounds []float64) error {
-	if len(bounds) == 0 || len(stats.Distribution) == 0 {
-		return errors.New("One of the distribution values from the stats json is unset")
+// countInvalidFields adds the invalid fields to the counts exported on the nginx_stats_invalid metric.
+func (collector *NginxStatsCollector) countInvalidFields(errs []FieldError) {
+	if len(errs) == 0 {
+		return
+	}
+	if collector.invalidCounts == nil {
+		collector.invalidCounts = make(map[string]int64)
+		collector.invalidStartTime = collector.startTime
+	}
+	for _, fieldError := range errs {
+		collector.invalidCounts[fieldError.Field]++
 	}
+}
 
-	if len(bounds)+1 != len(stats.Distribution) {
-		return errors.New("The length of the latency distribution and distribution bucket boundaries do not match")
+func (collector *NginxStatsCollector) addInvalidMetric(builder *metricgenerator.MetricsBuilder) {
+	fields := make([]string, 0, len(collector.invalidCounts))
+	for field := range collector.invalidCounts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	now := collector.now()
+	metric := builder.AddMetric(statsInvalidMetric)
+	for _, field := range fields {
+		metric.AddInt64Point(
+			collector.invalidCounts[field],
+			collector.invalidStartTime,
+			now,
+			map[string]string{fieldLabel.Key: field})
 	}
+}
 
-	if stats.RequestCount < 0 {
-		return errors.New("The request count is less than 0")
+// checkBucketBounds starts new cumulative series when the bucket bounds reported by nginx
+// change, since the distributions before and after the change can not be compared.
+// It returns the start time of the cumulative series.
+func (collector *NginxStatsCollector) checkBucketBounds(state *boundsState, name string, bounds []float64) time.Time {
+	if state.startTime.IsZero() {
+		state.startTime = collector.startTime
 	}
+	if len(bounds) == 0 {
+		return state.startTime
+	}
+	if state.bounds != nil && !equalBounds(state.bounds, bounds) {
+		collector.logger.Warn("The nginx "+name+" bucket bounds changed, starting new cumulative series",
+			zap.Float64s("previous_bounds", state.bounds),
+			zap.Float64s("bounds", bounds))
+		state.startTime = collector.now()
+		state.counters = nil
+	}
+	state.bounds = bounds
+	return state.startTime
+}
 
-	if stats.SumSquares < 0 {
-		return errors.New("The sum of squared latencies is less than 0")
+// checkCounterResets starts new cumulative series when a counter of the distributions
+// sharing state went down since the previous scrape, because nginx restarted and reset them
+// or they wrapped around. The counters that went down are counted on nginx_stats_invalid.
+// It returns the start time of the cumulative series.
+func (collector *NginxStatsCollector) checkCounterResets(state *boundsState, distributions []distributionMetric) time.Time {
+	var resets []FieldError
+	counters := make(map[string]*distributionStats, len(distributions))
+	for _, d := range distributions {
+		if previous, ok := state.counters[d.field]; ok {
+			resets = append(resets, countersReset(d.field, previous, d.stats)...)
+		}
+		current := *d.stats
+		current.distribution = append([]int64(nil), d.stats.distribution...)
+		counters[d.field] = &current
 	}
+	state.counters = counters
 
-	if stats.LatencySum < 0 {
-		return errors.New("The sum of latencies is less than 0")
+	if len(resets) > 0 {
+		fields := make([]string, len(resets))
+		for i, reset := range resets {
+			fields[i] = reset.Field
+		}
+		collector.logger.Info("The nginx counters were reset, starting new cumulative series", zap.Strings("fields", fields))
+		collector.countInvalidFields(resets)
+		state.startTime = collector.now()
 	}
+	return state.startTime
+}
 
-	for _, count := range stats.Distribution {
-		if count < 0 {
-			return errors.New("One of the latency distribution counts is less than 0")
+// addBucketBoundsMetric generates an info metric with the bucket bounds reported by nginx as a label.
+func (collector *NginxStatsCollector) addBucketBoundsMetric(
+	builder *metricgenerator.MetricsBuilder,
+	bounds []float64,
+	startTime time.Time,
+	descriptor *metricgenerator.MetricDescriptor) {
+	formatted := make([]string, len(bounds))
+	for i, bound := range bounds {
+		formatted[i] = strconv.FormatFloat(bound, 'g', -1, 64)
+	}
+	builder.AddMetric(descriptor).AddInt64Point(
+		1,
+		startTime,
+		collector.now(),
+		map[string]string{bucketBoundsLabel.Key: strings.Join(formatted, ",")},
+	)
+}
+
+// distributionMetric is a distribution reported by nginx and the metric it is exported as.
+type distributionMetric struct {
+	name       string
+	field      string
+	stats      *distributionStats
+	descriptor *metricgenerator.MetricDescriptor
+}
+
+// addDistributionMetrics validates a set of distributions sharing the same bucket bounds and
+// adds the valid ones to builder, re-bucketed onto fixedBounds if they are set.
+func (collector *NginxStatsCollector) addDistributionMetrics(
+	builder *metricgenerator.MetricsBuilder,
+	distributions []distributionMetric,
+	bounds []float64,
+	boundsField string,
+	fixedBounds []float64,
+	startTime time.Time) {
+
+	boundsErrors := validateBucketBounds(boundsField, bounds)
+	collector.countInvalidFields(boundsErrors)
+
+	exportBounds := bounds
+	if len(fixedBounds) > 0 {
+		exportBounds = fixedBounds
+	}
+
+	for _, d := range distributions {
+		fieldErrors := d.stats.validate(d.field, bounds)
+		collector.countInvalidFields(fieldErrors)
+		if err := asValidationError(append(boundsErrors, fieldErrors...)); err != nil {
+			collector.logger.Error("Invalid value received for "+d.name, zap.Error(err))
+			continue
+		}
+		if len(fixedBounds) > 0 {
+			d.stats.distribution = metricgenerator.RebucketDistribution(d.stats.distribution, bounds, fixedBounds)
+		}
+		collector.addDistributionMetric(builder, d.stats, startTime, exportBounds, d.descriptor)
+		if d.descriptor == requestLatencyMetric && len(collector.latencyPercentiles) > 0 {
+			collector.addLatencyPercentilesMetric(builder, d.stats, startTime, exportBounds)
 		}
 	}
-	return nil
 }
 
-func (collector *NginxStatsCollector) scrapeAndExport() {
-	metrics := make([]*metricspb.Metric, 0, 3)
+// addLatencyPercentilesMetric generates gauges with the percentiles of the request latency
+// since the previous export, estimated from the change of the cumulative distribution.
+func (collector *NginxStatsCollector) addLatencyPercentilesMetric(
+	builder *metricgenerator.MetricsBuilder,
+	stats *distributionStats,
+	startTime time.Time,
+	bounds []float64) {
+
+	if collector.latencyDeltas == nil {
+		// There is a single series, which never goes stale.
+		collector.latencyDeltas = metricgenerator.NewCumulativeToDelta(0)
+	}
+	now := collector.now()
+	delta, deltaStart := collector.latencyDeltas.Distribution(
+		requestLatencyMetric.Name,
+		nil,
+		startTime,
+		now,
+		metricgenerator.DistributionValue{
+			Count:   stats.count,
+			Sum:     float64(stats.sum),
+			Bounds:  bounds,
+			Buckets: stats.distribution,
+		})
+	if delta.Count == 0 || len(bounds) == 0 {
+		// No requests since the previous export, or no buckets to estimate the percentiles from.
+		return
+	}
+
+	metric := builder.AddMetric(requestLatencyPercentilesMetric)
+	for _, percentile := range collector.latencyPercentiles {
+		value, err := delta.Quantile(percentile / 100)
+		if err != nil {
+			collector.logger.Error("Could not estimate the request latency percentile", zap.Float64("percentile", percentile), zap.Error(err))
+			continue
+		}
+		metric.AddDoublePoint(
+			value,
+			deltaStart,
+			now,
+			map[string]string{percentileLabel.Key: strconv.FormatFloat(percentile, 'g', -1, 64)})
+	}
+}
+
+func (collector *NginxStatsCollector) scrapeAndExport(ctx context.Context) {
+	builder := metricgenerator.NewMetricsBuilder()
 
-	stats, err := collector.scrapeNginxStats()
+	stats, err := collector.scrapeNginxStats(ctx)
 	if err != nil {
 		collector.logger.Error("Could not read nginx stats", zap.Error(err))
+		var validationError *ValidationError
+		if errors.As(err, &validationError) {
+			collector.countInvalidFields(validationError.Errors)
+		}
 	} else {
-		bucketOptions := metricgenerator.FormatBucketOptions(stats.LatencyBucketBounds)
-
-		if err = stats.RequestLatency.checkConsistency(stats.LatencyBucketBounds); err != nil {
-			collector.logger.Error("Invalid value received for RequestLatency", zap.Error(err))
-		} else {
-			metrics = collector.appendDistributionMetric(&stats.RequestLatency, bucketOptions, metrics, requestLatencyMetric)
+		latencyDistributions := []distributionMetric{
+			{"RequestLatency", "request_latency", stats.RequestLatency.distributionStats(), requestLatencyMetric},
+			{"WebsocketLatency", "websocket_latency", stats.WebsocketLatency.distributionStats(), websocketLatencyMetric},
+			{"UpstreamLatency", "upstream_latency", stats.UpstreamLatency.distributionStats(), upstreamLatencyMetric},
 		}
-		if err = stats.WebsocketLatency.checkConsistency(stats.LatencyBucketBounds); err != nil {
-			collector.logger.Error("Invalid value received for WebsocketLatency", zap.Error(err))
-		} else {
-			metrics = collector.appendDistributionMetric(&stats.WebsocketLatency, bucketOptions, metrics, websocketLatencyMetric)
+		collector.checkBucketBounds(&collector.latencyBounds, "latency", stats.LatencyBucketBounds)
+		latencyStartTime := collector.checkCounterResets(&collector.latencyBounds, latencyDistributions)
+		collector.addDistributionMetrics(
+			builder,
+			latencyDistributions,
+			stats.LatencyBucketBounds,
+			fieldLatencyBucketBounds,
+			collector.fixedBucketBounds,
+			latencyStartTime)
+		if len(stats.LatencyBucketBounds) > 0 {
+			collector.addBucketBoundsMetric(builder, stats.LatencyBucketBounds, latencyStartTime, latencyBucketBoundsMetric)
 		}
 
-		if err = stats.UpstreamLatency.checkConsistency(stats.LatencyBucketBounds); err != nil {
-			collector.logger.Error("Invalid value received for UpstreamLatency", zap.Error(err))
-		} else {
-			metrics = collector.appendDistributionMetric(&stats.UpstreamLatency, bucketOptions, metrics, upstreamLatencyMetric)
+		// Versions of the latency module that don't publish the size stats leave them all unset.
+		if !stats.RequestSize.isUnset() || !stats.ResponseSize.isUnset() || stats.SizeBucketBounds != nil {
+			sizeDistributions := []distributionMetric{
+				{"RequestSize", "request_size", stats.RequestSize.distributionStats(), requestSizeMetric},
+				{"ResponseSize", "response_size", stats.ResponseSize.distributionStats(), responseSizeMetric},
+			}
+			collector.checkBucketBounds(&collector.sizeBounds, "size", stats.SizeBucketBounds)
+			sizeStartTime := collector.checkCounterResets(&collector.sizeBounds, sizeDistributions)
+			collector.addDistributionMetrics(
+				builder,
+				sizeDistributions,
+				stats.SizeBucketBounds,
+				fieldSizeBucketBounds,
+				collector.fixedSizeBucketBounds,
+				sizeStartTime)
+			if len(stats.SizeBucketBounds) > 0 {
+				collector.addBucketBoundsMetric(builder, stats.SizeBucketBounds, sizeStartTime, sizeBucketBoundsMetric)
+			}
 		}
 	}
 
-	ctx := context.Background()
-	err = collector.consumer.ConsumeMetrics(ctx, opencensus.OCToMetrics(nil, nil, metrics))
+	if len(collector.invalidCounts) > 0 {
+		collector.addInvalidMetric(builder)
+	}
+
+	if err := metricRegistry.Validate(builder.Metrics()); err != nil {
+		collector.logger.Error("Generated metrics do not match their descriptors", zap.Error(err))
+	}
+	err = collector.consumer.ConsumeMetrics(ctx, builder.Metrics())
 	if err != nil {
 		collector.logger.Error("Error sending nginx metrics", zap.Error(err))
 	}
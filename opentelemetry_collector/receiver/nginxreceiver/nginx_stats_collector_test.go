@@ -4,21 +4,17 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"testing"
 	"time"
 
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/opencensus"
 	"github.com/stretchr/testify/assert"
-	"go.opentelemetry.io/collector/model/pdata"
 	"go.uber.org/zap"
-	timestamp "google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
-
-	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
-	"go.opentelemetry.io/collector/consumer"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
 )
 
 func fakeNow() time.Time {
@@ -26,7 +22,7 @@ func fakeNow() time.Time {
 	return t
 }
 
-func fakeHTTPGet(testURL string) (resp *http.Response, err error) {
+func fakeHTTPGet(ctx context.Context, testURL string) (resp *http.Response, err error) {
 	successJSON := `{
   "accepted_connections": 3,
   "handled_connections": 3,
@@ -53,7 +49,20 @@ func fakeHTTPGet(testURL string) (resp *http.Response, err error) {
     "sum_squares": 16,
     "distribution": [0, 0, 1]
   },
-  "latency_bucket_bounds": [2, 4]
+  "latency_bucket_bounds": [2, 4],
+  "request_size":{
+    "size_sum": 300,
+    "request_count": 3,
+    "sum_squares": 50000,
+    "distribution": [1, 2, 0]
+  },
+  "response_size":{
+    "size_sum": 3000,
+    "request_count": 3,
+    "sum_squares": 5000000,
+    "distribution": [0, 1, 2]
+  },
+  "size_bucket_bounds": [100, 1000]
 }`
 	malformattedJSON := "malformatted json requests 0"
 	if testURL == "http://success" {
@@ -64,6 +73,8 @@ func fakeHTTPGet(testURL string) (resp *http.Response, err error) {
 		return getResponseFromJSON(malformattedJSON, 200), nil
 	} else if testURL == "http://unset" {
 		return getResponseFromJSON("{}", 200), nil
+	} else if testURL == "http://prometheus" {
+		return getResponseFromJSON(testPrometheusStats, 200), nil
 	}
 	return nil, errors.New("failed request")
 }
@@ -76,34 +87,17 @@ func getResponseFromJSON(json string, status int) *http.Response {
 	}
 }
 
-type fakeConsumer struct {
-	metrics pdata.Metrics
-}
-
-func (c *fakeConsumer) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{
-		MutatesData: false,
-	}
-}
-
-func (c *fakeConsumer) ConsumeMetrics(ctx context.Context, metrics pdata.Metrics) error {
-	c.metrics = metrics
-	return nil
-}
-
 func TestScrapeNginxStats(t *testing.T) {
 	collector := &NginxStatsCollector{
-		consumer:       &fakeConsumer{},
-		now:            fakeNow,
-		startTime:      fakeNow(),
-		done:           make(chan struct{}),
-		logger:         zap.NewNop(),
-		exportInterval: time.Minute,
-		statsURL:       "http://success",
-		getStatus:      fakeHTTPGet,
+		consumer:  &metricstest.Sink{},
+		now:       fakeNow,
+		startTime: fakeNow(),
+		logger:    zap.NewNop(),
+		statsURL:  "http://success",
+		getStatus: fakeHTTPGet,
 	}
 
-	stats, err := collector.scrapeNginxStats()
+	stats, err := collector.scrapeNginxStats(context.Background())
 
 	expectedStats := &NginxStats{
 		RequestLatency: LatencyStats{
@@ -125,6 +119,19 @@ func TestScrapeNginxStats(t *testing.T) {
 			Distribution: []int64{0, 0, 1},
 		},
 		LatencyBucketBounds: []float64{2, 4},
+		RequestSize: SizeStats{
+			RequestCount: 3,
+			SizeSum:      300,
+			SumSquares:   50000,
+			Distribution: []int64{1, 2, 0},
+		},
+		ResponseSize: SizeStats{
+			RequestCount: 3,
+			SizeSum:      3000,
+			SumSquares:   5000000,
+			Distribution: []int64{0, 1, 2},
+		},
+		SizeBucketBounds: []float64{100, 1000},
 	}
 	assert.Nil(t, err)
 	assert.Equal(t, expectedStats, stats)
@@ -132,17 +139,15 @@ func TestScrapeNginxStats(t *testing.T) {
 
 func TestScrapeNginxStatsUnset(t *testing.T) {
 	collector := &NginxStatsCollector{
-		consumer:       &fakeConsumer{},
-		now:            fakeNow,
-		startTime:      fakeNow(),
-		done:           make(chan struct{}),
-		logger:         zap.NewNop(),
-		exportInterval: time.Minute,
-		statsURL:       "http://unset",
-		getStatus:      fakeHTTPGet,
+		consumer:  &metricstest.Sink{},
+		now:       fakeNow,
+		startTime: fakeNow(),
+		logger:    zap.NewNop(),
+		statsURL:  "http://unset",
+		getStatus: fakeHTTPGet,
 	}
 
-	stats, err := collector.scrapeNginxStats()
+	stats, err := collector.scrapeNginxStats(context.Background())
 
 	expectedStats := &NginxStats{
 		RequestLatency: LatencyStats{
@@ -164,6 +169,19 @@ func TestScrapeNginxStatsUnset(t *testing.T) {
 			Distribution: nil,
 		},
 		LatencyBucketBounds: nil,
+		RequestSize: SizeStats{
+			RequestCount: -1,
+			SizeSum:      -1,
+			SumSquares:   -1,
+			Distribution: nil,
+		},
+		ResponseSize: SizeStats{
+			RequestCount: -1,
+			SizeSum:      -1,
+			SumSquares:   -1,
+			Distribution: nil,
+		},
+		SizeBucketBounds: nil,
 	}
 	assert.Nil(t, err)
 	assert.Equal(t, expectedStats, stats)
@@ -171,285 +189,355 @@ func TestScrapeNginxStatsUnset(t *testing.T) {
 
 func TestScrapeNginxStatsNotFound(t *testing.T) {
 	collector := &NginxStatsCollector{
-		consumer:       &fakeConsumer{},
-		startTime:      fakeNow(),
-		now:            fakeNow,
-		done:           make(chan struct{}),
-		logger:         zap.NewNop(),
-		exportInterval: time.Minute,
-		statsURL:       "http://not_found",
-		getStatus:      fakeHTTPGet,
+		consumer:  &metricstest.Sink{},
+		startTime: fakeNow(),
+		now:       fakeNow,
+		logger:    zap.NewNop(),
+		statsURL:  "http://not_found",
+		getStatus: fakeHTTPGet,
 	}
 
-	_, err := collector.scrapeNginxStats()
+	_, err := collector.scrapeNginxStats(context.Background())
 
 	assert.NotNil(t, err)
 }
 
 func TestScrapeNginxStatsMalformatted(t *testing.T) {
 	collector := &NginxStatsCollector{
-		consumer:       &fakeConsumer{},
-		startTime:      fakeNow(),
-		now:            fakeNow,
-		done:           make(chan struct{}),
-		logger:         zap.NewNop(),
-		exportInterval: time.Minute,
-		statsURL:       "http://malformatted",
-		getStatus:      fakeHTTPGet,
+		consumer:  &metricstest.Sink{},
+		startTime: fakeNow(),
+		now:       fakeNow,
+		logger:    zap.NewNop(),
+		statsURL:  "http://malformatted",
+		getStatus: fakeHTTPGet,
 	}
 
-	_, err := collector.scrapeNginxStats()
+	_, err := collector.scrapeNginxStats(context.Background())
 
 	assert.NotNil(t, err)
 }
 
 func TestScrapeNginxStatsError(t *testing.T) {
 	collector := &NginxStatsCollector{
-		consumer:       &fakeConsumer{},
-		startTime:      fakeNow(),
-		now:            fakeNow,
-		done:           make(chan struct{}),
-		logger:         zap.NewNop(),
-		exportInterval: time.Minute,
-		statsURL:       "http://error",
-		getStatus:      fakeHTTPGet,
+		consumer:  &metricstest.Sink{},
+		startTime: fakeNow(),
+		now:       fakeNow,
+		logger:    zap.NewNop(),
+		statsURL:  "http://error",
+		getStatus: fakeHTTPGet,
 	}
 
-	_, err := collector.scrapeNginxStats()
+	_, err := collector.scrapeNginxStats(context.Background())
 
 	assert.NotNil(t, err)
 }
 
-func TestCheckConsistency(t *testing.T) {
-	stats := LatencyStats{
+func TestAddDistributionMetric(t *testing.T) {
+	collector := &NginxStatsCollector{
+		consumer:  &metricstest.Sink{},
+		now:       fakeNow,
+		startTime: fakeNow(),
+		logger:    zap.NewNop(),
+		statsURL:  "http://success",
+		getStatus: fakeHTTPGet,
+	}
+	stats := &LatencyStats{
 		RequestCount: 3,
-		LatencySum:   8,
-		SumSquares:   24,
+		LatencySum:   9,
+		SumSquares:   33,
 		Distribution: []int64{0, 2, 1},
 	}
-	buckets := []float64{2, 4}
+	builder := metricgenerator.NewMetricsBuilder()
 
-	err := stats.checkConsistency(buckets)
-	assert.Nil(t, err)
+	collector.addDistributionMetric(
+		builder,
+		stats.distributionStats(),
+		fakeNow(),
+		[]float64{2, 4},
+		requestLatencyMetric,
+	)
+
+	metricstest.AssertMetricsGolden(t, "testdata/add_distribution_metric.golden", builder.Metrics())
 }
 
-func TestCheckConsistencyDistributionLengthErr(t *testing.T) {
-	stats := LatencyStats{
+func TestDistributionValue(t *testing.T) {
+	stats := &LatencyStats{
 		RequestCount: 3,
-		LatencySum:   8,
-		SumSquares:   24,
+		LatencySum:   9,
+		SumSquares:   33,
 		Distribution: []int64{0, 2, 1},
 	}
-	buckets := []float64{2, 4, 8}
-
-	err := stats.checkConsistency(buckets)
-	expectedError := errors.New("The length of the latency distribution and distribution bucket boundaries do not match")
-
-	assert.Equal(t, expectedError, err)
+	assert.Equal(t, metricgenerator.DistributionValue{
+		Count:                 3,
+		Sum:                   9,
+		SumOfSquaredDeviation: 6,
+		Bounds:                []float64{2, 4},
+		Buckets:               []int64{0, 2, 1},
+	}, stats.distributionStats().distributionValue([]float64{2, 4}))
 }
 
-func TestCheckConsistencyNegativeRequest(t *testing.T) {
-	stats := LatencyStats{
-		RequestCount: -1,
-		LatencySum:   8,
-		SumSquares:   24,
-		Distribution: []int64{0, 2, 1},
+func TestExportedDistributionToDelta(t *testing.T) {
+	statsJSON := `{
+  "request_latency":{"latency_sum": %d, "request_count": %d, "sum_squares": %d, "distribution": %s},
+  "latency_bucket_bounds": [2, 4]
+}`
+	responses := []string{
+		fmt.Sprintf(statsJSON, 8, 3, 22, "[0, 2, 1]"),
+		fmt.Sprintf(statsJSON, 15, 5, 59, "[1, 2, 2]"),
+	}
+	sink := &metricstest.Sink{}
+	now := fakeNow()
+	collector := &NginxStatsCollector{
+		consumer:  sink,
+		now:       func() time.Time { return now },
+		startTime: fakeNow(),
+		logger:    zap.NewNop(),
+		statsURL:  "http://success",
+		getStatus: func(context.Context, string) (*http.Response, error) {
+			response := responses[0]
+			responses = responses[1:]
+			return getResponseFromJSON(response, 200), nil
+		},
+	}
+	converter := metricgenerator.NewCumulativeToDelta(time.Hour)
+	var deltas []metricgenerator.DistributionValue
+	for i := 0; i < 2; i++ {
+		collector.scrapeAndExport(context.Background())
+		metrics := sink.Last().ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+		for j := 0; j < metrics.Len(); j++ {
+			if metrics.At(j).Name() != requestLatencyMetric.Name {
+				continue
+			}
+			point := metrics.At(j).Histogram().DataPoints().At(0)
+			delta, _ := converter.Distribution(requestLatencyMetric.Name, nil, point.StartTimestamp().AsTime(), point.Timestamp().AsTime(),
+				metricgenerator.DistributionValueFromPoint(point))
+			deltas = append(deltas, delta)
+		}
+		now = now.Add(time.Minute)
 	}
-	buckets := []float64{2, 4}
 
-	err := stats.checkConsistency(buckets)
-	expectedError := errors.New("The request count is less than 0")
-	assert.Equal(t, expectedError, err)
+	// The second delta holds the 2 requests of the second scrape.
+	assert.Equal(t, []metricgenerator.DistributionValue{
+		{Count: 3, Sum: 8, Bounds: []float64{2, 4}, Buckets: []int64{0, 2, 1}},
+		{Count: 2, Sum: 7, Bounds: []float64{2, 4}, Buckets: []int64{1, 0, 1}},
+	}, deltas)
 }
 
-func TestCheckConsistencyNegativeSumSquares(t *testing.T) {
-	stats := LatencyStats{
-		RequestCount: 3,
-		LatencySum:   8,
-		SumSquares:   -1,
-		Distribution: []int64{0, 2, 1},
+func TestScrapeAndExport(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := &NginxStatsCollector{
+		consumer:  sink,
+		now:       fakeNow,
+		startTime: fakeNow(),
+		logger:    zap.NewNop(),
+		statsURL:  "http://success",
+		getStatus: fakeHTTPGet,
 	}
-	buckets := []float64{2, 4}
-
-	err := stats.checkConsistency(buckets)
-	expectedError := errors.New("The sum of squared latencies is less than 0")
-	assert.Equal(t, expectedError, err)
+	collector.scrapeAndExport(context.Background())
+	assert.NoError(t, metricRegistry.Validate(sink.Last()))
+	metricstest.AssertMetricsGolden(t, "testdata/scrape_and_export.golden", sink.Last())
 }
 
-func TestCheckConsistencyNegativeSum(t *testing.T) {
-	stats := LatencyStats{
-		RequestCount: 3,
-		LatencySum:   -1,
-		SumSquares:   24,
-		Distribution: []int64{0, 2, 1},
+func TestScrapeAndExportError(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := &NginxStatsCollector{
+		consumer:  sink,
+		now:       fakeNow,
+		startTime: fakeNow(),
+		logger:    zap.NewNop(),
+		statsURL:  "http://error",
+		getStatus: fakeHTTPGet,
 	}
-	buckets := []float64{2, 4}
-
-	err := stats.checkConsistency(buckets)
-	expectedError := errors.New("The sum of latencies is less than 0")
-	assert.Equal(t, expectedError, err)
+	collector.scrapeAndExport(context.Background())
+	assert.Equal(t, 0, sink.Last().MetricCount())
 }
 
-func TestCheckConsistencyNegativeDistribution(t *testing.T) {
-	stats := LatencyStats{
-		RequestCount: 3,
-		LatencySum:   8,
-		SumSquares:   24,
-		Distribution: []int64{0, 2, -1},
+func TestScrapeAndExportBucketBoundsChange(t *testing.T) {
+	statsJSON := `{
+  "request_latency":{"latency_sum": 8, "request_count": 3, "sum_squares": 24, "distribution": %s},
+  "latency_bucket_bounds": %s
+}`
+	responses := []string{
+		fmt.Sprintf(statsJSON, "[0, 2, 1]", "[2, 4]"),
+		fmt.Sprintf(statsJSON, "[0, 2, 1]", "[2, 4]"),
+		fmt.Sprintf(statsJSON, "[0, 1, 1, 1]", "[2, 4, 8]"),
 	}
-	buckets := []float64{2, 4}
+	startTime := fakeNow()
+	now := startTime
+	sink := &metricstest.Sink{}
+	collector := &NginxStatsCollector{
+		consumer:  sink,
+		now:       func() time.Time { return now },
+		startTime: startTime,
+		logger:    zap.NewNop(),
+		statsURL:  "http://success",
+		getStatus: func(context.Context, string) (*http.Response, error) {
+			response := responses[0]
+			responses = responses[1:]
+			return getResponseFromJSON(response, 200), nil
+		},
+	}
+
+	collector.scrapeAndExport(context.Background())
+	now = now.Add(time.Minute)
+	collector.scrapeAndExport(context.Background())
+	now = now.Add(time.Minute)
+	collector.scrapeAndExport(context.Background())
 
-	err := stats.checkConsistency(buckets)
-	expectedError := errors.New("One of the latency distribution counts is less than 0")
-	assert.Equal(t, expectedError, err)
+	// The third export starts new cumulative series with the new bounds.
+	metricstest.AssertSinkGolden(t, "testdata/bucket_bounds_change.golden", sink)
 }
 
-func TestCheckConsistencyUnsetDistribution(t *testing.T) {
-	stats := LatencyStats{
-		RequestCount: 3,
-		LatencySum:   8,
-		SumSquares:   24,
-		Distribution: nil,
+func TestScrapeAndExportCounterReset(t *testing.T) {
+	statsJSON := `{
+  "request_latency":{"latency_sum": %d, "request_count": 3, "sum_squares": 24, "distribution": [0, 2, 1]},
+  "latency_bucket_bounds": [2, 4]
+}`
+	responses := []string{
+		fmt.Sprintf(statsJSON, 8),
+		fmt.Sprintf(statsJSON, 6),
 	}
-	var buckets []float64
+	startTime := fakeNow()
+	now := startTime
+	sink := &metricstest.Sink{}
+	collector := &NginxStatsCollector{
+		consumer:  sink,
+		now:       func() time.Time { return now },
+		startTime: startTime,
+		logger:    zap.NewNop(),
+		statsURL:  "http://success",
+		getStatus: func(context.Context, string) (*http.Response, error) {
+			response := responses[0]
+			responses = responses[1:]
+			return getResponseFromJSON(response, 200), nil
+		},
+	}
+
+	collector.scrapeAndExport(context.Background())
+	now = now.Add(time.Minute)
+	collector.scrapeAndExport(context.Background())
 
-	err := stats.checkConsistency(buckets)
-	expectedError := errors.New("One of the distribution values from the stats json is unset")
-	assert.Equal(t, expectedError, err)
+	// The reset starts new cumulative series, and is not reported on nginx_stats_invalid.
+	metricstest.AssertSinkGolden(t, "testdata/counter_reset.golden", sink)
 }
 
-func TestAppendDistributionMetric(t *testing.T) {
+func TestScrapeAndExportFixedBucketBounds(t *testing.T) {
+	sink := &metricstest.Sink{}
 	collector := &NginxStatsCollector{
-		consumer:       &fakeConsumer{},
-		now:            fakeNow,
-		startTime:      fakeNow(),
-		done:           make(chan struct{}),
-		logger:         zap.NewNop(),
-		exportInterval: time.Minute,
-		statsURL:       "http://success",
-		getStatus:      fakeHTTPGet,
-	}
-	stats := &LatencyStats{
-		RequestCount: 3,
-		LatencySum:   9,
-		SumSquares:   33,
-		Distribution: []int64{0, 2, 1},
+		consumer:          sink,
+		now:               fakeNow,
+		startTime:         fakeNow(),
+		logger:            zap.NewNop(),
+		statsURL:          "http://success",
+		fixedBucketBounds: []float64{4},
+		getStatus:         fakeHTTPGet,
 	}
-	metrics := []*metricspb.Metric{}
-	bucketOptions := metricgenerator.FormatBucketOptions([]float64{2, 4})
+	collector.scrapeAndExport(context.Background())
+	metricstest.AssertMetricsGolden(t, "testdata/fixed_bucket_bounds.golden", sink.Last())
+}
 
-	metrics = collector.appendDistributionMetric(
-		stats,
-		bucketOptions,
-		metrics,
-		requestLatencyMetric,
-	)
+func TestNewNginxStatsCollectorInvalidFixedBucketBounds(t *testing.T) {
+	cfg := &Config{
+		ExportInterval:      time.Minute,
+		StatsURL:            "http://example.com",
+		LatencyBucketBounds: []float64{4, 2},
+	}
+	_, err := NewNginxStatsCollector(cfg, zap.NewNop(), nil)
+	assert.Error(t, err)
 
-	if assert.Len(t, metrics, 1) {
-		expectedMetricDescriptor := &metricspb.MetricDescriptor{
-			Name:        "on_vm_request_latencies",
-			Description: "The request latency measured at nginx. Includes latency from nginx and the user's app code",
-			Unit:        "milliseconds",
-			Type:        metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION,
-			LabelKeys:   []*metricspb.LabelKey{},
-		}
-		expectedDistribution := &metricspb.DistributionValue{
-			Count:                 3,
-			Sum:                   9,
-			SumOfSquaredDeviation: 6,
-			BucketOptions:         bucketOptions,
-			Buckets:               []*metricspb.DistributionValue_Bucket{{Count: 0}, {Count: 2}, {Count: 1}},
-		}
-		expectedPoint := &metricspb.Point{
-			Timestamp: timestamp.New(fakeNow()),
-			Value: &metricspb.Point_DistributionValue{
-				DistributionValue: expectedDistribution,
-			},
-		}
-		expectedTimeseries := &metricspb.TimeSeries{
-			StartTimestamp: timestamp.New(fakeNow()),
-			LabelValues:    []*metricspb.LabelValue{},
-			Points:         []*metricspb.Point{expectedPoint},
-		}
-		expectedMetric := &metricspb.Metric{
-			MetricDescriptor: expectedMetricDescriptor,
-			Timeseries:       []*metricspb.TimeSeries{expectedTimeseries},
-		}
-		assert.Equal(t, expectedMetric, metrics[0])
+	cfg = &Config{
+		ExportInterval:   time.Minute,
+		StatsURL:         "http://example.com",
+		SizeBucketBounds: []float64{100, 100},
 	}
+	_, err = NewNginxStatsCollector(cfg, zap.NewNop(), nil)
+	assert.Error(t, err)
 }
 
-func checkDistributionMetricValue(t *testing.T, data []*metricspb.Metric, name string, stats *LatencyStats) {
-	found := false
-	for _, metric := range data {
-		if metric.MetricDescriptor.Name == name {
-			found = true
-			distribution := metric.Timeseries[0].Points[0].GetDistributionValue()
-			assert.Equal(t, stats.RequestCount, distribution.Count)
-			assert.Equal(t, float64(stats.LatencySum), distribution.Sum)
-			if assert.Equal(t, len(stats.Distribution), len(distribution.Buckets)) {
-				for i, bucket := range distribution.Buckets {
-					assert.Equal(t, stats.Distribution[i], bucket.Count)
-				}
-			}
-
-		}
-	}
-	if !found {
-		t.Errorf("Unable to find metric %s", name)
+func TestScrapeAndExportSizes(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := &NginxStatsCollector{
+		consumer:              sink,
+		now:                   fakeNow,
+		startTime:             fakeNow(),
+		logger:                zap.NewNop(),
+		statsURL:              "http://success",
+		fixedSizeBucketBounds: []float64{1000},
+		getStatus:             fakeHTTPGet,
 	}
+	collector.scrapeAndExport(context.Background())
+	metricstest.AssertMetricsGolden(t, "testdata/sizes.golden", sink.Last())
 }
 
-func TestScrapeAndExport(t *testing.T) {
-	consumer := &fakeConsumer{}
+func TestScrapeAndExportSizesNotPublished(t *testing.T) {
+	statsJSON := `{
+  "request_latency":{"latency_sum": 8, "request_count": 3, "sum_squares": 24, "distribution": [0, 2, 1]},
+  "upstream_latency":{"latency_sum": 5, "request_count": 3, "sum_squares": 9, "distribution": [1, 2, 0]},
+  "websocket_latency":{"latency_sum": 4, "request_count": 1, "sum_squares": 16, "distribution": [0, 0, 1]},
+  "latency_bucket_bounds": [2, 4]
+}`
+	sink := &metricstest.Sink{}
 	collector := &NginxStatsCollector{
-		consumer:       consumer,
-		now:            fakeNow,
-		startTime:      fakeNow(),
-		done:           make(chan struct{}),
-		logger:         zap.NewNop(),
-		exportInterval: time.Minute,
-		statsURL:       "http://success",
-		getStatus:      fakeHTTPGet,
+		consumer:  sink,
+		now:       fakeNow,
+		startTime: fakeNow(),
+		logger:    zap.NewNop(),
+		statsURL:  "http://success",
+		getStatus: func(context.Context, string) (*http.Response, error) {
+			return getResponseFromJSON(statsJSON, 200), nil
+		},
 	}
-	collector.scrapeAndExport()
-	_, _, data := opencensus.ResourceMetricsToOC(consumer.metrics.ResourceMetrics().At(0))
-	assert.Len(t, data, 3)
-	requestLatency := &LatencyStats{
-		RequestCount: 3,
-		LatencySum:   8,
-		SumSquares:   24,
-		Distribution: []int64{0, 2, 1},
+	collector.scrapeAndExport(context.Background())
+	metricstest.AssertMetricsGolden(t, "testdata/sizes_not_published.golden", sink.Last())
+}
+
+// latencyStatsJSON formats nginx stats with the request latency distribution over the bounds [2, 4].
+func latencyStatsJSON(count, sum, sumSquares int64, distribution string) string {
+	return fmt.Sprintf(`{
+  "request_latency": {"latency_sum": %d, "request_count": %d, "sum_squares": %d, "distribution": %s},
+  "upstream_latency": {"latency_sum": 0, "request_count": 0, "sum_squares": 0, "distribution": [0, 0, 0]},
+  "websocket_latency": {"latency_sum": 0, "request_count": 0, "sum_squares": 0, "distribution": [0, 0, 0]},
+  "latency_bucket_bounds": [2, 4]
+}`, sum, count, sumSquares, distribution)
+}
+
+func TestScrapeAndExportLatencyPercentiles(t *testing.T) {
+	responses := []string{
+		latencyStatsJSON(3, 8, 24, "[0, 2, 1]"),
+		latencyStatsJSON(7, 12, 28, "[4, 2, 1]"),
+		latencyStatsJSON(7, 12, 28, "[4, 2, 1]"),
 	}
-	upstreamLatency := &LatencyStats{
-		RequestCount: 3,
-		LatencySum:   5,
-		SumSquares:   9,
-		Distribution: []int64{1, 2, 0},
+	sink := &metricstest.Sink{}
+	collector := &NginxStatsCollector{
+		consumer:           sink,
+		now:                fakeNow,
+		startTime:          fakeNow(),
+		logger:             zap.NewNop(),
+		statsURL:           "http://success",
+		latencyPercentiles: []float64{50, 99},
+		getStatus: func(context.Context, string) (*http.Response, error) {
+			response := responses[0]
+			responses = responses[1:]
+			return getResponseFromJSON(response, 200), nil
+		},
 	}
-	websocketLatency := &LatencyStats{
-		RequestCount: 1,
-		LatencySum:   4,
-		SumSquares:   16,
-		Distribution: []int64{0, 0, 1},
+
+	// The first percentiles are estimated from all the requests since nginx started, the
+	// next ones only from the 4 requests since the previous export, which are all under 2ms,
+	// and none are exported when there were no requests.
+	for i := 0; i < 3; i++ {
+		collector.scrapeAndExport(context.Background())
+		assert.NoError(t, metricRegistry.Validate(sink.Last()))
 	}
-	checkDistributionMetricValue(t, data, "on_vm_request_latencies", requestLatency)
-	checkDistributionMetricValue(t, data, "on_vm_upstream_latencies", upstreamLatency)
-	checkDistributionMetricValue(t, data, "web_socket/durations", websocketLatency)
+	metricstest.AssertSinkGolden(t, "testdata/latency_percentiles.golden", sink)
 }
 
-func TestScrapeAndExportError(t *testing.T) {
-	consumer := &fakeConsumer{}
-	collector := &NginxStatsCollector{
-		consumer:       consumer,
-		now:            fakeNow,
-		startTime:      fakeNow(),
-		done:           make(chan struct{}),
-		logger:         zap.NewNop(),
-		exportInterval: time.Minute,
-		statsURL:       "http://error",
-		getStatus:      fakeHTTPGet,
+func TestNewNginxStatsCollectorInvalidLatencyPercentiles(t *testing.T) {
+	cfg := &Config{
+		ExportInterval:     time.Minute,
+		StatsURL:           "http://example.com",
+		LatencyPercentiles: []float64{50, 101},
 	}
-	collector.scrapeAndExport()
-	assert.Equal(t, consumer.metrics.MetricCount(), 0)
+	_, err := NewNginxStatsCollector(cfg, zap.NewNop(), nil)
+	assert.Error(t, err)
 }
@@ -1,29 +1,90 @@
 package nginxreceiver
 
 import (
-	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
 )
 
-var requestLatencyMetric = &metricspb.MetricDescriptor{
+// metricRegistry holds the descriptors of the metrics generated by the nginx stats receiver.
+var metricRegistry = metricgenerator.NewRegistry()
+
+var requestLatencyMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 	Name:        "on_vm_request_latencies",
 	Description: "The request latency measured at nginx. Includes latency from nginx and the user's app code",
 	Unit:        "milliseconds",
-	Type:        metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION,
-	LabelKeys:   []*metricspb.LabelKey{},
-}
+	Type:        metricgenerator.CumulativeDistribution,
+})
 
-var upstreamLatencyMetric = &metricspb.MetricDescriptor{
+var upstreamLatencyMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 	Name:        "on_vm_upstream_latencies",
 	Description: "The upstream latency measured at nginx. ie The latency of the user provided app code.",
 	Unit:        "milliseconds",
-	Type:        metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION,
-	LabelKeys:   []*metricspb.LabelKey{},
-}
+	Type:        metricgenerator.CumulativeDistribution,
+})
 
-var websocketLatencyMetric = &metricspb.MetricDescriptor{
+var websocketLatencyMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 	Name:        "web_socket/durations",
 	Description: "The duration of websocket connections measured at nginx.",
 	Unit:        "milliseconds",
-	Type:        metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION,
-	LabelKeys:   []*metricspb.LabelKey{},
+	Type:        metricgenerator.CumulativeDistribution,
+})
+
+var requestSizeMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "on_vm_request_sizes",
+	Description: "The size of the request bodies received by nginx.",
+	Unit:        "By",
+	Type:        metricgenerator.CumulativeDistribution,
+})
+
+var responseSizeMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "on_vm_response_sizes",
+	Description: "The size of the response bodies sent by nginx.",
+	Unit:        "By",
+	Type:        metricgenerator.CumulativeDistribution,
+})
+
+var bucketBoundsLabel = metricgenerator.LabelKey{
+	Key:         "bounds",
+	Description: "The comma separated upper bounds of the distribution buckets",
 }
+
+var latencyBucketBoundsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "on_vm_latency_bucket_bounds",
+	Description: "The latency distribution bucket bounds reported by nginx. The value is always 1, the bounds are in the label.",
+	Unit:        "1",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{bucketBoundsLabel},
+})
+
+var sizeBucketBoundsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "on_vm_size_bucket_bounds",
+	Description: "The request and response size distribution bucket bounds reported by nginx. The value is always 1, the bounds are in the label.",
+	Unit:        "1",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{bucketBoundsLabel},
+})
+
+var percentileLabel = metricgenerator.LabelKey{
+	Key:         "percentile",
+	Description: "The percentile of the request latency, between 0 and 100",
+}
+
+var requestLatencyPercentilesMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "on_vm_request_latency_percentiles",
+	Description: "The percentiles of the request latency measured at nginx over the export interval, estimated from the request latency distribution.",
+	Unit:        "milliseconds",
+	Type:        metricgenerator.GaugeDouble,
+	LabelKeys:   []metricgenerator.LabelKey{percentileLabel},
+})
+
+var fieldLabel = metricgenerator.LabelKey{
+	Key:         "field",
+	Description: "The field of the nginx stats that was invalid",
+}
+
+var statsInvalidMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "nginx_stats_invalid",
+	Description: "The number of problems found in the stats read from nginx, such as missing, negative or inconsistent values, or counters that went down.",
+	Unit:        "1",
+	Type:        metricgenerator.CumulativeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{fieldLabel},
+})
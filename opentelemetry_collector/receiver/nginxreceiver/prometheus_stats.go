@@ -0,0 +1,177 @@
+package nginxreceiver
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// readStatsPrometheus parses stats in the Prometheus text exposition format.
+// Each configured histogram family is read into the matching LatencyStats or SizeStats.
+// A family that is missing keeps the -1 defaults, like a field missing from the stats json.
+// The latencies are exported in milliseconds, so latency families named in seconds,
+// following the Prometheus naming conventions, are converted.
+func readStatsPrometheus(statsText []byte, histograms PrometheusHistograms) (*NginxStats, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(statsText))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := newDefaultNginxStats()
+	targets := []struct {
+		name string
+		// bounds are the bucket bounds shared with the other histograms of the same kind.
+		bounds  *[]float64
+		set     func(*distributionStats)
+		convert func(float64) float64
+	}{
+		{histograms.RequestLatency, &stats.LatencyBucketBounds, stats.RequestLatency.set, latencyConversion(histograms.RequestLatency)},
+		{histograms.UpstreamLatency, &stats.LatencyBucketBounds, stats.UpstreamLatency.set, latencyConversion(histograms.UpstreamLatency)},
+		{histograms.WebsocketLatency, &stats.LatencyBucketBounds, stats.WebsocketLatency.set, latencyConversion(histograms.WebsocketLatency)},
+		{histograms.RequestSize, &stats.SizeBucketBounds, stats.RequestSize.set, identity},
+		{histograms.ResponseSize, &stats.SizeBucketBounds, stats.ResponseSize.set, identity},
+	}
+
+	for _, target := range targets {
+		family, ok := families[target.name]
+		if !ok {
+			continue
+		}
+		distribution, bounds, err := histogramToDistribution(family, target.convert)
+		if err != nil {
+			return nil, fmt.Errorf("invalid histogram %s: %v", target.name, err)
+		}
+		if *target.bounds == nil {
+			*target.bounds = bounds
+		} else if !equalBounds(*target.bounds, bounds) {
+			// The nginx stats share one set of bucket bounds. Leave the distribution
+			// unset so the histogram is reported as inconsistent rather than
+			// exported with the wrong bounds.
+			distribution.distribution = nil
+		}
+		target.set(distribution)
+	}
+	return stats, nil
+}
+
+func identity(value float64) float64 {
+	return value
+}
+
+// latencyConversion returns the conversion of the values of the latency family name to milliseconds.
+func latencyConversion(name string) func(float64) float64 {
+	if strings.HasSuffix(name, "_seconds") {
+		return secondsToMilliseconds
+	}
+	return identity
+}
+
+// secondsToMilliseconds shifts the decimal point of value rather than multiplying it,
+// so that bounds like 0.3 seconds become exactly 300 milliseconds.
+func secondsToMilliseconds(value float64) float64 {
+	shifted, err := strconv.ParseFloat(strconv.FormatFloat(value, 'g', -1, 64)+"e3", 64)
+	if err != nil {
+		// Infinities and NaN are not formatted as numbers.
+		return value * 1000
+	}
+	return shifted
+}
+
+func (stats *LatencyStats) set(distribution *distributionStats) {
+	*stats = LatencyStats{
+		RequestCount:      distribution.count,
+		LatencySum:        distribution.sum,
+		SumSquares:        distribution.sumSquares,
+		Distribution:      distribution.distribution,
+		sumSquaresUnknown: distribution.sumSquaresUnknown,
+	}
+}
+
+func (stats *SizeStats) set(distribution *distributionStats) {
+	*stats = SizeStats{
+		RequestCount:      distribution.count,
+		SizeSum:           distribution.sum,
+		SumSquares:        distribution.sumSquares,
+		Distribution:      distribution.distribution,
+		sumSquaresUnknown: distribution.sumSquaresUnknown,
+	}
+}
+
+// histogramToDistribution converts a Prometheus histogram family into distribution stats
+// and the upper bounds of its buckets, excluding the +Inf bucket. The bounds and the sum
+// are converted with convert before the sum is rounded.
+// When the family has several label sets, their histograms are added together.
+func histogramToDistribution(family *dto.MetricFamily, convert func(float64) float64) (*distributionStats, []float64, error) {
+	if family.GetType() != dto.MetricType_HISTOGRAM {
+		return nil, nil, fmt.Errorf("metric type is %s, not HISTOGRAM", family.GetType())
+	}
+
+	var bounds []float64
+	var cumulative []uint64
+	var count uint64
+	var sum float64
+	for i, metric := range family.GetMetric() {
+		histogram := metric.GetHistogram()
+		metricBounds := make([]float64, 0, len(histogram.GetBucket()))
+		metricCumulative := make([]uint64, 0, len(histogram.GetBucket()))
+		for _, bucket := range histogram.GetBucket() {
+			if math.IsInf(bucket.GetUpperBound(), +1) {
+				continue
+			}
+			metricBounds = append(metricBounds, convert(bucket.GetUpperBound()))
+			metricCumulative = append(metricCumulative, bucket.GetCumulativeCount())
+		}
+		if !strictlyIncreasing(metricBounds) {
+			return nil, nil, fmt.Errorf("bucket bounds %v are not strictly increasing", metricBounds)
+		}
+
+		if i == 0 {
+			bounds = metricBounds
+			cumulative = metricCumulative
+		} else {
+			if !equalBounds(bounds, metricBounds) {
+				return nil, nil, fmt.Errorf("bucket bounds %v and %v differ between label sets", bounds, metricBounds)
+			}
+			for j := range cumulative {
+				cumulative[j] += metricCumulative[j]
+			}
+		}
+		count += histogram.GetSampleCount()
+		sum += histogram.GetSampleSum()
+	}
+
+	// Prometheus buckets are cumulative and the +Inf bucket is implied by the sample count.
+	distribution := make([]int64, len(bounds)+1)
+	var previous uint64
+	for i, c := range cumulative {
+		distribution[i] = int64(c) - int64(previous)
+		previous = c
+	}
+	distribution[len(bounds)] = int64(count) - int64(previous)
+
+	return &distributionStats{
+		count:        int64(count),
+		sum:          int64(math.Round(convert(sum))),
+		distribution: distribution,
+		// The Prometheus histogram has no sum of squares.
+		sumSquaresUnknown: true,
+	}, bounds, nil
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
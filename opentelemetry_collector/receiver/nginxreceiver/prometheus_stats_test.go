@@ -0,0 +1,176 @@
+package nginxreceiver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+var testPrometheusHistograms = PrometheusHistograms{
+	RequestLatency:   "nginx_request_latency_milliseconds",
+	UpstreamLatency:  "nginx_upstream_latency_milliseconds",
+	WebsocketLatency: "nginx_websocket_latency_milliseconds",
+	RequestSize:      "nginx_request_size_bytes",
+	ResponseSize:     "nginx_response_size_bytes",
+}
+
+const testPrometheusStats = `# HELP nginx_request_latency_milliseconds Request latency.
+# TYPE nginx_request_latency_milliseconds histogram
+nginx_request_latency_milliseconds_bucket{le="2"} 0
+nginx_request_latency_milliseconds_bucket{le="4"} 2
+nginx_request_latency_milliseconds_bucket{le="+Inf"} 3
+nginx_request_latency_milliseconds_sum 8
+nginx_request_latency_milliseconds_count 3
+# HELP nginx_upstream_latency_milliseconds Upstream latency.
+# TYPE nginx_upstream_latency_milliseconds histogram
+nginx_upstream_latency_milliseconds_bucket{server="a",le="2"} 1
+nginx_upstream_latency_milliseconds_bucket{server="a",le="4"} 2
+nginx_upstream_latency_milliseconds_bucket{server="a",le="+Inf"} 2
+nginx_upstream_latency_milliseconds_sum{server="a"} 3.6
+nginx_upstream_latency_milliseconds_count{server="a"} 2
+nginx_upstream_latency_milliseconds_bucket{server="b",le="2"} 0
+nginx_upstream_latency_milliseconds_bucket{server="b",le="4"} 1
+nginx_upstream_latency_milliseconds_bucket{server="b",le="+Inf"} 1
+nginx_upstream_latency_milliseconds_sum{server="b"} 1.4
+nginx_upstream_latency_milliseconds_count{server="b"} 1
+# TYPE nginx_response_size_bytes histogram
+nginx_response_size_bytes_bucket{le="100"} 1
+nginx_response_size_bytes_bucket{le="1000"} 3
+nginx_response_size_bytes_bucket{le="+Inf"} 3
+nginx_response_size_bytes_sum 1200
+nginx_response_size_bytes_count 3
+# HELP nginx_connections_active Active connections.
+# TYPE nginx_connections_active gauge
+nginx_connections_active 1
+`
+
+func TestReadStatsPrometheus(t *testing.T) {
+	stats, err := readStatsPrometheus([]byte(testPrometheusStats), testPrometheusHistograms)
+
+	expectedStats := &NginxStats{
+		RequestLatency: LatencyStats{
+			RequestCount:      3,
+			LatencySum:        8,
+			Distribution:      []int64{0, 2, 1},
+			sumSquaresUnknown: true,
+		},
+		UpstreamLatency: LatencyStats{
+			RequestCount:      3,
+			LatencySum:        5,
+			Distribution:      []int64{1, 2, 0},
+			sumSquaresUnknown: true,
+		},
+		WebsocketLatency: LatencyStats{
+			RequestCount: -1,
+			LatencySum:   -1,
+			SumSquares:   -1,
+		},
+		LatencyBucketBounds: []float64{2, 4},
+		RequestSize: SizeStats{
+			RequestCount: -1,
+			SizeSum:      -1,
+			SumSquares:   -1,
+		},
+		ResponseSize: SizeStats{
+			RequestCount:      3,
+			SizeSum:           1200,
+			Distribution:      []int64{1, 2, 0},
+			sumSquaresUnknown: true,
+		},
+		SizeBucketBounds: []float64{100, 1000},
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, expectedStats, stats)
+}
+
+func TestReadStatsPrometheusMismatchedBounds(t *testing.T) {
+	statsText := `# TYPE nginx_request_latency_milliseconds histogram
+nginx_request_latency_milliseconds_bucket{le="2"} 1
+nginx_request_latency_milliseconds_bucket{le="+Inf"} 1
+nginx_request_latency_milliseconds_sum 1
+nginx_request_latency_milliseconds_count 1
+# TYPE nginx_upstream_latency_milliseconds histogram
+nginx_upstream_latency_milliseconds_bucket{le="5"} 1
+nginx_upstream_latency_milliseconds_bucket{le="+Inf"} 1
+nginx_upstream_latency_milliseconds_sum 1
+nginx_upstream_latency_milliseconds_count 1
+`
+	stats, err := readStatsPrometheus([]byte(statsText), testPrometheusHistograms)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{2}, stats.LatencyBucketBounds)
+	assert.Empty(t, stats.RequestLatency.distributionStats().validate("request_latency", stats.LatencyBucketBounds))
+	assert.Equal(t,
+		[]FieldError{{Field: "upstream_latency.distribution", Reason: reasonMissing}},
+		stats.UpstreamLatency.distributionStats().validate("upstream_latency", stats.LatencyBucketBounds))
+}
+
+func TestReadStatsPrometheusSeconds(t *testing.T) {
+	statsText := `# TYPE nginx_request_latency_seconds histogram
+nginx_request_latency_seconds_bucket{le="0.1"} 1
+nginx_request_latency_seconds_bucket{le="0.3"} 3
+nginx_request_latency_seconds_bucket{le="+Inf"} 4
+nginx_request_latency_seconds_sum 1.2345
+nginx_request_latency_seconds_count 4
+`
+	histograms := testPrometheusHistograms
+	histograms.RequestLatency = "nginx_request_latency_seconds"
+	stats, err := readStatsPrometheus([]byte(statsText), histograms)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{100, 300}, stats.LatencyBucketBounds)
+	assert.Equal(t, LatencyStats{
+		RequestCount:      4,
+		LatencySum:        1235,
+		Distribution:      []int64{1, 2, 1},
+		sumSquaresUnknown: true,
+	}, stats.RequestLatency)
+}
+
+func TestReadStatsPrometheusNotHistogram(t *testing.T) {
+	statsText := `# TYPE nginx_request_latency_milliseconds gauge
+nginx_request_latency_milliseconds 1
+`
+	_, err := readStatsPrometheus([]byte(statsText), testPrometheusHistograms)
+	assert.Error(t, err)
+}
+
+func TestReadStatsPrometheusDuplicateBounds(t *testing.T) {
+	statsText := `# TYPE nginx_request_latency_milliseconds histogram
+nginx_request_latency_milliseconds_bucket{le="1"} 1
+nginx_request_latency_milliseconds_bucket{le="1"} 1
+nginx_request_latency_milliseconds_bucket{le="2"} 2
+nginx_request_latency_milliseconds_bucket{le="+Inf"} 2
+nginx_request_latency_milliseconds_sum 3
+nginx_request_latency_milliseconds_count 2
+`
+	_, err := readStatsPrometheus([]byte(statsText), testPrometheusHistograms)
+	assert.EqualError(t, err, "invalid histogram nginx_request_latency_milliseconds: bucket bounds [1 1 2] are not strictly increasing")
+}
+
+func TestReadStatsPrometheusMalformatted(t *testing.T) {
+	_, err := readStatsPrometheus([]byte("malformatted{ prometheus"), testPrometheusHistograms)
+	assert.Error(t, err)
+}
+
+func TestScrapeAndExportPrometheus(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := &NginxStatsCollector{
+		consumer:             sink,
+		now:                  fakeNow,
+		startTime:            fakeNow(),
+		logger:               zap.NewNop(),
+		statsURL:             "http://prometheus",
+		format:               formatPrometheus,
+		prometheusHistograms: testPrometheusHistograms,
+		getStatus:            fakeHTTPGet,
+	}
+	collector.scrapeAndExport(context.Background())
+	// The websocket histogram and the request size histogram are missing and
+	// are reported on nginx_stats_invalid.
+	metricstest.AssertMetricsGolden(t, "testdata/scrape_and_export_prometheus.golden", sink.Last())
+}
@@ -0,0 +1,180 @@
+package nginxreceiver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+)
+
+// Reasons a field of the nginx stats can be invalid.
+const (
+	reasonMissing          = "missing"
+	reasonNegative         = "negative"
+	reasonLengthMismatch   = "length_mismatch"
+	reasonNegativeVariance = "negative_variance"
+	reasonUnsorted         = "unsorted"
+	reasonWrongType        = "wrong_type"
+	reasonTruncated        = "truncated"
+	reasonMalformed        = "malformed"
+	reasonOverflow         = "overflow"
+	reasonCounterReset     = "counter_reset"
+)
+
+const (
+	fieldBody                = "body"
+	fieldLatencyBucketBounds = "latency_bucket_bounds"
+	fieldSizeBucketBounds    = "size_bucket_bounds"
+)
+
+// FieldError describes a problem with a single field of the nginx stats.
+type FieldError struct {
+	// Field is the path of the field in the stats json, e.g. request_latency.request_count.
+	Field  string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// ValidationError is the list of every problem found in an nginx stats payload.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Error()
+	}
+	return "invalid nginx stats: " + strings.Join(messages, "; ")
+}
+
+// asValidationError returns nil if there are no errors, so that the result can be compared to nil
+// without running into a non-nil interface holding a nil pointer.
+func asValidationError(errs []FieldError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+// validateBucketBounds checks the bucket bounds found at field in the stats json,
+// which are shared by a set of distributions.
+func validateBucketBounds(field string, bounds []float64) []FieldError {
+	if len(bounds) == 0 {
+		return []FieldError{{Field: field, Reason: reasonMissing}}
+	}
+	if !strictlyIncreasing(bounds) {
+		return []FieldError{{Field: field, Reason: reasonUnsorted}}
+	}
+	return nil
+}
+
+// strictlyIncreasing returns whether each bucket bound is greater than the previous one.
+// Equal bounds would make a bucket of zero width.
+func strictlyIncreasing(bounds []float64) bool {
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks the distribution stats found at field in the stats json against the bucket bounds
+// and returns every problem found.
+// A value of -1 is the default set before parsing and means the value was missing.
+func (stats *distributionStats) validate(field string, bounds []float64) []FieldError {
+	var errs []FieldError
+	checkValue := func(name string, value int64) {
+		if value == -1 {
+			errs = append(errs, FieldError{Field: field + "." + name, Reason: reasonMissing})
+		} else if value < 0 {
+			errs = append(errs, FieldError{Field: field + "." + name, Reason: reasonNegative})
+		}
+	}
+
+	checkValue("request_count", stats.count)
+	checkValue(stats.sumField, stats.sum)
+	if !stats.sumSquaresUnknown {
+		checkValue("sum_squares", stats.sumSquares)
+	}
+
+	distributionField := field + ".distribution"
+	if len(stats.distribution) == 0 {
+		errs = append(errs, FieldError{Field: distributionField, Reason: reasonMissing})
+	} else if len(bounds) > 0 && len(bounds)+1 != len(stats.distribution) {
+		errs = append(errs, FieldError{Field: distributionField, Reason: reasonLengthMismatch})
+	}
+	for _, count := range stats.distribution {
+		if count < 0 {
+			errs = append(errs, FieldError{Field: distributionField, Reason: reasonNegative})
+			break
+		}
+	}
+
+	// The variance can not be negative for real values.
+	if !stats.sumSquaresUnknown && stats.count > 0 && stats.sum >= 0 && stats.sumSquares >= 0 {
+		if metricgenerator.GetSumOfSquaredDeviationsFromIntDist(stats.sum, stats.sumSquares, stats.count) < 0 {
+			errs = append(errs, FieldError{Field: field + ".sum_squares", Reason: reasonNegativeVariance})
+		}
+	}
+	return errs
+}
+
+// jsonFieldErrors converts an error from parsing the stats json into field errors.
+func jsonFieldErrors(err error, statsJSON []byte) []FieldError {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxError):
+		if syntaxError.Offset >= int64(len(statsJSON)) {
+			return []FieldError{{Field: fieldBody, Reason: reasonTruncated}}
+		}
+		return []FieldError{{Field: fieldBody, Reason: reasonMalformed}}
+	case errors.As(err, &typeError):
+		field := typeError.Field
+		if field == "" {
+			field = fieldBody
+		}
+		// A number too large for an int64 counter means nginx reported an overflowed value.
+		if strings.HasPrefix(typeError.Value, "number") && typeError.Type != nil && typeError.Type.Kind() == reflect.Int64 {
+			return []FieldError{{Field: field, Reason: reasonOverflow}}
+		}
+		return []FieldError{{Field: field, Reason: reasonWrongType}}
+	}
+	return []FieldError{{Field: fieldBody, Reason: reasonMalformed}}
+}
+
+// countersReset compares the counters of the distribution stats found at field in the stats json
+// with their values in the previous scrape, and returns the counters that went down. Counters only
+// go up, so a lower value means they were reset, which happens when nginx restarts, or that they
+// wrapped around after overflowing. Negative values are invalid and left to validate.
+func countersReset(field string, previous, stats *distributionStats) []FieldError {
+	var errs []FieldError
+	checkCounter := func(name string, previous, current int64) {
+		if previous >= 0 && current >= 0 && current < previous {
+			errs = append(errs, FieldError{Field: field + "." + name, Reason: reasonCounterReset})
+		}
+	}
+
+	checkCounter("request_count", previous.count, stats.count)
+	checkCounter(stats.sumField, previous.sum, stats.sum)
+	if !previous.sumSquaresUnknown && !stats.sumSquaresUnknown {
+		checkCounter("sum_squares", previous.sumSquares, stats.sumSquares)
+	}
+	if len(previous.distribution) == len(stats.distribution) {
+		for i, count := range stats.distribution {
+			if previous.distribution[i] >= 0 && count >= 0 && count < previous.distribution[i] {
+				errs = append(errs, FieldError{Field: field + ".distribution", Reason: reasonCounterReset})
+				break
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,217 @@
+package nginxreceiver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+func TestValidate(t *testing.T) {
+	stats := LatencyStats{
+		RequestCount: 3,
+		LatencySum:   8,
+		SumSquares:   24,
+		Distribution: []int64{0, 2, 1},
+	}
+	buckets := []float64{2, 4}
+
+	assert.Empty(t, stats.distributionStats().validate("request_latency", buckets))
+}
+
+func TestValidateLargeValues(t *testing.T) {
+	// count * sum_squares is beyond the int64 range.
+	stats := LatencyStats{
+		RequestCount: 4,
+		LatencySum:   4e9,
+		SumSquares:   4e18,
+		Distribution: []int64{0, 0, 4},
+	}
+	assert.Empty(t, stats.distributionStats().validate("request_latency", []float64{2, 4}))
+}
+
+func TestCountersReset(t *testing.T) {
+	previous := LatencyStats{RequestCount: 3, LatencySum: 8, SumSquares: 24, Distribution: []int64{0, 2, 1}}
+	assert.Empty(t, countersReset("request_latency", previous.distributionStats(), previous.distributionStats()))
+
+	current := LatencyStats{RequestCount: 4, LatencySum: 9, SumSquares: 25, Distribution: []int64{1, 2, 1}}
+	assert.Empty(t, countersReset("request_latency", previous.distributionStats(), current.distributionStats()))
+
+	// The missing sum of squares is left to validate.
+	current = LatencyStats{RequestCount: 1, LatencySum: 8, SumSquares: -1, Distribution: []int64{0, 0, 1}}
+	assert.Equal(t,
+		[]FieldError{
+			{"request_latency.request_count", reasonCounterReset},
+			{"request_latency.distribution", reasonCounterReset},
+		},
+		countersReset("request_latency", previous.distributionStats(), current.distributionStats()))
+}
+
+func TestValidateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		stats    LatencyStats
+		buckets  []float64
+		expected []FieldError
+	}{
+		{
+			name: "distribution length",
+			stats: LatencyStats{
+				RequestCount: 3,
+				LatencySum:   8,
+				SumSquares:   24,
+				Distribution: []int64{0, 2, 1},
+			},
+			buckets:  []float64{2, 4, 8},
+			expected: []FieldError{{"request_latency.distribution", reasonLengthMismatch}},
+		},
+		{
+			name: "missing request count",
+			stats: LatencyStats{
+				RequestCount: -1,
+				LatencySum:   8,
+				SumSquares:   24,
+				Distribution: []int64{0, 2, 1},
+			},
+			buckets:  []float64{2, 4},
+			expected: []FieldError{{"request_latency.request_count", reasonMissing}},
+		},
+		{
+			name: "negative sum squares",
+			stats: LatencyStats{
+				RequestCount: 3,
+				LatencySum:   8,
+				SumSquares:   -5,
+				Distribution: []int64{0, 2, 1},
+			},
+			buckets:  []float64{2, 4},
+			expected: []FieldError{{"request_latency.sum_squares", reasonNegative}},
+		},
+		{
+			name: "negative sum",
+			stats: LatencyStats{
+				RequestCount: 3,
+				LatencySum:   -5,
+				SumSquares:   24,
+				Distribution: []int64{0, 2, 1},
+			},
+			buckets:  []float64{2, 4},
+			expected: []FieldError{{"request_latency.latency_sum", reasonNegative}},
+		},
+		{
+			name: "negative distribution",
+			stats: LatencyStats{
+				RequestCount: 3,
+				LatencySum:   8,
+				SumSquares:   24,
+				Distribution: []int64{0, 2, -1},
+			},
+			buckets:  []float64{2, 4},
+			expected: []FieldError{{"request_latency.distribution", reasonNegative}},
+		},
+		{
+			name: "unset distribution",
+			stats: LatencyStats{
+				RequestCount: 3,
+				LatencySum:   8,
+				SumSquares:   24,
+				Distribution: nil,
+			},
+			expected: []FieldError{{"request_latency.distribution", reasonMissing}},
+		},
+		{
+			name: "negative variance",
+			stats: LatencyStats{
+				RequestCount: 3,
+				LatencySum:   9,
+				SumSquares:   20,
+				Distribution: []int64{0, 2, 1},
+			},
+			buckets:  []float64{2, 4},
+			expected: []FieldError{{"request_latency.sum_squares", reasonNegativeVariance}},
+		},
+		{
+			name: "every problem is reported",
+			stats: LatencyStats{
+				RequestCount: -1,
+				LatencySum:   -1,
+				SumSquares:   -2,
+				Distribution: []int64{-1},
+			},
+			buckets: []float64{2, 4},
+			expected: []FieldError{
+				{"request_latency.request_count", reasonMissing},
+				{"request_latency.latency_sum", reasonMissing},
+				{"request_latency.sum_squares", reasonNegative},
+				{"request_latency.distribution", reasonLengthMismatch},
+				{"request_latency.distribution", reasonNegative},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.stats.distributionStats().validate("request_latency", tc.buckets))
+		})
+	}
+}
+
+func TestValidateBucketBounds(t *testing.T) {
+	assert.Empty(t, validateBucketBounds(fieldLatencyBucketBounds, []float64{2, 4}))
+	assert.Equal(t, []FieldError{{fieldLatencyBucketBounds, reasonMissing}}, validateBucketBounds(fieldLatencyBucketBounds, nil))
+	assert.Equal(t, []FieldError{{fieldLatencyBucketBounds, reasonUnsorted}}, validateBucketBounds(fieldLatencyBucketBounds, []float64{4, 2}))
+	assert.Equal(t, []FieldError{{fieldLatencyBucketBounds, reasonUnsorted}}, validateBucketBounds(fieldLatencyBucketBounds, []float64{1, 1, 2}))
+}
+
+func TestReadStatsJSONErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected []FieldError
+	}{
+		{"truncated", `{"request_latency": {"request_count": 3`, []FieldError{{fieldBody, reasonTruncated}}},
+		{"malformed", `malformatted json requests 0`, []FieldError{{fieldBody, reasonMalformed}}},
+		{"wrong type", `{"request_latency": {"request_count": "3"}}`, []FieldError{{"request_latency.request_count", reasonWrongType}}},
+		{"overflow", `{"request_latency": {"sum_squares": 18446744073709551616}}`, []FieldError{{"request_latency.sum_squares", reasonOverflow}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readStatsJSON([]byte(tc.json))
+			var validationError *ValidationError
+			if assert.True(t, errors.As(err, &validationError)) {
+				assert.Equal(t, tc.expected, validationError.Errors)
+			}
+		})
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	err := &ValidationError{Errors: []FieldError{
+		{"request_latency.request_count", reasonMissing},
+		{"latency_bucket_bounds", reasonUnsorted},
+	}}
+	assert.Equal(t, "invalid nginx stats: request_latency.request_count: missing; latency_bucket_bounds: unsorted", err.Error())
+}
+
+func TestScrapeAndExportInvalidMetric(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := &NginxStatsCollector{
+		consumer:  sink,
+		now:       fakeNow,
+		startTime: fakeNow(),
+		logger:    zap.NewNop(),
+		statsURL:  "http://unset",
+		getStatus: fakeHTTPGet,
+	}
+	collector.scrapeAndExport(context.Background())
+	collector.statsURL = "http://malformatted"
+	collector.scrapeAndExport(context.Background())
+
+	// Only the last export has the counts of both scrapes.
+	metricstest.AssertMetricsGolden(t, "testdata/invalid_metric.golden", sink.Last())
+}
@@ -0,0 +1,213 @@
+package scrapeloop
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Settings are the scrape settings the receivers read from their config, squashed next to
+// their own interval setting.
+type Settings struct {
+	// InitialDelay is how long to wait after Start before the first scrape.
+	InitialDelay time.Duration `mapstructure:"initial_delay"`
+	// Jitter is the maximum random delay added to InitialDelay, so that collectors
+	// started at the same time do not all scrape at the same time.
+	Jitter time.Duration `mapstructure:"jitter"`
+	// Timeout is the deadline of the context passed to each scrape. It defaults to the interval.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Config defines when a Controller scrapes.
+type Config struct {
+	// Interval is the time between the start of two scrapes.
+	Interval time.Duration
+	Settings
+}
+
+// Validate checks that the Config is valid.
+func (cfg Config) Validate() error {
+	if cfg.Interval <= 0 {
+		return errors.New("Interval must be greater than 0")
+	}
+	if cfg.InitialDelay < 0 {
+		return errors.New("InitialDelay must not be negative")
+	}
+	if cfg.Jitter < 0 {
+		return errors.New("Jitter must not be negative")
+	}
+	if cfg.Timeout < 0 {
+		return errors.New("Timeout must not be negative")
+	}
+	return nil
+}
+
+// ScrapeFunc scrapes and exports metrics once. ctx is done when the scrape times out
+// or the Controller is shut down.
+type ScrapeFunc func(ctx context.Context)
+
+// Ticker is the part of time.Ticker used by the Controller.
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+// Clock creates the timers the Controller waits on, so that tests can drive the scrapes.
+type Clock interface {
+	// NewTicker returns a Ticker ticking every d, like time.NewTicker.
+	NewTicker(d time.Duration) Ticker
+	// After returns a channel receiving the time after d, like time.After.
+	After(d time.Duration) <-chan time.Time
+}
+
+type timeTicker struct {
+	*time.Ticker
+}
+
+func (t timeTicker) Chan() <-chan time.Time {
+	return t.C
+}
+
+type realClock struct{}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return timeTicker{time.NewTicker(d)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Option changes how a Controller is created.
+type Option func(c *Controller)
+
+// WithClock makes the Controller wait on the timers of clock rather than the real ones.
+func WithClock(clock Clock) Option {
+	return func(c *Controller) {
+		c.clock = clock
+	}
+}
+
+// WithJitter makes the Controller draw the random delay added to InitialDelay with jitter,
+// which returns a duration between 0 and max.
+func WithJitter(jitter func(max time.Duration) time.Duration) Option {
+	return func(c *Controller) {
+		c.jitter = jitter
+	}
+}
+
+// Controller calls a ScrapeFunc right away after the initial delay and then at every interval,
+// one scrape at a time, until it is shut down.
+type Controller struct {
+	cfg    Config
+	scrape ScrapeFunc
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	stopped chan struct{}
+
+	clock  Clock
+	jitter func(max time.Duration) time.Duration
+}
+
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// NewController creates a Controller that calls scrape as defined by cfg.
+func NewController(cfg Config, scrape ScrapeFunc, opts ...Option) (*Controller, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = cfg.Interval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Controller{
+		cfg:    cfg,
+		scrape: scrape,
+		ctx:    ctx,
+		cancel: cancel,
+		clock:  realClock{},
+		jitter: randomJitter,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Start starts a go routine that scrapes until the Controller is shut down.
+// Only the first call starts the go routine, and it returns right away if the
+// Controller was already shut down.
+func (c *Controller) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped != nil {
+		return
+	}
+	c.stopped = make(chan struct{})
+	go c.run()
+}
+
+// Shutdown stops the scrapes, cancelling the context of a scrape in progress, and waits
+// for the scrape to return or for ctx to be done, in which case it returns the error of ctx.
+func (c *Controller) Shutdown(ctx context.Context) error {
+	c.cancel()
+
+	c.mu.Lock()
+	stopped := c.stopped
+	c.mu.Unlock()
+	if stopped == nil {
+		return nil
+	}
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Controller) run() {
+	defer close(c.stopped)
+
+	if delay := c.cfg.InitialDelay + c.jitter(c.cfg.Jitter); delay > 0 {
+		select {
+		case <-c.clock.After(delay):
+		case <-c.ctx.Done():
+			return
+		}
+	}
+
+	ticker := c.clock.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	c.scrapeOnce()
+	for {
+		select {
+		case <-ticker.Chan():
+			c.scrapeOnce()
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Controller) scrapeOnce() {
+	// A tick and the shutdown can both be ready, in which case select picks either.
+	if c.ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.cfg.Timeout)
+	defer cancel()
+	c.scrape(ctx)
+}
@@ -0,0 +1,176 @@
+package scrapeloop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTicker struct {
+	c       chan time.Time
+	stopped chan struct{}
+}
+
+func newFakeTicker() *fakeTicker {
+	return &fakeTicker{c: make(chan time.Time), stopped: make(chan struct{})}
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	close(t.stopped)
+}
+
+// fakeClock returns its ticker, checking the interval, and records the initial delays.
+type fakeClock struct {
+	t        *testing.T
+	interval time.Duration
+	ticker   *fakeTicker
+	delays   chan time.Duration
+	delayed  chan time.Time
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	assert.Equal(c.t, c.interval, d)
+	return c.ticker
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays <- d
+	return c.delayed
+}
+
+// newTestController creates a Controller with a fake clock, that sends the context
+// of each scrape on the returned channel.
+func newTestController(t *testing.T, cfg Config, opts ...Option) (*Controller, *fakeClock, chan context.Context) {
+	scrapes := make(chan context.Context, 10)
+	clock := &fakeClock{
+		t:        t,
+		interval: cfg.Interval,
+		ticker:   newFakeTicker(),
+		delays:   make(chan time.Duration, 1),
+		delayed:  make(chan time.Time),
+	}
+	controller, err := NewController(cfg, func(ctx context.Context) {
+		scrapes <- ctx
+	}, append([]Option{WithClock(clock)}, opts...)...)
+	require.NoError(t, err)
+	return controller, clock, scrapes
+}
+
+func TestNewControllerInvalidConfig(t *testing.T) {
+	configs := []Config{
+		{},
+		{Interval: -time.Second},
+		{Interval: time.Second, Settings: Settings{InitialDelay: -time.Second}},
+		{Interval: time.Second, Settings: Settings{Jitter: -time.Second}},
+		{Interval: time.Second, Settings: Settings{Timeout: -time.Second}},
+	}
+	for _, cfg := range configs {
+		_, err := NewController(cfg, func(ctx context.Context) {})
+		assert.Error(t, err, "config %+v", cfg)
+	}
+}
+
+func TestControllerScrapesRightAwayAndOnEachTick(t *testing.T) {
+	controller, clock, scrapes := newTestController(t, Config{Interval: time.Minute})
+	fake := clock.ticker
+	controller.Start()
+
+	ctx := <-scrapes
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 10*time.Second)
+
+	fake.c <- time.Now()
+	<-scrapes
+
+	assert.NoError(t, controller.Shutdown(context.Background()))
+	select {
+	case <-fake.stopped:
+	default:
+		t.Error("The ticker was not stopped")
+	}
+	assert.Empty(t, scrapes)
+}
+
+func TestControllerTimeout(t *testing.T) {
+	controller, _, scrapes := newTestController(t, Config{Interval: time.Minute, Settings: Settings{Timeout: time.Second}})
+	controller.Start()
+	defer controller.Shutdown(context.Background())
+
+	deadline, ok := (<-scrapes).Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 500*time.Millisecond)
+}
+
+func TestControllerInitialDelay(t *testing.T) {
+	jitter := func(max time.Duration) time.Duration {
+		assert.Equal(t, time.Second, max)
+		return 500 * time.Millisecond
+	}
+	cfg := Config{Interval: time.Minute, Settings: Settings{InitialDelay: time.Second, Jitter: time.Second}}
+	controller, clock, scrapes := newTestController(t, cfg, WithJitter(jitter))
+
+	controller.Start()
+	assert.Equal(t, 1500*time.Millisecond, <-clock.delays)
+	assert.Empty(t, scrapes)
+
+	clock.delayed <- time.Now()
+	<-scrapes
+	assert.NoError(t, controller.Shutdown(context.Background()))
+}
+
+func TestControllerShutdownDuringInitialDelay(t *testing.T) {
+	controller, _, scrapes := newTestController(t, Config{Interval: time.Minute, Settings: Settings{InitialDelay: time.Hour}})
+	controller.Start()
+
+	assert.NoError(t, controller.Shutdown(context.Background()))
+	assert.Empty(t, scrapes)
+}
+
+func TestControllerShutdownCancelsScrapeInProgress(t *testing.T) {
+	started := make(chan struct{})
+	controller, err := NewController(Config{Interval: time.Minute}, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+	require.NoError(t, err)
+
+	controller.Start()
+	<-started
+	assert.NoError(t, controller.Shutdown(context.Background()))
+}
+
+func TestControllerShutdownHonorsContext(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	controller, err := NewController(Config{Interval: time.Minute}, func(ctx context.Context) {
+		close(started)
+		<-release
+	})
+	require.NoError(t, err)
+
+	controller.Start()
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, controller.Shutdown(ctx))
+
+	close(release)
+	assert.NoError(t, controller.Shutdown(context.Background()))
+}
+
+func TestControllerShutdownBeforeStart(t *testing.T) {
+	controller, _, scrapes := newTestController(t, Config{Interval: time.Minute})
+	assert.NoError(t, controller.Shutdown(context.Background()))
+
+	controller.Start()
+	assert.NoError(t, controller.Shutdown(context.Background()))
+	assert.Empty(t, scrapes)
+}
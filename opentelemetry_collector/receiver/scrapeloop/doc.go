@@ -0,0 +1,3 @@
+// Package scrapeloop provides the loop the receivers that generate metrics use to
+// scrape and export them periodically.
+package scrapeloop
@@ -0,0 +1,82 @@
+package vmagereceiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// The sources the VM image build date can come from.
+const (
+	buildDateSourceConfig    = "config"
+	buildDateSourceImageName = "image_name"
+)
+
+// compileImageNamePattern compiles the pattern the build date is extracted from the VM image name with.
+// The pattern must have a capture group for the date, either named date or the first one.
+func compileImageNamePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("ImageNameBuildDatePattern %q is not valid: %v", pattern, err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("ImageNameBuildDatePattern %q must have a capture group for the date", pattern)
+	}
+	return re, nil
+}
+
+// buildDateFromImageName extracts the build date from the VM image name.
+func buildDateFromImageName(pattern *regexp.Regexp, imageName string, layouts []string) (time.Time, error) {
+	match := pattern.FindStringSubmatch(imageName)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("VM image name %q does not match %q", imageName, pattern)
+	}
+	group := 1
+	if i := pattern.SubexpIndex("date"); i > 0 {
+		group = i
+	}
+	buildDate, _, err := parseTimestamp(match[group], layouts)
+	return buildDate, err
+}
+
+// readBuildDate returns the VM image build date. When the configured build date is missing
+// or can not be parsed, it falls back to the date in the VM image name, if a pattern is set.
+// Errors are about the configured build date, since that is the one expected to be set.
+func (collector *VMAgeCollector) readBuildDate(ctx context.Context, now time.Time) (time.Time, error) {
+	buildDate, err := readTimestamp(ctx, collector.buildDate, now)
+	if err == nil {
+		collector.noteBuildDateSource(buildDateSourceConfig)
+		return buildDate, nil
+	}
+
+	var timestampErr *timestampError
+	if collector.imageNamePattern == nil || !errors.As(err, &timestampErr) || timestampErr.reason == reasonFuture {
+		return buildDate, err
+	}
+	fallback, fallbackErr := buildDateFromImageName(collector.imageNamePattern, collector.vmImageName, collector.timestampLayouts)
+	if fallbackErr != nil {
+		collector.logger.Debug("Could not read the build date from the VM image name", zap.Error(fallbackErr))
+		return buildDate, err
+	}
+	if fallback.After(now) {
+		return buildDate, err
+	}
+	collector.noteBuildDateSource(buildDateSourceImageName)
+	return fallback, nil
+}
+
+// noteBuildDateSource logs which source the build date comes from when it changes.
+func (collector *VMAgeCollector) noteBuildDateSource(source string) {
+	if source == collector.buildDateSource {
+		return
+	}
+	collector.logger.Info("Using the VM image build date from the "+source, zap.String("vm_image_name", collector.vmImageName))
+	collector.buildDateSource = source
+}
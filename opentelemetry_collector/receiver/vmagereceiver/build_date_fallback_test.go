@@ -0,0 +1,104 @@
+package vmagereceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+const testImageNamePattern = `-v(\d{8})$`
+
+func TestCompileImageNamePattern(t *testing.T) {
+	re, err := compileImageNamePattern("")
+	assert.Nil(t, err)
+	assert.Nil(t, re)
+
+	_, err = compileImageNamePattern(testImageNamePattern)
+	assert.Nil(t, err)
+
+	_, err = compileImageNamePattern(`-v\d{8}$`)
+	assert.Error(t, err)
+
+	_, err = compileImageNamePattern(`-v(\d{8}`)
+	assert.Error(t, err)
+}
+
+func TestBuildDateFromImageName(t *testing.T) {
+	expected := time.Date(2026, time.September, 14, 0, 0, 0, 0, time.UTC)
+
+	re, _ := compileImageNamePattern(testImageNamePattern)
+	buildDate, err := buildDateFromImageName(re, "gae-flex-debian-v20260914", nil)
+	assert.Nil(t, err)
+	assert.True(t, expected.Equal(buildDate))
+
+	re, _ = compileImageNamePattern(`^(flex)-(?P<date>\d{4}-\d{2}-\d{2})`)
+	buildDate, err = buildDateFromImageName(re, "flex-2026-09-14-rc1", nil)
+	assert.Nil(t, err)
+	assert.True(t, expected.Equal(buildDate))
+
+	_, err = buildDateFromImageName(re, "other-image", nil)
+	assert.Error(t, err)
+}
+
+func TestReadBuildDateFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		buildDate     string
+		imageName     string
+		expectedDate  time.Time
+		expectedError bool
+		source        string
+	}{
+		{
+			name:         "configured build date",
+			buildDate:    "2025-01-02T00:00:00Z",
+			imageName:    "gae-flex-v20250901",
+			expectedDate: time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC),
+			source:       buildDateSourceConfig,
+		},
+		{
+			name:         "missing build date",
+			buildDate:    "",
+			imageName:    "gae-flex-v20250901",
+			expectedDate: time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC),
+			source:       buildDateSourceImageName,
+		},
+		{
+			name:         "unparseable build date",
+			buildDate:    "unknown",
+			imageName:    "gae-flex-v20250901",
+			expectedDate: time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC),
+			source:       buildDateSourceImageName,
+		},
+		{
+			name:          "no date in the image name",
+			buildDate:     "",
+			imageName:     "gae-flex",
+			expectedError: true,
+		},
+	}
+
+	now := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig(tc.buildDate, testVMStartTime, testVMReadyTime)
+			cfg.VMImageName = tc.imageName
+			cfg.ImageNameBuildDatePattern = testImageNamePattern
+			collector := NewVMAgeCollector(cfg, component.BuildInfo{}, nil, zap.NewNop())
+
+			buildDate, err := collector.readBuildDate(context.Background(), now)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, tc.expectedDate.Equal(buildDate), "got %v", buildDate)
+			assert.Equal(t, tc.source, collector.buildDateSource)
+			assert.Empty(t, collector.readTimestamps(context.Background(), now).errors())
+		})
+	}
+}
@@ -1,17 +1,140 @@
 package vmagereceiver
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"time"
 
 	"go.opentelemetry.io/collector/config"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 // Config defines the configuration for the VM age receiver.
 type Config struct {
 	config.ReceiverSettings `mapstructure:",squash"`
+	scrapeloop.Settings     `mapstructure:",squash"`
 	ExportInterval          time.Duration `mapstructure:"export_interval"`
 	BuildDate               string        `mapstructure:"build_date"`
 	VMImageName             string        `mapstructure:"vm_image_name"`
 	VMStartTime             string        `mapstructure:"vm_start_time"`
 	VMReadyTime             string        `mapstructure:"vm_ready_time"`
+
+	// ImageNameBuildDatePattern, if set, is a regular expression extracting the build date from
+	// VMImageName, used when the build date is missing or invalid. The date is the capture group
+	// named date, or the first capture group, and is parsed with TimestampLayouts.
+	ImageNameBuildDatePattern string `mapstructure:"image_name_build_date_pattern"`
+
+	// ImageAgeWarnDays and ImageAgeCriticalDays are the VM image ages, in days, past which
+	// vm_image_staleness reports the image as stale. A threshold of 0 is not set.
+	ImageAgeWarnDays     float64 `mapstructure:"image_age_warn_days"`
+	ImageAgeCriticalDays float64 `mapstructure:"image_age_critical_days"`
+
+	// BuildDateSource, VMStartTimeSource and VMReadyTimeSource define where the timestamps
+	// are read from. They take precedence over the literal BuildDate, VMStartTime and VMReadyTime.
+	BuildDateSource   TimestampSource `mapstructure:"build_date_source"`
+	VMStartTimeSource TimestampSource `mapstructure:"vm_start_time_source"`
+	VMReadyTimeSource TimestampSource `mapstructure:"vm_ready_time_source"`
+
+	// TimestampLayouts are the layouts the timestamps are parsed with, tried in order.
+	// Each is a Go time layout, or epoch_seconds or epoch_millis for a number of
+	// seconds or milliseconds since the Unix epoch.
+	TimestampLayouts []string `mapstructure:"timestamp_layouts"`
+
+	// MetadataURL is the base url of the metadata server instance attributes
+	// that TimestampSource.MetadataAttribute is read from.
+	MetadataURL string `mapstructure:"metadata_url"`
+
+	// StartupMilestones are the names of the VM startup milestones, in the order they are reached.
+	StartupMilestones []string `mapstructure:"startup_milestones"`
+	// StartupTimestampsFile is the file the startup scripts append a line to as each
+	// milestone is reached, holding the name of the milestone and an RFC 3339 timestamp.
+	StartupTimestampsFile string `mapstructure:"startup_timestamps_file"`
+
+	// ProcRoot is the directory the proc filesystem the host boot time and uptime
+	// are read from is mounted at.
+	ProcRoot string `mapstructure:"proc_root"`
+	// OSReleaseFile is the os-release file the OS release is read from.
+	OSReleaseFile string `mapstructure:"os_release_file"`
+	// DockerHost is the address of the docker engine the docker version is read from.
+	// If it is not set, the address is taken from the DOCKER_HOST environment variable
+	// or the docker default.
+	DockerHost string `mapstructure:"docker_host"`
+}
+
+// TimestampSource defines where a timestamp is read from. Only one of the fields should be set.
+type TimestampSource struct {
+	// Value is the timestamp itself.
+	Value string `mapstructure:"value"`
+	// Env is the name of an environment variable holding the timestamp.
+	Env string `mapstructure:"env"`
+	// File is the path of a file holding the timestamp. The file is read again on
+	// every export until it holds a valid timestamp, so the timestamp can be written
+	// after the collector starts.
+	File string `mapstructure:"file"`
+	// MetadataAttribute is the name of a metadata server instance attribute holding the timestamp.
+	// Like File, it is read again until it holds a valid timestamp.
+	MetadataAttribute string `mapstructure:"metadata_attribute"`
+}
+
+// orValue returns the source, or a source for the literal value if no source is set.
+func (source TimestampSource) orValue(value string) TimestampSource {
+	if source == (TimestampSource{}) {
+		return TimestampSource{Value: value}
+	}
+	return source
+}
+
+// setFields returns the number of fields of the source that are set.
+func (source TimestampSource) setFields() int {
+	count := 0
+	for _, field := range []string{source.Value, source.Env, source.File, source.MetadataAttribute} {
+		if field != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// validate checks the config for mistakes that would keep the receiver from working as configured.
+func (cfg *Config) validate() error {
+	sources := []struct {
+		name   string
+		source TimestampSource
+	}{
+		{"BuildDateSource", cfg.BuildDateSource},
+		{"VMStartTimeSource", cfg.VMStartTimeSource},
+		{"VMReadyTimeSource", cfg.VMReadyTimeSource},
+	}
+	usesMetadata := false
+	for _, s := range sources {
+		if s.source.setFields() > 1 {
+			return fmt.Errorf("%s must set only one of value, env, file and metadata_attribute", s.name)
+		}
+		usesMetadata = usesMetadata || s.source.MetadataAttribute != ""
+	}
+	if usesMetadata {
+		if _, err := url.ParseRequestURI(cfg.MetadataURL); err != nil {
+			return fmt.Errorf("MetadataURL %s is not valid: %v", cfg.MetadataURL, err)
+		}
+	}
+
+	if cfg.StartupTimestampsFile != "" && len(cfg.StartupMilestones) < 2 {
+		return errors.New("StartupMilestones must list at least 2 milestones when StartupTimestampsFile is set")
+	}
+
+	// The export interval defaults when it is not set, so only the scrape settings are checked.
+	if err := (scrapeloop.Config{Interval: defaultExportInterval, Settings: cfg.Settings}).Validate(); err != nil {
+		return err
+	}
+
+	if _, err := compileImageNamePattern(cfg.ImageNameBuildDatePattern); err != nil {
+		return err
+	}
+	if err := validateTimestampLayouts(cfg.TimestampLayouts); err != nil {
+		return err
+	}
+
+	return validateImageAgeThresholds(cfg.ImageAgeWarnDays, cfg.ImageAgeCriticalDays)
 }
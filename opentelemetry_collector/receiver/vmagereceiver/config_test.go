@@ -10,6 +10,8 @@ import (
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/service/servicetest"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -31,11 +33,63 @@ func TestLoadConfig(t *testing.T) {
 	customReceiver := cfg.Receivers[config.NewComponentIDWithName("vmage", "customname")]
 	assert.Equal(t, customReceiver,
 		&Config{
-			ReceiverSettings: config.NewReceiverSettings(config.NewComponentIDWithName("vmage", "customname")),
-			ExportInterval:   10 * time.Minute,
-			BuildDate:        "2006-01-02T15:04:05Z07:00",
-			VMImageName:      "test_vm_image_name",
-			VMStartTime:      "2007-01-01T01:01:00Z07:00",
-			VMReadyTime:      "2007-01-01T01:02:00Z07:00",
+			ReceiverSettings:          config.NewReceiverSettings(config.NewComponentIDWithName("vmage", "customname")),
+			ExportInterval:            10 * time.Minute,
+			Settings:                  scrapeloop.Settings{InitialDelay: 5 * time.Second, Jitter: 10 * time.Second, Timeout: 30 * time.Second},
+			BuildDate:                 "2006-01-02T15:04:05Z07:00",
+			VMImageName:               "test_vm_image_name",
+			VMStartTime:               "2007-01-01T01:01:00Z07:00",
+			VMReadyTime:               "2007-01-01T01:02:00Z07:00",
+			ImageNameBuildDatePattern: `-v(\d{8})$`,
+			ImageAgeWarnDays:          30,
+			ImageAgeCriticalDays:      60.5,
+			BuildDateSource:           TimestampSource{Env: "BUILD_DATE"},
+			VMStartTimeSource:         TimestampSource{MetadataAttribute: "vm-start-time"},
+			VMReadyTimeSource:         TimestampSource{File: "/var/run/vm_ready_time"},
+			MetadataURL:               "http://localhost:8080/attributes/",
+			TimestampLayouts:          []string{"epoch_seconds", "20060102"},
+			StartupMilestones:         []string{"vm_start", "docker_started", "app_ready"},
+			StartupTimestampsFile:     "/var/run/startup_timestamps",
+			ProcRoot:                  "/host/proc",
+			OSReleaseFile:             "/host/etc/os-release",
+			DockerHost:                "unix:///host/var/run/docker.sock",
 		})
 }
+
+func TestValidateConfig(t *testing.T) {
+	valid := func() *Config {
+		return NewFactory().CreateDefaultConfig().(*Config)
+	}
+	assert.NoError(t, valid().validate())
+
+	cfg := valid()
+	cfg.VMReadyTimeSource = TimestampSource{Env: "VM_READY_TIME", File: "/var/run/vm_ready_time"}
+	assert.Error(t, cfg.validate())
+
+	cfg = valid()
+	cfg.VMStartTimeSource = TimestampSource{MetadataAttribute: "vm-start-time"}
+	cfg.MetadataURL = "not a url"
+	assert.Error(t, cfg.validate())
+
+	cfg = valid()
+	cfg.StartupTimestampsFile = "/var/run/startup_timestamps"
+	cfg.StartupMilestones = []string{"vm_start"}
+	assert.Error(t, cfg.validate())
+
+	cfg = valid()
+	cfg.ImageNameBuildDatePattern = `-v\d{8}$`
+	assert.Error(t, cfg.validate())
+
+	cfg = valid()
+	cfg.TimestampLayouts = []string{layoutEpochSeconds, "15:04"}
+	assert.Error(t, cfg.validate())
+
+	cfg = valid()
+	cfg.Jitter = -time.Second
+	assert.Error(t, cfg.validate())
+
+	cfg = valid()
+	cfg.ImageAgeWarnDays = 60
+	cfg.ImageAgeCriticalDays = 30
+	assert.Error(t, cfg.validate())
+}
@@ -1,5 +1,6 @@
 // Package vmagereceiver generates and periodically emits metrics related to
 // time about the VM based on the config file, including metrics about the VM
-// image age and VM ready time. It is a metric receiver designed to work with
-// OpenTelemetry Collector.
+// image age, VM ready time, the duration of the VM startup phases, the host
+// uptime and the versions of the software running on the VM. It is a metric
+// receiver designed to work with OpenTelemetry Collector.
 package vmagereceiver
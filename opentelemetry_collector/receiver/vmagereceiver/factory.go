@@ -6,6 +6,7 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
 )
 
 const (
@@ -16,6 +17,9 @@ const (
 func createDefaultConfig() config.Receiver {
 	return &Config{
 		ReceiverSettings: config.NewReceiverSettings(config.NewComponentID(typeStr)),
+		MetadataURL:      defaultMetadataURL,
+		ProcRoot:         defaultProcRoot,
+		OSReleaseFile:    defaultOSReleaseFile,
 	}
 }
 
@@ -28,7 +32,28 @@ func createMetricsReceiver(
 ) (component.MetricsReceiver, error) {
 
 	cfg := config.(*Config)
-	collector := NewVMAgeCollector(cfg.ExportInterval, cfg.BuildDate, cfg.VMImageName, cfg.VMStartTime, cfg.VMReadyTime, consumer, params.Logger)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	collector := NewVMAgeCollector(cfg, params.BuildInfo, consumer, params.Logger)
+
+	// Report invalid timestamps at startup rather than only in the vm_timestamp_errors metric.
+	// Only the values and environment variables are checked: the files and the metadata server
+	// can be written after the collector starts, and reading the metadata server can block.
+	now := collector.now()
+	if !collector.buildDate.retryable() {
+		if _, err := collector.readBuildDate(ctx, now); err != nil {
+			params.Logger.Warn("Invalid VM timestamp", zap.Error(err))
+		}
+	}
+	for _, reader := range []*timestampReader{collector.vmStartTime, collector.vmReadyTime} {
+		if reader.retryable() {
+			continue
+		}
+		if _, err := readTimestamp(ctx, reader, now); err != nil {
+			params.Logger.Warn("Invalid VM timestamp", zap.Error(err))
+		}
+	}
 
 	receiver := &Receiver{
 		vmAgeCollector: collector,
@@ -2,6 +2,9 @@ package vmagereceiver
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -37,3 +40,27 @@ func TestCreateReceiver(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, mReceiver)
 }
+
+func TestCreateReceiverDoesNotReadTimestampSources(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.VMReadyTimeSource = TimestampSource{MetadataAttribute: "vm-ready-time"}
+	cfg.MetadataURL = server.URL
+	params := component.ReceiverCreateSettings{
+		TelemetrySettings: component.TelemetrySettings{
+			Logger: zap.NewNop(),
+		},
+	}
+
+	// The metadata server can block, so it is only read when scraping.
+	_, err := factory.CreateMetricsReceiver(context.Background(), params, cfg, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
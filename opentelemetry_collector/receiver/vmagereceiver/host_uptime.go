@@ -0,0 +1,110 @@
+package vmagereceiver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+)
+
+const defaultProcRoot = "/proc"
+
+// rebootTolerance is how much later than the VM start time the host may have booted
+// before it is considered rebooted. The boot time in /proc/stat is only accurate to
+// the second and drifts with clock adjustments.
+const rebootTolerance = time.Minute
+
+// readBootTime reads the time the host booted from the btime line of /proc/stat.
+func readBootTime(procRoot string) (time.Time, error) {
+	file, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "btime" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid btime %q: %v", fields[1], err)
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	if err = scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, errors.New("btime not found in stat")
+}
+
+// readUptime reads how long the host has been up from /proc/uptime.
+func readUptime(procRoot string) (time.Duration, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, "uptime"))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, errors.New("uptime is empty")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uptime %q: %v", fields[0], err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// rebootedSince returns true if the host booted after the VM was started,
+// meaning it was rebooted in place.
+func rebootedSince(bootTime, vmStartTime time.Time) bool {
+	return bootTime.Sub(vmStartTime) > rebootTolerance
+}
+
+func (collector *VMAgeCollector) addHostUptimeMetrics(builder *metricgenerator.MetricsBuilder, timestamps *vmTimestamps) {
+	now := timestamps.now
+
+	uptime, err := readUptime(collector.procRoot)
+	if err != nil {
+		collector.logger.Error("Error reading the host uptime", zap.Error(err))
+	} else {
+		builder.AddMetric(vmUptimeMetric).AddDoublePoint(uptime.Seconds(), collector.collectorStartTime, now, collector.attributes)
+	}
+
+	bootTime, err := readBootTime(collector.procRoot)
+	if err != nil {
+		collector.logger.Error("Error reading the host boot time", zap.Error(err))
+		return
+	}
+	builder.AddMetric(vmBootTimeMetric).AddInt64Point(bootTime.Unix(), collector.collectorStartTime, now, collector.attributes)
+
+	if timestamps.vmStartTimeErr != nil {
+		return
+	}
+	var rebooted int64
+	if rebootedSince(bootTime, timestamps.vmStartTime) {
+		rebooted = 1
+	}
+	builder.AddMetric(vmRebootedMetric).AddInt64Point(rebooted, collector.collectorStartTime, now, collector.attributes)
+}
+
+func (collector *VMAgeCollector) scrapeAndExportHostUptime(ctx context.Context, timestamps *vmTimestamps) {
+	builder := metricgenerator.NewMetricsBuilder()
+	collector.addHostUptimeMetrics(builder, timestamps)
+	if builder.Len() == 0 {
+		return
+	}
+	collector.export(ctx, builder, "Error sending VM uptime metrics")
+}
@@ -0,0 +1,63 @@
+package vmagereceiver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+var testProcRoot = filepath.Join("testdata", "proc")
+
+func TestReadBootTime(t *testing.T) {
+	bootTime, err := readBootTime(testProcRoot)
+	assert.Nil(t, err)
+	assert.True(t, time.Date(2007, time.January, 1, 10, 0, 0, 0, time.UTC).Equal(bootTime))
+}
+
+func TestReadBootTimeMissing(t *testing.T) {
+	_, err := readBootTime(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestReadUptime(t *testing.T) {
+	uptime, err := readUptime(testProcRoot)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour+500*time.Millisecond, uptime)
+}
+
+func TestRebootedSince(t *testing.T) {
+	bootTime := time.Date(2007, time.January, 1, 10, 0, 0, 0, time.UTC)
+	assert.False(t, rebootedSince(bootTime, bootTime.Add(time.Minute)))
+	assert.False(t, rebootedSince(bootTime, bootTime.Add(-30*time.Second)))
+	assert.True(t, rebootedSince(bootTime, bootTime.Add(-time.Hour)))
+}
+
+func TestScrapeAndExportHostUptime(t *testing.T) {
+	tests := []struct {
+		name        string
+		vmStartTime string
+		golden      string
+	}{
+		{"not rebooted", testVMStartTime, "testdata/host_uptime.golden"},
+		{"rebooted", "2007-01-01T09:00:00Z", "testdata/host_uptime_rebooted.golden"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := &metricstest.Sink{}
+			cfg := newTestConfig(testVMImageBuildDate, tc.vmStartTime, testVMReadyTime)
+			cfg.ProcRoot = testProcRoot
+			collector := newTestCollector(cfg, component.BuildInfo{}, sink)
+
+			collector.scrapeAndExportHostUptime(context.Background(), collector.readTimestamps(context.Background(), collector.now()))
+
+			metricstest.AssertMetricsGolden(t, tc.golden, sink.Last())
+		})
+	}
+}
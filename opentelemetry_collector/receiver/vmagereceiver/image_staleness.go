@@ -0,0 +1,62 @@
+package vmagereceiver
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// The values of the vm_image_staleness metric.
+const (
+	stalenessFresh    int64 = 0
+	stalenessWarn     int64 = 1
+	stalenessCritical int64 = 2
+)
+
+var stalenessNames = map[int64]string{
+	stalenessFresh:    "fresh",
+	stalenessWarn:     "warn",
+	stalenessCritical: "critical",
+}
+
+// validateImageAgeThresholds checks that the critical threshold is not below the warn threshold.
+// A threshold of 0 is not set.
+func validateImageAgeThresholds(warnDays, criticalDays float64) error {
+	if warnDays < 0 || criticalDays < 0 {
+		return fmt.Errorf("image age thresholds must not be negative, got warn %v and critical %v", warnDays, criticalDays)
+	}
+	if warnDays > 0 && criticalDays > 0 && criticalDays < warnDays {
+		return fmt.Errorf("ImageAgeCriticalDays %v must not be less than ImageAgeWarnDays %v", criticalDays, warnDays)
+	}
+	return nil
+}
+
+// imageStaleness returns the staleness status of an image of the given age in days.
+func imageStaleness(imageAgeDays, warnDays, criticalDays float64) int64 {
+	switch {
+	case criticalDays > 0 && imageAgeDays >= criticalDays:
+		return stalenessCritical
+	case warnDays > 0 && imageAgeDays >= warnDays:
+		return stalenessWarn
+	}
+	return stalenessFresh
+}
+
+func (collector *VMAgeCollector) stalenessEnabled() bool {
+	return collector.imageAgeWarnDays > 0 || collector.imageAgeCriticalDays > 0
+}
+
+// updateStaleness computes the staleness status of the image and logs when it changes,
+// so that crossing a threshold is logged once rather than on every export.
+func (collector *VMAgeCollector) updateStaleness(imageAgeDays float64) int64 {
+	staleness := imageStaleness(imageAgeDays, collector.imageAgeWarnDays, collector.imageAgeCriticalDays)
+	if staleness != collector.staleness {
+		if staleness > stalenessFresh {
+			collector.logger.Warn("The VM image age crossed the "+stalenessNames[staleness]+" threshold",
+				zap.String("vm_image_name", collector.vmImageName),
+				zap.Float64("image_age_days", imageAgeDays))
+		}
+		collector.staleness = staleness
+	}
+	return staleness
+}
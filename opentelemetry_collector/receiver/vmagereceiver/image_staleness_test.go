@@ -0,0 +1,71 @@
+package vmagereceiver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+func TestImageStaleness(t *testing.T) {
+	tests := []struct {
+		age          float64
+		warnDays     float64
+		criticalDays float64
+		expected     int64
+	}{
+		{age: 10, warnDays: 30, criticalDays: 60, expected: stalenessFresh},
+		{age: 30, warnDays: 30, criticalDays: 60, expected: stalenessWarn},
+		{age: 59.9, warnDays: 30, criticalDays: 60, expected: stalenessWarn},
+		{age: 60, warnDays: 30, criticalDays: 60, expected: stalenessCritical},
+		{age: 100, warnDays: 30, criticalDays: 0, expected: stalenessWarn},
+		{age: 100, warnDays: 0, criticalDays: 60, expected: stalenessCritical},
+		{age: 100, warnDays: 0, criticalDays: 0, expected: stalenessFresh},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, imageStaleness(tc.age, tc.warnDays, tc.criticalDays), "%+v", tc)
+	}
+}
+
+func TestValidateImageAgeThresholds(t *testing.T) {
+	assert.Nil(t, validateImageAgeThresholds(30, 60))
+	assert.Nil(t, validateImageAgeThresholds(30, 0))
+	assert.Nil(t, validateImageAgeThresholds(0, 0))
+	assert.Error(t, validateImageAgeThresholds(60, 30))
+	assert.Error(t, validateImageAgeThresholds(-1, 0))
+}
+
+func TestUpdateStalenessLogsOnce(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	cfg := newTestConfig(testVMImageBuildDate, testVMStartTime, testVMReadyTime)
+	cfg.ImageAgeWarnDays = 30
+	cfg.ImageAgeCriticalDays = 60
+	collector := NewVMAgeCollector(cfg, component.BuildInfo{}, nil, zap.New(core))
+
+	assert.Equal(t, stalenessFresh, collector.updateStaleness(10))
+	assert.Equal(t, stalenessWarn, collector.updateStaleness(31))
+	assert.Equal(t, stalenessWarn, collector.updateStaleness(32))
+	assert.Equal(t, stalenessCritical, collector.updateStaleness(61))
+	assert.Equal(t, stalenessCritical, collector.updateStaleness(62))
+
+	assert.Equal(t, 2, logs.Len())
+}
+
+func TestScrapeAndExportVMImageStaleness(t *testing.T) {
+	sink := &metricstest.Sink{}
+	cfg := newTestConfig(testVMImageBuildDate, testVMStartTime, testVMReadyTime)
+	cfg.ImageAgeWarnDays = 30
+	cfg.ImageAgeCriticalDays = 60
+	collector := newTestCollector(cfg, component.BuildInfo{}, sink)
+
+	collector.scrapeAndExportVMImageAge(context.Background(), collector.readTimestamps(context.Background(), collector.now()))
+
+	// The test build date is in 2006, so the image is critically stale.
+	metricstest.AssertMetricsGolden(t, "testdata/vm_image_staleness.golden", sink.Last())
+}
@@ -25,8 +25,9 @@ func (receiver *Receiver) Start(ctx context.Context, host component.Host) error
 
 // Shutdown stops and cancels the underlying VM metrics generator.
 func (receiver *Receiver) Shutdown(ctx context.Context) error {
+	var err error
 	receiver.stopOnce.Do(func() {
-		receiver.vmAgeCollector.StopCollection()
+		err = receiver.vmAgeCollector.StopCollection(ctx)
 	})
-	return nil
+	return err
 }
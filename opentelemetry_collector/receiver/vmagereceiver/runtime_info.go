@@ -0,0 +1,132 @@
+package vmagereceiver
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+)
+
+const (
+	defaultOSReleaseFile = "/etc/os-release"
+	unknownVersion       = "unknown"
+	dockerVersionTimeout = 10 * time.Second
+)
+
+// dockerVersionClient is the part of the docker client used to read the docker engine version.
+type dockerVersionClient interface {
+	ServerVersion(ctx context.Context) (types.Version, error)
+}
+
+func newDockerVersionClient(dockerHost string) (dockerVersionClient, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if dockerHost != "" {
+		opts = append(opts, client.WithHost(dockerHost))
+	}
+	return client.NewClientWithOpts(opts...)
+}
+
+// readKernelRelease reads the kernel release from /proc/sys/kernel/osrelease.
+func readKernelRelease(procRoot string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, "sys", "kernel", "osrelease"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readOSRelease reads the name of the OS release from an os-release file. It uses PRETTY_NAME,
+// or ID and VERSION_ID if PRETTY_NAME is not set.
+func readOSRelease(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			continue
+		}
+		values[line[:i]] = strings.Trim(line[i+1:], `"'`)
+	}
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if name := values["PRETTY_NAME"]; name != "" {
+		return name, nil
+	}
+	return strings.TrimSpace(values["ID"] + " " + values["VERSION_ID"]), nil
+}
+
+func (collector *VMAgeCollector) readDockerVersion(ctx context.Context) (string, error) {
+	if collector.docker == nil {
+		docker, err := collector.newDockerClient(collector.dockerHost)
+		if err != nil {
+			return "", err
+		}
+		collector.docker = docker
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dockerVersionTimeout)
+	defer cancel()
+	version, err := collector.docker.ServerVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	return version.Version, nil
+}
+
+// versionOrUnknown returns the version, or unknownVersion after logging the error if it could not be read.
+func (collector *VMAgeCollector) versionOrUnknown(name string, version string, err error) string {
+	if err != nil {
+		collector.logger.Warn("Error reading the "+name, zap.Error(err))
+		return unknownVersion
+	}
+	if version == "" {
+		return unknownVersion
+	}
+	return version
+}
+
+func (collector *VMAgeCollector) addRuntimeInfoMetrics(ctx context.Context, builder *metricgenerator.MetricsBuilder) {
+	osRelease, err := readOSRelease(collector.osReleaseFile)
+	osRelease = collector.versionOrUnknown("OS release", osRelease, err)
+	kernelRelease, err := readKernelRelease(collector.procRoot)
+	kernelRelease = collector.versionOrUnknown("kernel release", kernelRelease, err)
+	dockerVersion, err := collector.readDockerVersion(ctx)
+	dockerVersion = collector.versionOrUnknown("docker engine version", dockerVersion, err)
+	collectorVersion := collector.versionOrUnknown("collector version", collector.collectorVersion, nil)
+
+	attributes := map[string]string{
+		vmImageNameLabel.Key:      collector.vmImageName,
+		osReleaseLabel.Key:        osRelease,
+		kernelReleaseLabel.Key:    kernelRelease,
+		dockerVersionLabel.Key:    dockerVersion,
+		collectorVersionLabel.Key: collectorVersion,
+	}
+	builder.AddMetric(vmRuntimeInfoMetric).AddInt64Point(1, collector.collectorStartTime, collector.now(), attributes)
+}
+
+func (collector *VMAgeCollector) scrapeAndExportRuntimeInfo(ctx context.Context) {
+	builder := metricgenerator.NewMetricsBuilder()
+	collector.addRuntimeInfoMetrics(ctx, builder)
+	collector.export(ctx, builder, "Error sending VM runtime info metrics")
+}
@@ -0,0 +1,82 @@
+package vmagereceiver
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+type fakeDockerVersion struct {
+	version string
+	err     error
+}
+
+func (d *fakeDockerVersion) ServerVersion(ctx context.Context) (types.Version, error) {
+	return types.Version{Version: d.version}, d.err
+}
+
+func TestReadKernelRelease(t *testing.T) {
+	release, err := readKernelRelease(testProcRoot)
+	assert.Nil(t, err)
+	assert.Equal(t, "5.10.0-test-amd64", release)
+}
+
+func TestReadOSRelease(t *testing.T) {
+	release, err := readOSRelease(filepath.Join("testdata", "os-release"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Debian GNU/Linux 10 (buster)", release)
+}
+
+func TestReadOSReleaseWithoutPrettyName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "os-release")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("# comment\nID=debian\nVERSION_ID=\"10\"\n"), 0644))
+	release, err := readOSRelease(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "debian 10", release)
+}
+
+func TestScrapeAndExportRuntimeInfo(t *testing.T) {
+	tests := []struct {
+		name          string
+		docker        *fakeDockerVersion
+		osReleaseFile string
+		golden        string
+	}{
+		{
+			name:          "all versions",
+			docker:        &fakeDockerVersion{version: "20.10.14"},
+			osReleaseFile: filepath.Join("testdata", "os-release"),
+			golden:        "testdata/runtime_info.golden",
+		},
+		{
+			// The versions that can not be read are reported as unknown.
+			name:          "unreadable versions",
+			docker:        &fakeDockerVersion{err: errors.New("connection refused")},
+			osReleaseFile: filepath.Join("testdata", "missing"),
+			golden:        "testdata/runtime_info_unreadable.golden",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := &metricstest.Sink{}
+			cfg := newTestConfig(testVMImageBuildDate, testVMStartTime, testVMReadyTime)
+			cfg.ProcRoot = testProcRoot
+			cfg.OSReleaseFile = tc.osReleaseFile
+			collector := newTestCollector(cfg, component.BuildInfo{Version: "v1.2.3"}, sink)
+			collector.newDockerClient = func(string) (dockerVersionClient, error) { return tc.docker, nil }
+
+			collector.scrapeAndExportRuntimeInfo(context.Background())
+
+			metricstest.AssertMetricsGolden(t, tc.golden, sink.Last())
+		})
+	}
+}
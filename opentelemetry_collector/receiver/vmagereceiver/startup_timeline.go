@@ -0,0 +1,99 @@
+package vmagereceiver
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+)
+
+// readStartupTimestamps reads the startup timestamps file. Each line holds the name of a
+// milestone and the time it was reached in one of the layouts, separated by whitespace, e.g.
+// "docker_started 2020-01-01T00:01:00Z". When a milestone is listed more than once,
+// the last time wins. Lines that can not be parsed are skipped.
+// It returns nil without an error if the file does not exist yet.
+func readStartupTimestamps(path string, layouts []string, logger *zap.Logger) (map[string]time.Time, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	timestamps := make(map[string]time.Time)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			logger.Debug("Skipping malformed startup timestamp line", zap.String("line", scanner.Text()))
+			continue
+		}
+		timestamp, _, err := parseTimestamp(fields[1], layouts)
+		if err != nil {
+			logger.Debug("Skipping malformed startup timestamp line", zap.String("line", scanner.Text()), zap.Error(err))
+			continue
+		}
+		timestamps[fields[0]] = timestamp
+	}
+	return timestamps, scanner.Err()
+}
+
+// addStartupTimelineMetrics adds the duration of each startup phase reached so far.
+// A phase is named after the milestone that ends it and lasts from the previous milestone.
+// The total startup duration is only generated once the last milestone is reached.
+func (collector *VMAgeCollector) addStartupTimelineMetrics(builder *metricgenerator.MetricsBuilder, timestamps map[string]time.Time) {
+	now := collector.now()
+	// reached are the indexes of the milestones ending a phase that has been completed.
+	var reached []int
+	for i := 1; i < len(collector.startupMilestones); i++ {
+		start, startOK := timestamps[collector.startupMilestones[i-1]]
+		end, endOK := timestamps[collector.startupMilestones[i]]
+		if startOK && endOK && !end.Before(start) {
+			reached = append(reached, i)
+		}
+	}
+
+	if len(reached) > 0 {
+		phases := builder.AddMetric(vmStartupPhaseDurationMetric)
+		for _, i := range reached {
+			start := timestamps[collector.startupMilestones[i-1]]
+			end := timestamps[collector.startupMilestones[i]]
+			attributes := map[string]string{
+				vmImageNameLabel.Key:  collector.vmImageName,
+				startupPhaseLabel.Key: collector.startupMilestones[i],
+			}
+			phases.AddDoublePoint(end.Sub(start).Seconds(), collector.collectorStartTime, now, attributes)
+		}
+	}
+
+	first, firstOK := timestamps[collector.startupMilestones[0]]
+	last, lastOK := timestamps[collector.startupMilestones[len(collector.startupMilestones)-1]]
+	if firstOK && lastOK && !last.Before(first) {
+		builder.AddMetric(vmStartupDurationMetric).AddDoublePoint(
+			last.Sub(first).Seconds(), collector.collectorStartTime, now, collector.attributes)
+	}
+}
+
+func (collector *VMAgeCollector) scrapeAndExportStartupTimeline(ctx context.Context) {
+	timestamps, err := readStartupTimestamps(collector.startupTimestampsFile, collector.timestampLayouts, collector.logger)
+	if err != nil {
+		collector.logger.Error("Error reading the startup timestamps file", zap.Error(err))
+		return
+	}
+	builder := metricgenerator.NewMetricsBuilder()
+	collector.addStartupTimelineMetrics(builder, timestamps)
+	if builder.Len() == 0 {
+		return
+	}
+	collector.export(ctx, builder, "Error sending VM startup timeline metrics")
+}
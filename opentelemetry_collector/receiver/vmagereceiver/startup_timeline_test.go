@@ -0,0 +1,65 @@
+package vmagereceiver
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+const testStartupTimestamps = `vm_start 2020-01-01T00:00:00Z
+image_pulled 2020-01-01T00:00:30Z
+malformed line here
+docker_started 2020-01-01T00:00:40Z
+nginx_started not_a_time
+`
+
+var testStartupMilestones = []string{"vm_start", "image_pulled", "docker_started", "nginx_started", "app_ready"}
+
+func writeStartupTimestamps(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "startup_timestamps")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	return path
+}
+
+func TestReadStartupTimestamps(t *testing.T) {
+	path := writeStartupTimestamps(t, testStartupTimestamps+"image_pulled 2020-01-01T00:00:35Z\n")
+
+	timestamps, err := readStartupTimestamps(path, nil, zap.NewNop())
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]time.Time{
+		"vm_start":       time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"image_pulled":   time.Date(2020, time.January, 1, 0, 0, 35, 0, time.UTC),
+		"docker_started": time.Date(2020, time.January, 1, 0, 0, 40, 0, time.UTC),
+	}, timestamps)
+}
+
+func TestReadStartupTimestampsMissingFile(t *testing.T) {
+	timestamps, err := readStartupTimestamps(filepath.Join(t.TempDir(), "missing"), nil, zap.NewNop())
+	assert.Nil(t, err)
+	assert.Nil(t, timestamps)
+}
+
+func TestScrapeAndExportStartupTimeline(t *testing.T) {
+	sink := &metricstest.Sink{}
+	cfg := newTestConfig(testVMImageBuildDate, testVMStartTime, testVMReadyTime)
+	cfg.StartupMilestones = testStartupMilestones
+	cfg.StartupTimestampsFile = writeStartupTimestamps(t, testStartupTimestamps)
+	collector := newTestCollector(cfg, component.BuildInfo{}, sink)
+
+	collector.scrapeAndExportStartupTimeline(context.Background())
+	assert.Nil(t, ioutil.WriteFile(cfg.StartupTimestampsFile, []byte(testStartupTimestamps+
+		"nginx_started 2020-01-01T00:00:45Z\napp_ready 2020-01-01T00:01:05Z\n"), 0644))
+	collector.scrapeAndExportStartupTimeline(context.Background())
+
+	// The total is not exported until the last milestone is reached.
+	metricstest.AssertSinkGolden(t, "testdata/startup_timeline.golden", sink)
+}
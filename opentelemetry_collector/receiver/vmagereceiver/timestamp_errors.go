@@ -0,0 +1,147 @@
+package vmagereceiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
+)
+
+// The VM timestamps, as named in the field label of vm_timestamp_errors.
+const (
+	fieldBuildDate   = "build_date"
+	fieldVMStartTime = "vm_start_time"
+	fieldVMReadyTime = "vm_ready_time"
+)
+
+// Reasons a VM timestamp can be invalid.
+const (
+	reasonMissing          = "missing"
+	reasonUnparseable      = "unparseable"
+	reasonFuture           = "future"
+	reasonNegativeDuration = "negative_duration"
+)
+
+// timestampError describes a problem with one of the VM timestamps.
+type timestampError struct {
+	field  string
+	reason string
+	err    error
+}
+
+func (e *timestampError) Error() string {
+	return fmt.Sprintf("%s is %s: %v", e.field, e.reason, e.err)
+}
+
+func (e *timestampError) Unwrap() error {
+	return e.err
+}
+
+// readTimestamp reads a timestamp from the reader and checks that it is not in the future.
+func readTimestamp(ctx context.Context, reader *timestampReader, now time.Time) (time.Time, error) {
+	timestamp, err := reader.read(ctx)
+	if err != nil {
+		return timestamp, err
+	}
+	if timestamp.After(now) {
+		return timestamp, &timestampError{
+			field:  reader.name,
+			reason: reasonFuture,
+			err:    fmt.Errorf("%v is after the current time %v", timestamp, now),
+		}
+	}
+	return timestamp, nil
+}
+
+// vmTimestamps are the VM timestamps as read at the start of a scrape. Each source is
+// read once per scrape, and the metrics made from the timestamps share the result.
+type vmTimestamps struct {
+	now time.Time
+
+	buildDate      time.Time
+	buildDateErr   error
+	vmStartTime    time.Time
+	vmStartTimeErr error
+	vmReadyTime    time.Time
+	vmReadyTimeErr error
+}
+
+// readTimestamps reads the VM timestamps, giving up on the sources still being read when ctx is done.
+func (collector *VMAgeCollector) readTimestamps(ctx context.Context, now time.Time) *vmTimestamps {
+	timestamps := &vmTimestamps{now: now}
+	timestamps.buildDate, timestamps.buildDateErr = collector.readBuildDate(ctx, now)
+	timestamps.vmStartTime, timestamps.vmStartTimeErr = readTimestamp(ctx, collector.vmStartTime, now)
+	timestamps.vmReadyTime, timestamps.vmReadyTimeErr = readTimestamp(ctx, collector.vmReadyTime, now)
+	return timestamps
+}
+
+// readyDuration returns the time from the VM start time to the VM ready time.
+func (timestamps *vmTimestamps) readyDuration() (time.Duration, error) {
+	if timestamps.vmStartTimeErr != nil {
+		return 0, timestamps.vmStartTimeErr
+	}
+	if timestamps.vmReadyTimeErr != nil {
+		return 0, timestamps.vmReadyTimeErr
+	}
+	if timestamps.vmReadyTime.Before(timestamps.vmStartTime) {
+		return 0, &timestampError{
+			field:  fieldVMReadyTime,
+			reason: reasonNegativeDuration,
+			err:    fmt.Errorf("%v is before the VM start time %v", timestamps.vmReadyTime, timestamps.vmStartTime),
+		}
+	}
+	return timestamps.vmReadyTime.Sub(timestamps.vmStartTime), nil
+}
+
+// errors returns every problem found with the timestamps. A timestamp that has not been
+// written yet is expected while the VM starts, and is not a problem.
+func (timestamps *vmTimestamps) errors() []*timestampError {
+	var errs []*timestampError
+	add := func(err error) {
+		var timestampErr *timestampError
+		if errors.As(err, &timestampErr) && !errors.Is(err, errTimestampNotAvailable) {
+			errs = append(errs, timestampErr)
+		}
+	}
+
+	add(timestamps.buildDateErr)
+	add(timestamps.vmStartTimeErr)
+	add(timestamps.vmReadyTimeErr)
+	if timestamps.vmStartTimeErr == nil && timestamps.vmReadyTimeErr == nil {
+		_, err := timestamps.readyDuration()
+		add(err)
+	}
+	return errs
+}
+
+func (collector *VMAgeCollector) addErrorMetrics(builder *metricgenerator.MetricsBuilder, errs []*timestampError, now time.Time) {
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].field != errs[j].field {
+			return errs[i].field < errs[j].field
+		}
+		return errs[i].reason < errs[j].reason
+	})
+
+	metric := builder.AddMetric(vmTimestampErrorsMetric)
+	for _, err := range errs {
+		attributes := map[string]string{
+			vmImageNameLabel.Key:          collector.vmImageName,
+			timestampFieldLabel.Key:       err.field,
+			timestampErrorReasonLabel.Key: err.reason,
+		}
+		metric.AddInt64Point(1, collector.collectorStartTime, now, attributes)
+	}
+}
+
+func (collector *VMAgeCollector) scrapeAndExportTimestampErrors(ctx context.Context, timestamps *vmTimestamps) {
+	errs := timestamps.errors()
+	if len(errs) == 0 {
+		return
+	}
+	builder := metricgenerator.NewMetricsBuilder()
+	collector.addErrorMetrics(builder, errs, timestamps.now)
+	collector.export(ctx, builder, "Error sending VM timestamp error metrics")
+}
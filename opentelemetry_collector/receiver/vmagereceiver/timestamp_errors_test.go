@@ -0,0 +1,128 @@
+package vmagereceiver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+)
+
+type fieldReason struct {
+	field  string
+	reason string
+}
+
+func TestTimestampErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		buildDate   string
+		vmStartTime string
+		vmReadyTime string
+		expected    []fieldReason
+	}{
+		{
+			name:        "valid",
+			buildDate:   testVMImageBuildDate,
+			vmStartTime: testVMStartTime,
+			vmReadyTime: testVMReadyTime,
+		},
+		{
+			name:        "missing",
+			buildDate:   "",
+			vmStartTime: testVMStartTime,
+			vmReadyTime: "  ",
+			expected:    []fieldReason{{fieldBuildDate, reasonMissing}, {fieldVMReadyTime, reasonMissing}},
+		},
+		{
+			name:        "unparseable",
+			buildDate:   "unknown",
+			vmStartTime: "misformated_date",
+			vmReadyTime: testVMReadyTime,
+			expected:    []fieldReason{{fieldBuildDate, reasonUnparseable}, {fieldVMStartTime, reasonUnparseable}},
+		},
+		{
+			name:        "future",
+			buildDate:   "2999-01-01T00:00:00Z",
+			vmStartTime: testVMStartTime,
+			vmReadyTime: testVMReadyTime,
+			expected:    []fieldReason{{fieldBuildDate, reasonFuture}},
+		},
+		{
+			name:        "negative duration",
+			buildDate:   testVMImageBuildDate,
+			vmStartTime: testVMReadyTime,
+			vmReadyTime: testVMStartTime,
+			expected:    []fieldReason{{fieldVMReadyTime, reasonNegativeDuration}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			collector := NewVMAgeCollector(newTestConfig(tc.buildDate, tc.vmStartTime, tc.vmReadyTime), component.BuildInfo{}, nil, zap.NewNop())
+			var actual []fieldReason
+			for _, err := range collector.readTimestamps(context.Background(), time.Now()).errors() {
+				actual = append(actual, fieldReason{err.field, err.reason})
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestScrapeAndExportTimestampErrors(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(newTestConfig("", testVMReadyTime, testVMStartTime), component.BuildInfo{}, sink)
+
+	collector.scrapeAndExportTimestampErrors(context.Background(), collector.readTimestamps(context.Background(), collector.now()))
+	metricstest.AssertMetricsGolden(t, "testdata/timestamp_errors.golden", sink.Last())
+
+	// The image age and ready time are not exported while their timestamps are invalid.
+	collector.scrapeAndExportVMImageAge(context.Background(), collector.readTimestamps(context.Background(), collector.now()))
+	collector.scrapeAndExportVMReadyTime(context.Background(), collector.readTimestamps(context.Background(), collector.now()))
+	assert.Len(t, sink.AllMetrics(), 1)
+}
+
+func TestTimestampErrorsSkipsTimestampsNotWrittenYet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vm_ready_time")
+	cfg := newTestConfig(testVMImageBuildDate, testVMStartTime, "")
+	cfg.VMReadyTimeSource = TimestampSource{File: path}
+	collector := NewVMAgeCollector(cfg, component.BuildInfo{}, nil, zap.NewNop())
+
+	// The VM is not ready yet, which is not an error.
+	assert.Empty(t, collector.readTimestamps(context.Background(), time.Now()).errors())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("unknown"), 0644))
+	errs := collector.readTimestamps(context.Background(), time.Now()).errors()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, fieldReason{fieldVMReadyTime, reasonUnparseable}, fieldReason{errs[0].field, errs[0].reason})
+	}
+}
+
+func TestScrapeAndExportReadsEachSourceOnce(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(testVMImageBuildDate, "", testVMReadyTime)
+	cfg.VMStartTimeSource = TimestampSource{MetadataAttribute: "vm-start-time"}
+	cfg.MetadataURL = server.URL
+	cfg.ProcRoot = "testdata/proc"
+	cfg.OSReleaseFile = "testdata/os-release"
+	collector := newTestCollector(cfg, component.BuildInfo{}, &metricstest.Sink{})
+	collector.docker = &fakeDockerVersion{version: "20.10.14"}
+
+	collector.scrapeAndExport(context.Background())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,86 @@
+package vmagereceiver
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Layouts for timestamps given as the number of seconds or milliseconds since the Unix epoch,
+// e.g. the output of date +%s. They can be listed in TimestampLayouts with the Go time layouts.
+const (
+	layoutEpochSeconds = "epoch_seconds"
+	layoutEpochMillis  = "epoch_millis"
+)
+
+// defaultTimestampLayouts are tried in order when TimestampLayouts is not set.
+// The date only layouts come before the epoch layouts so that a date like
+// 20200131 is not read as a number of seconds.
+var defaultTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02",
+	"20060102",
+	layoutEpochSeconds,
+	layoutEpochMillis,
+}
+
+// validateTimestampLayouts checks that the Go time layouts in layouts have at least a year,
+// so that the timestamps they parse are dates.
+func validateTimestampLayouts(layouts []string) error {
+	reference := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	for _, layout := range layouts {
+		if layout == layoutEpochSeconds || layout == layoutEpochMillis {
+			continue
+		}
+		parsed, err := time.Parse(layout, reference.Format(layout))
+		if err != nil || parsed.Year() != reference.Year() {
+			return fmt.Errorf("TimestampLayouts %q is not a valid time layout with a year", layout)
+		}
+	}
+	return nil
+}
+
+// parseEpoch parses an integer number of seconds or milliseconds since the Unix epoch.
+// Epoch seconds have at most 10 digits and epoch milliseconds 11 to 13, which keeps
+// the two apart for any time between 1973 and 2286.
+func parseEpoch(value string, millis bool) (time.Time, error) {
+	maxDigits := 10
+	if millis {
+		maxDigits = 13
+	}
+	if len(value) == 0 || len(value) > maxDigits || (millis && len(value) < 11) {
+		return time.Time{}, fmt.Errorf("%q is not an epoch timestamp", value)
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, fmt.Errorf("%q is not an epoch timestamp", value)
+	}
+	if millis {
+		return time.Unix(0, n*int64(time.Millisecond)).UTC(), nil
+	}
+	return time.Unix(n, 0).UTC(), nil
+}
+
+// parseTimestamp parses the value with the first of the layouts it matches
+// and returns the layout that matched.
+func parseTimestamp(value string, layouts []string) (time.Time, string, error) {
+	if len(layouts) == 0 {
+		layouts = defaultTimestampLayouts
+	}
+	for _, layout := range layouts {
+		var timestamp time.Time
+		var err error
+		switch layout {
+		case layoutEpochSeconds:
+			timestamp, err = parseEpoch(value, false)
+		case layoutEpochMillis:
+			timestamp, err = parseEpoch(value, true)
+		default:
+			timestamp, err = time.Parse(layout, value)
+		}
+		if err == nil {
+			return timestamp, layout, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("%q does not match any of the timestamp layouts %q", value, layouts)
+}
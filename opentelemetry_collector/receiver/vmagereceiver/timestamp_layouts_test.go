@@ -0,0 +1,56 @@
+package vmagereceiver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimestampDefaultLayouts(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+		layout   string
+	}{
+		{"2020-09-14T10:30:00.5Z", time.Date(2020, time.September, 14, 10, 30, 0, 500000000, time.UTC), time.RFC3339Nano},
+		{"2020-09-14", time.Date(2020, time.September, 14, 0, 0, 0, 0, time.UTC), "2006-01-02"},
+		{"20200914", time.Date(2020, time.September, 14, 0, 0, 0, 0, time.UTC), "20060102"},
+		{"1600079400", time.Date(2020, time.September, 14, 10, 30, 0, 0, time.UTC), layoutEpochSeconds},
+		{"1600079400500", time.Date(2020, time.September, 14, 10, 30, 0, 500000000, time.UTC), layoutEpochMillis},
+	}
+
+	for _, tc := range tests {
+		timestamp, layout, err := parseTimestamp(tc.value, nil)
+		if assert.Nil(t, err, tc.value) {
+			assert.True(t, tc.expected.Equal(timestamp), "%s parsed as %v", tc.value, timestamp)
+			assert.Equal(t, tc.layout, layout, tc.value)
+		}
+	}
+}
+
+func TestParseTimestampConfiguredLayouts(t *testing.T) {
+	layouts := []string{layoutEpochSeconds, "02 Jan 2006"}
+
+	timestamp, layout, err := parseTimestamp("14 Sep 2020", layouts)
+	assert.Nil(t, err)
+	assert.Equal(t, "02 Jan 2006", layout)
+	assert.True(t, time.Date(2020, time.September, 14, 0, 0, 0, 0, time.UTC).Equal(timestamp))
+
+	_, _, err = parseTimestamp("2020-09-14T10:30:00Z", layouts)
+	assert.Error(t, err)
+}
+
+func TestValidateTimestampLayouts(t *testing.T) {
+	assert.NoError(t, validateTimestampLayouts(nil))
+	assert.NoError(t, validateTimestampLayouts(defaultTimestampLayouts))
+	assert.Error(t, validateTimestampLayouts([]string{"15:04:05"}))
+	assert.Error(t, validateTimestampLayouts([]string{"unknown"}))
+}
+
+func TestParseTimestampInvalid(t *testing.T) {
+	for _, value := range []string{"unknown", "", "-1600079400", "16000794005001234"} {
+		_, _, err := parseTimestamp(value, nil)
+		assert.Error(t, err, value)
+	}
+}
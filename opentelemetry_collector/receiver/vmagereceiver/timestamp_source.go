@@ -0,0 +1,138 @@
+package vmagereceiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/attributes/"
+
+// errTimestampNotAvailable is returned while a timestamp that can be written
+// after the collector starts has not been written yet.
+var errTimestampNotAvailable = errors.New("timestamp is not available yet")
+
+// timestampReader reads a timestamp from its source. Once the timestamp has been
+// parsed, it is kept and the source is not read again. The errors of the sources that
+// cannot change, a value or an environment variable, are kept too.
+// The errors it returns are *timestampError.
+type timestampReader struct {
+	name        string
+	source      TimestampSource
+	metadataURL string
+	layouts     []string
+	client      *http.Client
+	lookupEnv   func(string) (string, bool)
+	logger      *zap.Logger
+
+	done  bool
+	value time.Time
+	err   error
+}
+
+func newTimestampReader(name string, source TimestampSource, metadataURL string, layouts []string, logger *zap.Logger) *timestampReader {
+	if metadataURL == "" {
+		metadataURL = defaultMetadataURL
+	}
+	return &timestampReader{
+		name:        name,
+		source:      source,
+		metadataURL: metadataURL,
+		layouts:     layouts,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		lookupEnv:   os.LookupEnv,
+		logger:      logger,
+	}
+}
+
+// read returns the timestamp. The errors of sources that can be retried, like a file that does
+// not exist yet or is only partly written, are returned without being kept so that the next
+// call reads the source again. The metadata server is read with ctx.
+func (r *timestampReader) read(ctx context.Context) (time.Time, error) {
+	if r.done {
+		return r.value, r.err
+	}
+
+	value, err := r.readAndParse(ctx)
+	if err != nil && r.retryable() {
+		r.logger.Debug("Could not read "+r.name+" yet", zap.Error(err))
+		return value, err
+	}
+	r.value, r.err, r.done = value, err, true
+	return r.value, r.err
+}
+
+func (r *timestampReader) readAndParse(ctx context.Context) (time.Time, error) {
+	raw, err := r.readSource(ctx)
+	if err != nil {
+		return time.Time{}, &timestampError{field: r.name, reason: reasonMissing, err: err}
+	}
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, &timestampError{field: r.name, reason: reasonMissing, err: errors.New("timestamp is empty")}
+	}
+	value, layout, err := parseTimestamp(raw, r.layouts)
+	if err != nil {
+		return time.Time{}, &timestampError{field: r.name, reason: reasonUnparseable, err: err}
+	}
+	r.logger.Debug("Parsed "+r.name, zap.String("value", raw), zap.String("layout", layout))
+	return value, nil
+}
+
+// retryable returns true if the source can still change after the collector starts.
+func (r *timestampReader) retryable() bool {
+	return r.source.File != "" || r.source.MetadataAttribute != ""
+}
+
+func (r *timestampReader) readSource(ctx context.Context) (string, error) {
+	switch {
+	case r.source.Value != "":
+		return r.source.Value, nil
+	case r.source.Env != "":
+		value, ok := r.lookupEnv(r.source.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", r.source.Env)
+		}
+		return value, nil
+	case r.source.File != "":
+		data, err := ioutil.ReadFile(r.source.File)
+		if os.IsNotExist(err) {
+			return "", errTimestampNotAvailable
+		}
+		return string(data), err
+	case r.source.MetadataAttribute != "":
+		return r.readMetadata(ctx)
+	}
+	return "", errors.New("no timestamp source is set")
+}
+
+func (r *timestampReader) readMetadata(ctx context.Context) (string, error) {
+	url := strings.TrimSuffix(r.metadataURL, "/") + "/" + r.source.MetadataAttribute
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return "", errTimestampNotAvailable
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned status %d for %s", response.StatusCode, url)
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	return string(data), err
+}
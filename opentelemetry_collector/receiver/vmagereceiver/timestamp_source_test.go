@@ -0,0 +1,137 @@
+package vmagereceiver
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var testTimestamp = time.Date(2007, time.January, 1, 10, 1, 0, 123456789, time.FixedZone("", 0))
+
+func TestReadTimestampValue(t *testing.T) {
+	reader := newTimestampReader("test", TimestampSource{Value: testVMStartTime}, "", nil, zap.NewNop())
+	value, err := reader.read(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, testTimestamp.Equal(value))
+}
+
+func TestReadTimestampEnv(t *testing.T) {
+	reader := newTimestampReader("test", TimestampSource{Env: "VM_START_TIME"}, "", nil, zap.NewNop())
+	reader.lookupEnv = func(name string) (string, bool) {
+		if name == "VM_START_TIME" {
+			return testVMStartTime, true
+		}
+		return "", false
+	}
+	value, err := reader.read(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, testTimestamp.Equal(value))
+}
+
+func TestReadTimestampEnvNotSet(t *testing.T) {
+	reader := newTimestampReader("test", TimestampSource{Env: "VM_START_TIME"}, "", nil, zap.NewNop())
+	reader.lookupEnv = func(string) (string, bool) { return "", false }
+	_, err := reader.read(context.Background())
+	assert.Error(t, err)
+	assert.True(t, reader.done)
+}
+
+func TestReadTimestampFileWrittenLater(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vm_ready_time")
+	reader := newTimestampReader("test", TimestampSource{File: path}, "", nil, zap.NewNop())
+
+	_, err := reader.read(context.Background())
+	assert.True(t, errors.Is(err, errTimestampNotAvailable))
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(testVMStartTime+"\n"), 0644))
+	value, err := reader.read(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, testTimestamp.Equal(value))
+}
+
+func TestReadTimestampFilePartlyWritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vm_ready_time")
+	reader := newTimestampReader("test", TimestampSource{File: path}, "", nil, zap.NewNop())
+
+	assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+	_, err := reader.read(context.Background())
+	var timestampErr *timestampError
+	assert.True(t, errors.As(err, &timestampErr))
+	assert.Equal(t, reasonMissing, timestampErr.reason)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(testVMStartTime[:15]), 0644))
+	_, err = reader.read(context.Background())
+	assert.True(t, errors.As(err, &timestampErr))
+	assert.Equal(t, reasonUnparseable, timestampErr.reason)
+
+	// The file is read again until it parses, and then the value is kept.
+	assert.Nil(t, ioutil.WriteFile(path, []byte(testVMStartTime), 0644))
+	value, err := reader.read(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, testTimestamp.Equal(value))
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("unknown"), 0644))
+	value, err = reader.read(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, testTimestamp.Equal(value))
+}
+
+func TestReadTimestampMetadata(t *testing.T) {
+	available := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/attributes/vm-ready-time" || !available {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(testVMStartTime))
+	}))
+	defer server.Close()
+
+	reader := newTimestampReader("test", TimestampSource{MetadataAttribute: "vm-ready-time"}, server.URL+"/attributes/", nil, zap.NewNop())
+	_, err := reader.read(context.Background())
+	assert.True(t, errors.Is(err, errTimestampNotAvailable))
+
+	available = true
+	value, err := reader.read(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, testTimestamp.Equal(value))
+}
+
+func TestReadTimestampMetadataCanceled(t *testing.T) {
+	blocked := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer server.Close()
+	defer close(blocked)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader := newTimestampReader("test", TimestampSource{MetadataAttribute: "vm-ready-time"}, server.URL+"/attributes/", nil, zap.NewNop())
+	_, err := reader.read(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, reader.done)
+}
+
+func TestReadTimestampNoSource(t *testing.T) {
+	reader := newTimestampReader("test", TimestampSource{}, "", nil, zap.NewNop())
+	_, err := reader.read(context.Background())
+	assert.Error(t, err)
+}
+
+func TestTimestampSourceOrValue(t *testing.T) {
+	assert.Equal(t, TimestampSource{Value: "literal"}, TimestampSource{}.orValue("literal"))
+	assert.Equal(t, TimestampSource{Env: "VAR"}, TimestampSource{Env: "VAR"}.orValue("literal"))
+}
@@ -3,15 +3,15 @@ package vmagereceiver
 import (
 	"context"
 	"errors"
+	"regexp"
 	"time"
 
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.uber.org/zap"
 
 	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
-
-	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/opencensus"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 // VMAgeCollector is a struct that generates metrics based on the
@@ -19,25 +19,40 @@ import (
 type VMAgeCollector struct {
 	consumer consumer.Metrics
 
+	now func() time.Time
+	// collectorStartTime is read from now when the collection starts.
 	collectorStartTime time.Time
-
-	exportInterval time.Duration
-	buildDate      string
-	done           chan struct{}
-	vmImageName    string
-	vmStartTime    string
-	vmReadyTime    string
+	controller         *scrapeloop.Controller
+	vmImageName        string
 
 	logger *zap.Logger
 
-	parsedBuildDate   time.Time
-	buildDateError    bool
-	parsedVMStartTime time.Time
-	vmStartTimeError  bool
-	parsedVMReadyTime time.Time
-	vmReadyTimeError  bool
+	buildDate   *timestampReader
+	vmStartTime *timestampReader
+	vmReadyTime *timestampReader
+
+	// imageNamePattern extracts the build date from the VM image name when buildDate can not be read.
+	imageNamePattern *regexp.Regexp
+	// buildDateSource is where the build date was last read from.
+	buildDateSource string
+
+	imageAgeWarnDays     float64
+	imageAgeCriticalDays float64
+	staleness            int64
+
+	startupMilestones     []string
+	startupTimestampsFile string
+	timestampLayouts      []string
+	procRoot              string
+	osReleaseFile         string
 
-	labelValues []*metricspb.LabelValue
+	dockerHost       string
+	docker           dockerVersionClient
+	newDockerClient  func(dockerHost string) (dockerVersionClient, error)
+	collectorVersion string
+
+	// attributes are the attributes of the metrics labelled with just the VM image name.
+	attributes map[string]string
 }
 
 const (
@@ -45,29 +60,53 @@ const (
 )
 
 // NewVMAgeCollector creates a new VMAgeCollector that generates metrics
-// based on the buildDate and vmImageName.
-func NewVMAgeCollector(exportInterval time.Duration, buildDate, vmImageName, vmStartTime, vmReadyTime string, consumer consumer.Metrics, logger *zap.Logger) *VMAgeCollector {
+// based on the build date, VM image name and VM lifecycle timestamps in the config.
+// The opts are passed on to the scrape loop controller.
+func NewVMAgeCollector(cfg *Config, buildInfo component.BuildInfo, consumer consumer.Metrics, logger *zap.Logger, opts ...scrapeloop.Option) *VMAgeCollector {
+	exportInterval := cfg.ExportInterval
 	if exportInterval <= 0 {
 		exportInterval = defaultExportInterval
 	}
 
-	collector := &VMAgeCollector{
-		consumer:           consumer,
-		collectorStartTime: time.Now(),
-		buildDate:          buildDate,
-		vmImageName:        vmImageName,
-		vmStartTime:        vmStartTime,
-		vmReadyTime:        vmReadyTime,
-		exportInterval:     exportInterval,
-		done:               make(chan struct{}),
-		logger:             logger,
+	procRoot := cfg.ProcRoot
+	if procRoot == "" {
+		procRoot = defaultProcRoot
 	}
 
-	return collector
-}
+	osReleaseFile := cfg.OSReleaseFile
+	if osReleaseFile == "" {
+		osReleaseFile = defaultOSReleaseFile
+	}
+
+	imageNamePattern, err := compileImageNamePattern(cfg.ImageNameBuildDatePattern)
+	if err != nil {
+		logger.Error("Not reading the build date from the VM image name", zap.Error(err))
+	}
 
-func (collector *VMAgeCollector) parseDate(date string) (time.Time, error) {
-	return time.Parse(time.RFC3339Nano, date)
+	collector := &VMAgeCollector{
+		consumer:              consumer,
+		now:                   time.Now,
+		vmImageName:           cfg.VMImageName,
+		imageAgeWarnDays:      cfg.ImageAgeWarnDays,
+		imageAgeCriticalDays:  cfg.ImageAgeCriticalDays,
+		buildDate:             newTimestampReader(fieldBuildDate, cfg.BuildDateSource.orValue(cfg.BuildDate), cfg.MetadataURL, cfg.TimestampLayouts, logger),
+		vmStartTime:           newTimestampReader(fieldVMStartTime, cfg.VMStartTimeSource.orValue(cfg.VMStartTime), cfg.MetadataURL, cfg.TimestampLayouts, logger),
+		vmReadyTime:           newTimestampReader(fieldVMReadyTime, cfg.VMReadyTimeSource.orValue(cfg.VMReadyTime), cfg.MetadataURL, cfg.TimestampLayouts, logger),
+		startupMilestones:     cfg.StartupMilestones,
+		startupTimestampsFile: cfg.StartupTimestampsFile,
+		timestampLayouts:      cfg.TimestampLayouts,
+		imageNamePattern:      imageNamePattern,
+		procRoot:              procRoot,
+		osReleaseFile:         osReleaseFile,
+		dockerHost:            cfg.DockerHost,
+		newDockerClient:       newDockerVersionClient,
+		collectorVersion:      buildInfo.Version,
+		logger:                logger,
+	}
+	// The export interval is always positive and the scrape settings are checked by validate,
+	// so the scrape loop config is valid.
+	collector.controller, _ = scrapeloop.NewController(scrapeloop.Config{Interval: exportInterval, Settings: cfg.Settings}, collector.scrapeAndExport, opts...)
+	return collector
 }
 
 func calculateImageAge(buildDate time.Time, now time.Time) (float64, error) {
@@ -79,100 +118,78 @@ func calculateImageAge(buildDate time.Time, now time.Time) (float64, error) {
 	return imageAgeDays, nil
 }
 
-// StartCollection starts a go routine with a ticker that periodically generates and exports the metrics.
+// StartCollection starts a go routine that exports the metrics right away and then
+// periodically.
 func (collector *VMAgeCollector) StartCollection() {
+	collector.collectorStartTime = collector.now()
 	collector.setupCollection()
-	readyTime := float64(collector.parsedVMReadyTime.Sub(collector.parsedVMStartTime) / time.Second)
-
-	go func() {
-		ticker := time.NewTicker(collector.exportInterval)
-		for {
-			select {
-			case <-ticker.C:
-				collector.scrapeAndExportVMImageAge()
-				collector.scrapeAndExportVMReadyTime(readyTime)
-			case <-collector.done:
-				return
-			}
-		}
-	}()
+	collector.controller.Start()
 }
 
-func (collector *VMAgeCollector) setupCollection() {
-	var err error
-
-	collector.parsedBuildDate, err = collector.parseDate(collector.buildDate)
-	collector.buildDateError = (err != nil)
-
-	collector.parsedVMStartTime, err = collector.parseDate(collector.vmStartTime)
-	if err != nil {
-		collector.vmStartTimeError = true
-		collector.logger.Error("Error parsing vmStartTime", zap.Error(err))
+func (collector *VMAgeCollector) scrapeAndExport(ctx context.Context) {
+	timestamps := collector.readTimestamps(ctx, collector.now())
+	collector.scrapeAndExportVMImageAge(ctx, timestamps)
+	collector.scrapeAndExportVMReadyTime(ctx, timestamps)
+	collector.scrapeAndExportTimestampErrors(ctx, timestamps)
+	if collector.startupTimelineEnabled() {
+		collector.scrapeAndExportStartupTimeline(ctx)
 	}
-
-	collector.parsedVMReadyTime, err = collector.parseDate(collector.vmReadyTime)
-	collector.vmReadyTimeError = (err != nil)
-	if err != nil {
-		collector.vmReadyTimeError = true
-		collector.logger.Error("Error parsing vmReadyTime", zap.Error(err))
-	}
-
-	collector.labelValues = []*metricspb.LabelValue{metricgenerator.MakeLabelValue(collector.vmImageName)}
+	collector.scrapeAndExportHostUptime(ctx, timestamps)
+	collector.scrapeAndExportRuntimeInfo(ctx)
 }
 
-// StopCollection stops the generation and export of the metrics.
-func (collector *VMAgeCollector) StopCollection() {
-	close(collector.done)
+func (collector *VMAgeCollector) setupCollection() {
+	collector.attributes = map[string]string{vmImageNameLabel.Key: collector.vmImageName}
 }
 
-func (collector *VMAgeCollector) makeErrorMetrics() *metricspb.Metric {
-	timeseries := metricgenerator.MakeInt64TimeSeries(1, collector.collectorStartTime, time.Now(), collector.labelValues)
-	return &metricspb.Metric{
-		MetricDescriptor: vmImageAgesErrorMetric,
-		Timeseries:       []*metricspb.TimeSeries{timeseries},
-	}
+func (collector *VMAgeCollector) startupTimelineEnabled() bool {
+	return collector.startupTimestampsFile != "" && len(collector.startupMilestones) > 1
 }
 
-func makeMetrics(metricDescriptor *metricspb.MetricDescriptor, timeseries *metricspb.TimeSeries) []*metricspb.Metric {
-	return []*metricspb.Metric{
-		{
-			MetricDescriptor: metricDescriptor,
-			Timeseries:       []*metricspb.TimeSeries{timeseries},
-		},
-	}
+// StopCollection stops the generation and export of the metrics and waits for an export
+// in progress to finish or for ctx to be done.
+func (collector *VMAgeCollector) StopCollection(ctx context.Context) error {
+	return collector.controller.Shutdown(ctx)
 }
 
-func (collector *VMAgeCollector) export(metrics []*metricspb.Metric, errorKey string) {
-	ctx := context.Background()
-	err := collector.consumer.ConsumeMetrics(ctx, opencensus.OCToMetrics(nil, nil, metrics))
+func (collector *VMAgeCollector) export(ctx context.Context, builder *metricgenerator.MetricsBuilder, errorKey string) {
+	if err := metricRegistry.Validate(builder.Metrics()); err != nil {
+		collector.logger.Error("Generated metrics do not match their descriptors", zap.Error(err))
+	}
+	err := collector.consumer.ConsumeMetrics(ctx, builder.Metrics())
 	if err != nil {
 		collector.logger.Error(errorKey, zap.Error(err))
 	}
 }
 
-func (collector *VMAgeCollector) scrapeAndExportVMImageAge() {
-	var metrics []*metricspb.Metric
-
-	if collector.buildDateError {
-		metrics = []*metricspb.Metric{collector.makeErrorMetrics()}
-	} else {
-		imageAge, err := calculateImageAge(collector.parsedBuildDate, time.Now())
-		if err != nil {
-			metrics = []*metricspb.Metric{collector.makeErrorMetrics()}
-		} else {
-			timeseries := metricgenerator.MakeDoubleTimeSeries(imageAge, collector.collectorStartTime, time.Now(), collector.labelValues)
-			metrics = makeMetrics(vmImageAgeMetric, timeseries)
-		}
+func (collector *VMAgeCollector) scrapeAndExportVMImageAge(ctx context.Context, timestamps *vmTimestamps) {
+	if timestamps.buildDateErr != nil {
+		return
+	}
+	now := timestamps.now
+	imageAge, err := calculateImageAge(timestamps.buildDate, now)
+	if err != nil {
+		return
 	}
 
-	collector.export(metrics, "Error sending VM image age metrics")
+	builder := metricgenerator.NewMetricsBuilder()
+	builder.AddMetric(vmImageAgeMetric).AddDoublePoint(imageAge, collector.collectorStartTime, now, collector.attributes)
+	if collector.stalenessEnabled() {
+		staleness := collector.updateStaleness(imageAge)
+		builder.AddMetric(vmImageStalenessMetric).AddInt64Point(staleness, collector.collectorStartTime, now, collector.attributes)
+	}
+	collector.export(ctx, builder, "Error sending VM image age metrics")
 }
 
-func (collector *VMAgeCollector) scrapeAndExportVMReadyTime(readyTime float64) {
-	if collector.vmReadyTimeError {
+func (collector *VMAgeCollector) scrapeAndExportVMReadyTime(ctx context.Context, timestamps *vmTimestamps) {
+	now := timestamps.now
+	readyDuration, err := timestamps.readyDuration()
+	if err != nil {
 		return
 	}
+	readyTime := float64(readyDuration / time.Second)
 
-	timeseries := metricgenerator.MakeDoubleTimeSeries(readyTime, collector.collectorStartTime, time.Now(), collector.labelValues)
-	collector.export(makeMetrics(vmReadyTimeMetric, timeseries), "Error sending VM ready time metrics")
+	builder := metricgenerator.NewMetricsBuilder()
+	builder.AddMetric(vmReadyTimeMetric).AddDoublePoint(readyTime, collector.collectorStartTime, now, collector.attributes)
+	collector.export(ctx, builder, "Error sending VM ready time metrics")
 }
@@ -2,16 +2,20 @@ package vmagereceiver
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/model/pdata"
 	"go.uber.org/zap"
 
-	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/opencensus"
+
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricstest"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/scrapeloop"
 )
 
 const (
@@ -47,15 +51,27 @@ func TestCalculateImageAgeWith0Age(t *testing.T) {
 	assert.Equal(t, float64(0.25), age)
 }
 
+func newTestConfig(buildDate, vmStartTime, vmReadyTime string) *Config {
+	return &Config{
+		BuildDate:   buildDate,
+		VMImageName: testVMImageName,
+		VMStartTime: vmStartTime,
+		VMReadyTime: vmReadyTime,
+	}
+}
+
 func TestParseInputTimes(t *testing.T) {
-	collector := NewVMAgeCollector(0, testVMImageBuildDate, testVMImageName, testVMStartTime, testVMReadyTime, nil, zap.NewNop())
+	collector := NewVMAgeCollector(newTestConfig(testVMImageBuildDate, testVMStartTime, testVMReadyTime), component.BuildInfo{}, nil, zap.NewNop())
 	collector.setupCollection()
 
-	assert.False(t, collector.buildDateError)
-	assert.False(t, collector.vmStartTimeError)
-	assert.False(t, collector.vmReadyTimeError)
+	buildDate, err := collector.buildDate.read(context.Background())
+	assert.Nil(t, err)
+	_, err = collector.vmStartTime.read(context.Background())
+	assert.Nil(t, err)
+	_, err = collector.vmReadyTime.read(context.Background())
+	assert.Nil(t, err)
 
-	diff := collector.parsedBuildDate.Sub(time.Date(2006, time.January, 2, 15, 4, 5, 123456789, time.FixedZone("", 0)))
+	diff := buildDate.Sub(time.Date(2006, time.January, 2, 15, 4, 5, 123456789, time.FixedZone("", 0)))
 	assert.Equal(t, diff, time.Second*0)
 }
 
@@ -97,128 +113,145 @@ func TestParseInputTimesError(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		collector := NewVMAgeCollector(0, tc.buildDate, testVMImageName, tc.vmStartTime, tc.vmReadyTime, nil, zap.NewNop())
+		collector := NewVMAgeCollector(newTestConfig(tc.buildDate, tc.vmStartTime, tc.vmReadyTime), component.BuildInfo{}, nil, zap.NewNop())
 		collector.setupCollection()
-		assert.Equal(t, tc.buildDateError, collector.buildDateError)
-		assert.Equal(t, tc.vmStartTimeError, collector.vmStartTimeError)
-		assert.Equal(t, tc.vmReadyTime, collector.vmReadyTime)
-		assert.Equal(t, tc.vmReadyTimeError, collector.vmReadyTimeError)
-		assert.Equal(t, tc.vmStartTimeError, collector.vmStartTimeError)
+
+		_, err := collector.buildDate.read(context.Background())
+		assert.Equal(t, tc.buildDateError, err != nil)
+		_, err = collector.vmStartTime.read(context.Background())
+		assert.Equal(t, tc.vmStartTimeError, err != nil)
+		_, err = collector.vmReadyTime.read(context.Background())
+		assert.Equal(t, tc.vmReadyTimeError, err != nil)
 	}
 }
 
-type fakeConsumer struct {
-	storage *metricsStore
-}
+// testNow is the time the collectors made by newTestCollector run at.
+var testNow = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
 
-func (c fakeConsumer) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{
-		MutatesData: false,
-	}
+// newTestCollector creates a collector running at testNow, so that the metrics it exports
+// can be compared with golden files.
+func newTestCollector(cfg *Config, buildInfo component.BuildInfo, consumer consumer.Metrics) *VMAgeCollector {
+	collector := NewVMAgeCollector(cfg, buildInfo, consumer, zap.NewNop())
+	collector.now = func() time.Time { return testNow }
+	collector.collectorStartTime = testNow
+	collector.setupCollection()
+	return collector
 }
 
-type metricsStore struct {
-	metrics pdata.Metrics
-}
+func TestScrapeAndExportVMImageAge(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(newTestConfig(testVMImageBuildDate, testVMStartTime, testVMReadyTime), component.BuildInfo{}, sink)
 
-func (s *metricsStore) storeMetric(toStore pdata.Metrics) {
-	s.metrics = toStore
+	collector.scrapeAndExportVMImageAge(context.Background(), collector.readTimestamps(context.Background(), collector.now()))
+	assert.NoError(t, metricRegistry.Validate(sink.Last()))
+	metricstest.AssertMetricsGolden(t, "testdata/vm_image_age.golden", sink.Last())
 }
 
-func (c fakeConsumer) ConsumeMetrics(ctx context.Context, metrics pdata.Metrics) error {
-	c.storage.storeMetric(metrics)
-	return nil
-}
+func TestScrapeAndExportVMReadyTime(t *testing.T) {
+	sink := &metricstest.Sink{}
+	collector := newTestCollector(newTestConfig(testVMImageBuildDate, testVMStartTime, testVMReadyTime), component.BuildInfo{}, sink)
 
-func TestScrapeAndExportVMImageAge(t *testing.T) {
-	c := fakeConsumer{storage: &metricsStore{}}
-	collector := NewVMAgeCollector(0, testVMImageBuildDate, testVMImageName, testVMStartTime, testVMReadyTime, c, zap.NewNop())
-	collector.setupCollection()
+	collector.scrapeAndExportVMReadyTime(context.Background(), collector.readTimestamps(context.Background(), collector.now()))
+	metricstest.AssertMetricsGolden(t, "testdata/vm_ready_time.golden", sink.Last())
+}
 
-	expectedDesc := &metricspb.MetricDescriptor{
-		Name:        "vm_image_age",
-		Description: "The VM image age for the VM instance",
-		Unit:        "Days",
-		Type:        metricspb.MetricDescriptor_GAUGE_DOUBLE,
-		LabelKeys: []*metricspb.LabelKey{{
-			Key: "vm_image_name",
-		}},
-	}
+type fakeTicker struct {
+	c       chan time.Time
+	stopped chan struct{}
+}
 
-	collector.scrapeAndExportVMImageAge()
-	assertMetricGreaterThan0Double(t, expectedDesc, c.storage.metrics)
+func newFakeTicker() *fakeTicker {
+	return &fakeTicker{c: make(chan time.Time), stopped: make(chan struct{})}
 }
 
-func TestScrapeAndExportVMReadyTime(t *testing.T) {
-	consumer := fakeConsumer{storage: &metricsStore{}}
-	collector := NewVMAgeCollector(0, testVMImageBuildDate, testVMImageName, testVMStartTime, testVMReadyTime, consumer, zap.NewNop())
-	collector.setupCollection()
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.c
+}
 
-	expectedDesc := &metricspb.MetricDescriptor{
-		Name:        "vm_ready_time",
-		Description: "The amount of time from when Flex first started setting up the VM in the startup script to when it finished setting up all VM runtime components.",
-		Unit:        "Seconds",
-		Type:        metricspb.MetricDescriptor_GAUGE_DOUBLE,
-		LabelKeys: []*metricspb.LabelKey{{
-			Key: "vm_image_name",
-		}},
-	}
+func (t *fakeTicker) Stop() {
+	close(t.stopped)
+}
 
-	collector.scrapeAndExportVMReadyTime(float64(60))
-	assertMetricGreaterThan0Double(t, expectedDesc, consumer.storage.metrics)
+// fakeClock is the clock of the collector and of its scrape loop, which gets ticker.
+type fakeClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	ticker   *fakeTicker
+	interval time.Duration
 }
 
-func TestScrapeAndExportVMImageAgeWithError(t *testing.T) {
-	consumer := fakeConsumer{storage: &metricsStore{}}
-	collector := NewVMAgeCollector(0, "", testVMImageName, testVMStartTime, testVMReadyTime, consumer, zap.NewNop())
-	collector.setupCollection()
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
 
-	expectedMetricDescriptor := &metricspb.MetricDescriptor{
-		Name:        "vm_image_ages_error",
-		Description: "The current number of VM instances with errors exporting the VM image age.",
-		Unit:        "Count",
-		Type:        metricspb.MetricDescriptor_GAUGE_INT64,
-		LabelKeys: []*metricspb.LabelKey{{
-			Key: "vm_image_name",
-		}},
-	}
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
 
-	collector.scrapeAndExportVMImageAge()
+func (c *fakeClock) NewTicker(d time.Duration) scrapeloop.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interval = d
+	return c.ticker
+}
 
-	// TODO: Rewrite tests to directly use pdata.Metrics instead of converting back to consumerdata.MetricsData.
-	_, _, cdMetrics := opencensus.ResourceMetricsToOC(consumer.storage.metrics.ResourceMetrics().At(0))
-	if assert.Len(t, cdMetrics, 1) {
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
 
-		actualMetric := cdMetrics[0]
-		assert.Equal(t, expectedMetricDescriptor, actualMetric.MetricDescriptor)
+// imageAgeConsumer sends the exported vm_image_age values on a channel.
+type imageAgeConsumer struct {
+	ages chan float64
+}
 
-		if assert.Len(t, actualMetric.Timeseries, 1) {
-			expectedLabel := []*metricspb.LabelValue{{Value: testVMImageName, HasValue: true}}
-			timeseries := actualMetric.Timeseries[0]
-			assert.Equal(t, expectedLabel, timeseries.LabelValues)
+func (c imageAgeConsumer) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
 
-			if assert.Len(t, timeseries.Points, 1) {
-				assert.Equal(t, int64(1), timeseries.Points[0].GetInt64Value())
-			}
+func (c imageAgeConsumer) ConsumeMetrics(ctx context.Context, metrics pdata.Metrics) error {
+	_, _, data := opencensus.ResourceMetricsToOC(metrics.ResourceMetrics().At(0))
+	for _, metric := range data {
+		if metric.MetricDescriptor.Name == "vm_image_age" {
+			c.ages <- metric.Timeseries[0].Points[0].GetDoubleValue()
 		}
 	}
+	return nil
 }
 
-func assertMetricGreaterThan0Double(t *testing.T, expectedMetricDescriptor *metricspb.MetricDescriptor, metrics pdata.Metrics) {
-	// TODO: Rewrite tests to directly use pdata.Metrics instead of converting back to consumerdata.MetricsData.
-	_, _, cdMetrics := opencensus.ResourceMetricsToOC(metrics.ResourceMetrics().At(0))
-	if assert.Len(t, cdMetrics, 1) {
-		actualMetric := cdMetrics[0]
-		assert.Equal(t, expectedMetricDescriptor, actualMetric.MetricDescriptor)
-
-		if assert.Len(t, actualMetric.Timeseries, 1) {
-			expectedLabel := []*metricspb.LabelValue{{Value: testVMImageName, HasValue: true}}
-			timeseries := actualMetric.Timeseries[0]
-			assert.Equal(t, expectedLabel, timeseries.LabelValues)
-
-			if assert.Len(t, timeseries.Points, 1) {
-				assert.Greater(t, timeseries.Points[0].GetDoubleValue(), 0.0)
-			}
-		}
+func TestStartCollectionExportsOnEachTick(t *testing.T) {
+	ages := make(chan float64, 10)
+	cfg := newTestConfig("2020-01-01T00:00:00Z", testVMStartTime, testVMReadyTime)
+	cfg.ProcRoot = "testdata/proc"
+	cfg.OSReleaseFile = "testdata/os-release"
+	clock := &fakeClock{now: time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC), ticker: newFakeTicker()}
+	collector := NewVMAgeCollector(cfg, component.BuildInfo{}, imageAgeConsumer{ages: ages}, zap.NewNop(), scrapeloop.WithClock(clock))
+	collector.now = clock.Now
+	collector.docker = &fakeDockerVersion{version: "20.10.14"}
+
+	collector.StartCollection()
+	assert.Equal(t, clock.Now(), collector.collectorStartTime)
+
+	// The first export happens right away, without waiting for the ticker.
+	assert.Equal(t, 1.0, <-ages)
+
+	clock.Add(24 * time.Hour)
+	clock.ticker.c <- clock.Now()
+	assert.Equal(t, 2.0, <-ages)
+
+	clock.Add(24 * time.Hour)
+	clock.ticker.c <- clock.Now()
+	assert.Equal(t, 3.0, <-ages)
+
+	assert.NoError(t, collector.StopCollection(context.Background()))
+	select {
+	case <-clock.ticker.stopped:
+	default:
+		t.Error("The ticker was not stopped")
 	}
+	assert.Equal(t, defaultExportInterval, clock.interval)
+	assert.Empty(t, ages)
 }
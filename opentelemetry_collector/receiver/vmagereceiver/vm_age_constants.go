@@ -1,34 +1,127 @@
 package vmagereceiver
 
 import (
-	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	"github.com/googlecloudplatform/appengine-sidecars-docker/opentelemetry_collector/receiver/metricgenerator"
 )
 
-var vmImageNameLabel = &metricspb.LabelKey{
+// metricRegistry holds the descriptors of the metrics generated by the VM age receiver.
+var metricRegistry = metricgenerator.NewRegistry()
+
+var vmImageNameLabel = metricgenerator.LabelKey{
 	Key:         "vm_image_name",
 	Description: "The name of the VM image",
 }
 
-var vmImageAgeMetric = &metricspb.MetricDescriptor{
+var vmImageAgeMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 	Name:        "vm_image_age",
 	Description: "The VM image age for the VM instance",
 	Unit:        "Days",
-	Type:        metricspb.MetricDescriptor_GAUGE_DOUBLE,
-	LabelKeys:   []*metricspb.LabelKey{vmImageNameLabel},
+	Type:        metricgenerator.GaugeDouble,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel},
+})
+
+var vmImageStalenessMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "vm_image_staleness",
+	Description: "The staleness status of the VM image based on its age: 0 is fresh, 1 is past the warn threshold and 2 is past the critical threshold.",
+	Unit:        "1",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel},
+})
+
+var timestampFieldLabel = metricgenerator.LabelKey{
+	Key:         "field",
+	Description: "The VM timestamp with the error: build_date, vm_start_time or vm_ready_time",
 }
 
-var vmImageAgesErrorMetric = &metricspb.MetricDescriptor{
-	Name:        "vm_image_ages_error",
-	Description: "The current number of VM instances with errors exporting the VM image age.",
-	Unit:        "Count",
-	Type:        metricspb.MetricDescriptor_GAUGE_INT64,
-	LabelKeys:   []*metricspb.LabelKey{vmImageNameLabel},
+var timestampErrorReasonLabel = metricgenerator.LabelKey{
+	Key:         "reason",
+	Description: "Why the VM timestamp is invalid: missing, unparseable, future or negative_duration",
 }
 
-var vmReadyTimeMetric = &metricspb.MetricDescriptor{
+var vmTimestampErrorsMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "vm_timestamp_errors",
+	Description: "The VM timestamps that can not be used to generate the VM age metrics. The value is always 1 for a timestamp with an error.",
+	Unit:        "1",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel, timestampFieldLabel, timestampErrorReasonLabel},
+})
+
+var vmReadyTimeMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
 	Name:        "vm_ready_time",
 	Description: "The amount of time from when Flex first started setting up the VM in the startup script to when it finished setting up all VM runtime components.",
 	Unit:        "Seconds",
-	Type:        metricspb.MetricDescriptor_GAUGE_DOUBLE,
-	LabelKeys:   []*metricspb.LabelKey{vmImageNameLabel},
-}
\ No newline at end of file
+	Type:        metricgenerator.GaugeDouble,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel},
+})
+var startupPhaseLabel = metricgenerator.LabelKey{
+	Key:         "phase",
+	Description: "The startup phase, named after the milestone that ends it",
+}
+
+var vmStartupPhaseDurationMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "vm_startup_phase_duration",
+	Description: "The amount of time each phase of the VM startup took, from the previous startup milestone to the one the phase is named after.",
+	Unit:        "s",
+	Type:        metricgenerator.GaugeDouble,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel, startupPhaseLabel},
+})
+
+var vmStartupDurationMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "vm_startup_duration",
+	Description: "The amount of time from the first to the last VM startup milestone.",
+	Unit:        "s",
+	Type:        metricgenerator.GaugeDouble,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel},
+})
+
+var vmUptimeMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "vm_uptime",
+	Description: "The amount of time since the VM host booted.",
+	Unit:        "s",
+	Type:        metricgenerator.GaugeDouble,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel},
+})
+
+var vmBootTimeMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "vm_boot_time",
+	Description: "The time the VM host booted, in seconds since the Unix epoch.",
+	Unit:        "s",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel},
+})
+
+var vmRebootedMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "vm_rebooted",
+	Description: "1 if the VM host booted after the VM start time, meaning it was rebooted in place, 0 otherwise.",
+	Unit:        "1",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel},
+})
+
+var osReleaseLabel = metricgenerator.LabelKey{
+	Key:         "os_release",
+	Description: "The OS release from /etc/os-release",
+}
+
+var kernelReleaseLabel = metricgenerator.LabelKey{
+	Key:         "kernel_release",
+	Description: "The kernel release",
+}
+
+var dockerVersionLabel = metricgenerator.LabelKey{
+	Key:         "docker_version",
+	Description: "The docker engine version",
+}
+
+var collectorVersionLabel = metricgenerator.LabelKey{
+	Key:         "collector_version",
+	Description: "The build version of the OpenTelemetry collector",
+}
+
+var vmRuntimeInfoMetric = metricRegistry.MustRegister(&metricgenerator.MetricDescriptor{
+	Name:        "vm_runtime_info",
+	Description: "The versions of the OS, kernel, docker engine and collector running on the VM. The value is always 1, the versions are in the labels.",
+	Unit:        "1",
+	Type:        metricgenerator.GaugeInt64,
+	LabelKeys:   []metricgenerator.LabelKey{vmImageNameLabel, osReleaseLabel, kernelReleaseLabel, dockerVersionLabel, collectorVersionLabel},
+})
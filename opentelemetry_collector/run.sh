@@ -13,14 +13,49 @@ set_metadata () {
   fi
 }
 
-set_metadata "${BUILD_DATE}" "BUILD_DATE"
 set_metadata "${IMAGE}" "IMAGE_NAME"
 set_metadata "${GAE_BACKEND_VERSION}" "VERSION"
 set_metadata "${GAE_BACKEND_NAME}" "SERVICE"
 set_metadata "${INSTANCE_ID}" "INSTANCE"
 set_metadata "${NGINX_STATS_URL}" "NGINX_STATS_URL"
-set_metadata "${VM_READY_TIME}" "VM_READY_TIME"
-set_metadata "${VM_START_TIME}" "VM_START_TIME"
+
+# BUILD_DATE and VM_START_TIME are known when the container starts, and the
+# collector reads them from the environment. The VM ready time can be written
+# later by the VM to the vm_ready_time file of the vm_timestamps directory,
+# mounted from the host, see the Dockerfile. A ready time already known is
+# written there right away.
+VM_TIMESTAMPS_DIR=/var/run/vm_timestamps
+mkdir -p "${VM_TIMESTAMPS_DIR}"
+if [[ -n "${VM_READY_TIME}" ]]; then
+  # Rename the file into place so that the collector never reads it half written.
+  echo "${VM_READY_TIME}" > "${VM_TIMESTAMPS_DIR}/vm_ready_time.tmp"
+  mv "${VM_TIMESTAMPS_DIR}/vm_ready_time.tmp" "${VM_TIMESTAMPS_DIR}/vm_ready_time"
+fi
+
+# The startup timestamps file gets a "<milestone> <time>" line as each startup
+# milestone is reached, see startup_milestones in the collector config. This
+# script appends vm_start and collector_started, and vm_ready if the ready time
+# is already known. Otherwise the VM startup script that writes the
+# vm_ready_time file appends vm_ready. The file is only appended to, so the
+# lines of earlier milestones survive a restart of the collector.
+STARTUP_TIMESTAMPS_FILE="${VM_TIMESTAMPS_DIR}/startup_timestamps"
+touch "${STARTUP_TIMESTAMPS_FILE}"
+if [[ -n "${VM_START_TIME}" ]] && ! grep -q "^vm_start " "${STARTUP_TIMESTAMPS_FILE}"; then
+  echo "vm_start ${VM_START_TIME}" >> "${STARTUP_TIMESTAMPS_FILE}"
+fi
+if [[ -n "${VM_READY_TIME}" ]] && ! grep -q "^vm_ready " "${STARTUP_TIMESTAMPS_FILE}"; then
+  echo "vm_ready ${VM_READY_TIME}" >> "${STARTUP_TIMESTAMPS_FILE}"
+fi
+echo "collector_started $(date -u +%Y-%m-%dT%H:%M:%SZ)" >> "${STARTUP_TIMESTAMPS_FILE}"
+
+if [[ ! -e /host/proc/stat ]]; then
+  echo "The host proc filesystem is not mounted on /host/proc, the host stats are not collected." >&2
+fi
+
+if [[ ! -d /var/log/nginx ]]; then
+  echo "The nginx log directory is not mounted on /var/log/nginx, the nginx errors are not counted." >&2
+fi
+mkdir -p /var/lib/opentelemetry_collector
 
 if [[ -z "${ZONE}" ]]; then
   sed -i "s/@REGION@/unknown/" "${OPENTELEMETRY_CONFIG_FILE}"